@@ -2,18 +2,22 @@ package main
 
 import (
 	"bytes"
+	"expvar"
 	"fmt"
 	"github.com/cfchou/icecream/cmd/apiserver/handler"
 	"github.com/cfchou/icecream/cmd/apiserver/middleware"
-	"github.com/cfchou/icecream/cmd/apiserver/util"
-	"github.com/cfchou/icecream/pkg/backend/mongodb"
-	"github.com/globalsign/mgo"
+	"github.com/cfchou/icecream/cmd/apiserver/openapi"
+	"github.com/cfchou/icecream/cmd/apiserver/registry"
+	"github.com/cfchou/icecream/pkg/backend/cache"
 	"github.com/gorilla/mux"
 	"github.com/inconshreveable/log15"
 	"github.com/justinas/alice"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 )
@@ -27,10 +31,28 @@ server:
   host: 127.0.0.1
   port: 8080
   limitToRead: 10
+  cursorSecret: ""
+  requestTimeout: 10s
 db:
+  type: mongodb
   database: icecream
   host: 127.0.0.1
   port: 27017
+cache:
+  enabled: false
+  size: 1000
+  ttl: 30s
+trash:
+  retention: 720h
+  purgeInterval: 1h
+graphql:
+  maxDepth: 10
+  maxComplexity: 1000
+grpc:
+  enabled: false
+  port: 0
+openapi:
+  docs: false
 `)
 )
 
@@ -71,26 +93,85 @@ func main() {
 	serverConf := viper.Sub("server")
 	dbConf := viper.Sub("db")
 
-	url := util.CreateMongoURL(dbConf, appName)
-	session, err := mgo.Dial(url)
+	if pflag.Arg(0) == "migrate" {
+		if err := migrate(pflag.Args()[1:], dbConf); err == errMigrateUsage {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		} else if err != nil {
+			log.Error("migrate failed", "err", err.Error())
+			os.Exit(-1)
+		}
+		return
+	}
+
+	// appName identifies apiserver to the db, e.g. in mongoDB's url.
+	dbConf.SetDefault("appName", appName)
+	productBackend, apiKeyBackend, closeBackends, err := registry.Create(dbConf)
 	if err != nil {
-		log.Error("mgo.Dial failed", "err", err.Error())
+		log.Error("registry.Create failed", "err", err.Error())
 		return
 	}
-	defer session.Close()
+	defer closeBackends()
+
+	cacheConf := viper.Sub("cache")
+	if cacheConf.GetBool("enabled") {
+		cached, err := cache.CreateCachedProductBackend(productBackend,
+			cacheConf.GetInt("size"), cacheConf.GetDuration("ttl"))
+		if err != nil {
+			log.Error("cache.CreateCachedProductBackend failed",
+				"err", err.Error())
+			return
+		}
+		// Stats are visible at /debug/vars
+		expvar.Publish("cache", expvar.Func(func() interface{} {
+			return cached.Stats()
+		}))
+		productBackend = cached
+	}
 
-	productBackend, _ := mongodb.CreateMongoProductBackend(session)
-	apiKeyBackend, _ := mongodb.CreateMongoAPIKeyBackend(session)
+	trashConf := viper.Sub("trash")
+	if retention := trashConf.GetDuration("retention"); retention > 0 {
+		interval := trashConf.GetDuration("purgeInterval")
+		if interval <= 0 {
+			log.Error("Invalid trash.purgeInterval", "purgeInterval", interval)
+			return
+		}
+		go purgeTrash(productBackend, retention, interval)
+	}
 
+	cursorSecret := serverConf.GetString("cursorSecret")
+	if cursorSecret == "" {
+		log.Warn("No cursorSecret, cursors are valid until apiserver stops")
+	}
 	ph := handler.CreateProductHandler(productBackend,
-		serverConf.GetInt("limitToRead"))
+		serverConf.GetInt("limitToRead"), []byte(cursorSecret))
+	graphqlConf := viper.Sub("graphql")
+	gh, err := handler.CreateGraphQLHandler(ph, graphqlConf.GetInt("maxDepth"),
+		graphqlConf.GetInt("maxComplexity"))
+	if err != nil {
+		log.Error("handler.CreateGraphQLHandler failed", "err", err.Error())
+		return
+	}
 
+	tm := middleware.CreateTimeoutMiddleWare(
+		serverConf.GetDuration("requestTimeout"))
 	am := middleware.CreateAPIKeyMiddleWare(apiKeyBackend)
+	rm := middleware.CreateRequestIDMiddleWare()
+	doc := openapi.CreateDocument()
+	om := middleware.CreateOpenAPIMiddleWare(doc)
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(handler.NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(handler.MethodNotAllowed)
+	// Requests are validated against doc once their routes are matched
+	r.Use(om.Handle)
+
+	// Search, with parameters "q" and optional "cursor" and "limit". It's
+	// routed before Read so that "search" is not taken as a productID.
+	r.Methods("GET").Path("/products/search").HandlerFunc(ph.HandleSearch)
 
 	// Read
 	r.Methods("GET").Path("/products/{productID}").HandlerFunc(ph.HandleGet)
-	// Read many, with optional parameters "cursor" and "limit"
+	// Read many, with optional parameters "cursor", "limit", "sort" and filters
 	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
 
 	// Create exclusively without productID in URI. However, the productID must be
@@ -106,8 +187,80 @@ func main() {
 	// Delete
 	r.Methods("DELETE").Path("/products/{productID}").HandlerFunc(ph.HandleDelete)
 
+	// Trashed products, restoring and purging one of them
+	r.Methods("GET").Path("/trash/").HandlerFunc(ph.HandleGetTrash)
+	r.Methods("POST").Path("/trash/{productID}:restore").
+		HandlerFunc(ph.HandleRestoreTrashed)
+	r.Methods("DELETE").Path("/trash/{productID}").
+		HandlerFunc(ph.HandlePurgeTrashed)
+
+	// Revisions, i.e. prior versions, of a product and restoring one of them
+	r.Methods("GET").Path("/products/{productID}/revisions").
+		HandlerFunc(ph.HandleGetRevisions)
+	r.Methods("GET").Path("/products/{productID}/revisions/{revision:[0-9]+}").
+		HandlerFunc(ph.HandleGetRevision)
+	r.Methods("POST").
+		Path("/products/{productID}/revisions/{revision:[0-9]+}:restore").
+		HandlerFunc(ph.HandleRestore)
+
+	// GraphQL, queries by GET or POST and mutations by POST
+	r.Methods("GET", "POST").Path("/graphql").HandlerFunc(gh.HandleGraphQL)
+
+	// Metrics, e.g. stats of cache
+	r.Methods("GET").Path("/debug/vars").Handler(expvar.Handler())
+
+	// The OpenAPI document and its docs page need no API keys, while other
+	// routes go through middlewares.
+	public := mux.NewRouter()
+	public.NotFoundHandler = alice.New(tm.Handle, am.Handle).Then(r)
+	public.MethodNotAllowedHandler = http.HandlerFunc(handler.MethodNotAllowed)
+	public.Methods("GET").Path("/openapi.json").Handler(doc)
+	if viper.Sub("openapi").GetBool("docs") {
+		public.Methods("GET").Path("/docs").
+			Handler(openapi.DocsHandler("/openapi.json"))
+	}
+	if err := doc.CheckRoutes(public, r); err != nil {
+		log.Error("doc.CheckRoutes failed", "err", err.Error())
+		return
+	}
+
 	// Chain middlewares and handler
-	stack := alice.New(am.Handle).Then(r)
+	stack := alice.New(rm.Handle).Then(public)
+
+	cert := serverConf.GetString("cert")
+	key := serverConf.GetString("key")
+	grpcConf := viper.Sub("grpc")
+	if grpcConf.GetBool("enabled") {
+		if port := grpcConf.GetInt("port"); port != 0 {
+			var opts []grpc.ServerOption
+			if cert != "" && key != "" {
+				creds, err := credentials.NewServerTLSFromFile(cert, key)
+				if err != nil {
+					log.Error("credentials.NewServerTLSFromFile failed",
+						"err", err.Error())
+					return
+				}
+				opts = append(opts, grpc.Creds(creds))
+			}
+			lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d",
+				serverConf.GetString("host"), port))
+			if err != nil {
+				log.Error("net.Listen failed", "err", err.Error())
+				return
+			}
+			gs := createGRPCServer(ph, tm, am, opts...)
+			defer gs.Stop()
+			go func() {
+				if err := gs.Serve(lis); err != nil {
+					log.Error("gRPC Serve failed", "err", err.Error())
+				}
+			}()
+		} else {
+			// Share the port with REST
+			stack = withGRPC(createGRPCServer(ph, tm, am), stack,
+				cert != "" && key != "")
+		}
+	}
 
 	server := http.Server{
 		Addr: fmt.Sprintf("%s:%d", serverConf.GetString("host"),
@@ -115,8 +268,6 @@ func main() {
 		Handler: stack,
 	}
 
-	cert := serverConf.GetString("cert")
-	key := serverConf.GetString("key")
 	if cert != "" && key != "" {
 		server.ListenAndServeTLS(cert, key)
 	} else {
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/middleware"
+	"github.com/cfchou/icecream/pkg/productpb"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"net/http"
+	"strings"
+)
+
+// createGRPCServer creates a gRPC server of ProductService, authenticated and
+// bounded by the same middlewares as REST.
+func createGRPCServer(ph *handler.ProductHandler, tm *middleware.TimeoutMiddleWare,
+	am *middleware.APIKeyMiddleWare, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(tm.UnaryInterceptor, am.UnaryInterceptor),
+		grpc.StreamInterceptor(am.StreamInterceptor))
+	s := grpc.NewServer(opts...)
+	productpb.RegisterProductServiceServer(s, handler.CreateProductService(ph))
+	return s
+}
+
+// withGRPC serves gRPC requests by s and others by h, so that they share a
+// port. Without TLS, HTTP/2 is served in cleartext(h2c) for gRPC.
+func withGRPC(s *grpc.Server, h http.Handler, tls bool) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(
+			r.Header.Get("Content-Type"), "application/grpc") {
+			s.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	if tls {
+		return http.HandlerFunc(f)
+	}
+	return h2c.NewHandler(http.HandlerFunc(f), &http2.Server{})
+}
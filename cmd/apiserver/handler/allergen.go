@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/pkg/errors"
+)
+
+// normalizeAllergens validates "allergens" of input written to backends. If
+// it's absent or null while "allergy_info" is given, allergens are parsed from
+// it. Allergy_info of the wrong type is left to backends to reject.
+func normalizeAllergens(input map[string]interface{}) error {
+	raw, ok := input["allergens"]
+	if !ok || raw == nil {
+		delete(input, "allergens")
+		if text, ok := input["allergy_info"].(string); ok {
+			input["allergens"] = model.ParseAllergyInfo(text)
+		}
+		return nil
+	}
+	var allergens model.Allergens
+	if err := remarshal(raw, &allergens); err != nil || !allergens.IsValid() {
+		return errors.New("invalid allergens")
+	}
+	allergens.Sort()
+	input["allergens"] = allergens
+	return nil
+}
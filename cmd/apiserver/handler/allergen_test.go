@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizeAllergens(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`{"name": "One"}`, `{"name": "One"}`},
+		{`{"allergy_info": "contains milk and eggs, may contain peanuts"}`,
+			`{"allergy_info": "contains milk and eggs, may contain peanuts",
+			"allergens": {"contains": ["milk", "eggs"],
+			"may_contain": ["peanuts"]}}`},
+		{`{"allergy_info": "", "allergens": null}`,
+			`{"allergy_info": "", "allergens": {"contains": [],
+			"may_contain": []}}`},
+		// Given ones are kept and sorted
+		{`{"allergy_info": "contains milk", "allergens": {
+			"contains": ["soy", "milk"]}}`,
+			`{"allergy_info": "contains milk", "allergens": {
+			"contains": ["milk", "soy"], "may_contain": []}}`},
+	}
+	for _, c := range cases {
+		var input map[string]interface{}
+		json.Unmarshal([]byte(c.input), &input)
+		if assert.NoError(t, normalizeAllergens(input), c.input) {
+			bs, _ := json.Marshal(input)
+			assert.JSONEq(t, c.expected, string(bs))
+		}
+	}
+
+	for _, s := range []string{
+		`{"allergens": "milk"}`,
+		`{"allergens": {"contains": ["nuts"]}}`,
+		`{"allergens": {"contains": ["milk"], "may_contain": ["milk"]}}`,
+	} {
+		var input map[string]interface{}
+		json.Unmarshal([]byte(s), &input)
+		assert.Error(t, normalizeAllergens(input), s)
+	}
+}
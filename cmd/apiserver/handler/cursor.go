@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// cursorMACSize is the number of bytes of the HMAC a cursor token carries.
+const cursorMACSize = 16
+
+var errCursor = errors.New("invalid cursor")
+
+// Pages other than those of products, which cursors are told apart by.
+const (
+	searchPage = "search"
+	trashPage  = "trash"
+)
+
+// cursorPayload is what a cursor token carries, i.e. a cursor of the backend,
+// the page it's for, empty for products, and the sort order.
+type cursorPayload struct {
+	Page   string     `json:"p,omitempty"`
+	Sort   model.Sort `json:"s,omitempty"`
+	Cursor string     `json:"c"`
+}
+
+// mac is the HMAC of payload by cursorSecret.
+func (h *ProductHandler) mac(payload []byte) []byte {
+	m := hmac.New(sha256.New, h.cursorSecret)
+	m.Write(payload)
+	return m.Sum(nil)[:cursorMACSize]
+}
+
+// encodeCursor makes cursor of the backend an opaque token for clients. The
+// token is signed so that a tampered one is rejected rather than read from
+// somewhere unexpected.
+func (h *ProductHandler) encodeCursor(order model.Sort, cursor string) string {
+	return h.sign(&cursorPayload{Sort: order, Cursor: cursor})
+}
+
+// decodeCursor verifies token made by encodeCursor and returns the cursor of
+// the backend. It fails if token is tampered or made for a different order.
+func (h *ProductHandler) decodeCursor(order model.Sort, token string) (string, error) {
+	p, err := h.verify(token)
+	if err != nil || p == nil {
+		return "", err
+	}
+	if p.Page != "" {
+		return "", errors.Wrap(errCursor, "of another page")
+	}
+	if p.Sort != order {
+		return "", errors.Wrap(errCursor, "sorted differently")
+	}
+	return p.Cursor, nil
+}
+
+// encodePageCursor is like encodeCursor but for cursors of page.
+func (h *ProductHandler) encodePageCursor(page string, cursor string) string {
+	return h.sign(&cursorPayload{Page: page, Cursor: cursor})
+}
+
+// decodePageCursor verifies token made by encodePageCursor and returns the
+// cursor of the backend. It fails if token is tampered or made for another
+// page.
+func (h *ProductHandler) decodePageCursor(page string, token string) (string, error) {
+	p, err := h.verify(token)
+	if err != nil || p == nil {
+		return "", err
+	}
+	if p.Page != page {
+		return "", errors.Wrap(errCursor, "of another page")
+	}
+	return p.Cursor, nil
+}
+
+// sign encodes p to a token, which is empty if the cursor of p is.
+func (h *ProductHandler) sign(p *cursorPayload) string {
+	if p.Cursor == "" {
+		return ""
+	}
+	payload, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(h.mac(payload))
+}
+
+// verify decodes token made by sign. The payload is nil if token is empty.
+func (h *ProductHandler) verify(token string) (*cursorPayload, error) {
+	if token == "" {
+		return nil, nil
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(mac, h.mac(payload)) {
+		return nil, errCursor
+	}
+	var p cursorPayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.Cursor == "" {
+		return nil, errCursor
+	}
+	return &p, nil
+}
+
+// pageURL is the absolute URL of r with its cursor replaced by cursor, or
+// removed if cursor is empty.
+func pageURL(r *http.Request, cursor string) string {
+	u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	qs := r.URL.Query()
+	qs.Del("cursor")
+	if cursor != "" {
+		qs.Set("cursor", cursor)
+	}
+	u.RawQuery = qs.Encode()
+	return u.String()
+}
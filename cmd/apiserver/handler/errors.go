@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// StatusCode maps an error returned by backends to a http status code. 4xx
+// means the request should not be retried as it is, while 503 and 504 mean
+// retrying may succeed.
+func StatusCode(err error) int {
+	switch errors.Cause(err) {
+	case backend.ErrNotFound:
+		// Not Found
+		return 404
+	case backend.ErrAlreadyExists, backend.ErrConflict:
+		// Conflict
+		return 409
+	case backend.ErrInvalidArgument:
+		// Bad Request
+		return 400
+	case backend.ErrPreconditionFailed:
+		// Precondition Failed
+		return 412
+	case backend.ErrUnavailable, context.Canceled:
+		// Service Unavailable
+		return 503
+	case context.DeadlineExceeded:
+		// Gateway Timeout
+		return 504
+	case backend.ErrNotImplemented:
+		// Not Implemented
+		return 501
+	}
+	if _, ok := errors.Cause(err).(invalidFields); ok {
+		// Bad Request
+		return 400
+	}
+	// Internal Server Error, including backend.ErrInconsistent
+	return 500
+}
+
+// GRPCCode maps an error returned by backends to a gRPC status code, like
+// StatusCode does to a http status code. Aborted, Unavailable and
+// DeadlineExceeded mean retrying may succeed.
+func GRPCCode(err error) codes.Code {
+	switch errors.Cause(err) {
+	case backend.ErrNotFound:
+		return codes.NotFound
+	case backend.ErrAlreadyExists:
+		return codes.AlreadyExists
+	case backend.ErrConflict:
+		return codes.Aborted
+	case backend.ErrInvalidArgument:
+		return codes.InvalidArgument
+	case backend.ErrPreconditionFailed:
+		return codes.FailedPrecondition
+	case backend.ErrUnavailable:
+		return codes.Unavailable
+	case context.Canceled:
+		return codes.Canceled
+	case context.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	case backend.ErrNotImplemented:
+		return codes.Unimplemented
+	}
+	if _, ok := errors.Cause(err).(invalidFields); ok {
+		return codes.InvalidArgument
+	}
+	// Including backend.ErrInconsistent
+	return codes.Internal
+}
+
+// GRPCError converts err to a gRPC status error of the code GRPCCode maps.
+func GRPCError(err error) error {
+	return status.Error(GRPCCode(err), err.Error())
+}
+
+// writeError writes the Problem of err. Errors of 5xx are logged rather than
+// written.
+func (h *ProductHandler) writeError(w http.ResponseWriter, r *http.Request,
+	err error) {
+	p := ProblemOf(err)
+	if p.Status >= 500 {
+		h.log.Error("Backend failed", "method", r.Method,
+			"url", r.URL.String(), "code", p.Status,
+			"request", RequestIDFrom(r.Context()), "err", err)
+	}
+	WriteProblem(w, r, p)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestStatusCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{backend.ErrNotFound, 404},
+		{backend.ErrAlreadyExists, 409},
+		{backend.ErrConflict, 409},
+		{backend.ErrInvalidArgument, 400},
+		{backend.ErrPreconditionFailed, 412},
+		{backend.ErrUnavailable, 503},
+		{backend.ErrInconsistent, 500},
+		{context.Canceled, 503},
+		{context.DeadlineExceeded, 504},
+		{backend.ErrNotImplemented, 501},
+		{fmt.Errorf("any error"), 500},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.code, StatusCode(c.err), "err:%v", c.err)
+		// Only the cause matters
+		assert.Equal(t, c.code, StatusCode(pe.Wrap(c.err, "wrapped")),
+			"err:%v", c.err)
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{backend.ErrNotFound, codes.NotFound},
+		{backend.ErrAlreadyExists, codes.AlreadyExists},
+		{backend.ErrConflict, codes.Aborted},
+		{backend.ErrInvalidArgument, codes.InvalidArgument},
+		{backend.ErrPreconditionFailed, codes.FailedPrecondition},
+		{backend.ErrUnavailable, codes.Unavailable},
+		{backend.ErrInconsistent, codes.Internal},
+		{context.Canceled, codes.Canceled},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{backend.ErrNotImplemented, codes.Unimplemented},
+		{fmt.Errorf("any error"), codes.Internal},
+	}
+	for _, c := range cases {
+		err := pe.Wrap(c.err, "wrapped")
+		assert.Equal(t, c.code, GRPCCode(err), "err:%v", c.err)
+		assert.Equal(t, c.code, status.Code(GRPCError(err)), "err:%v", c.err)
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// anyVersion is what "*" in If-Match means, i.e. any existing version.
+const anyVersion int64 = -1
+
+var errETag = errors.New("invalid entity tag")
+
+// formatETag formats version as a strong entity tag.
+func formatETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseETag parses a strong entity tag formatted by formatETag. Weak tags are
+// never matched in If-Match so they are rejected as well.
+func parseETag(tag string) (int64, error) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errors.Wrap(errETag, tag)
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errors.Wrap(errETag, tag)
+	}
+	return version, nil
+}
+
+// ifMatch parses the header If-Match. It returns 0 if the header is absent,
+// anyVersion if it's "*", otherwise the version of the only entity tag.
+func ifMatch(r *http.Request) (int64, error) {
+	values, ok := r.Header["If-Match"]
+	if !ok {
+		return 0, nil
+	}
+	value := strings.TrimSpace(strings.Join(values, ","))
+	if value == "*" {
+		return anyVersion, nil
+	}
+	if strings.Contains(value, ",") {
+		return 0, errors.Wrap(errETag, "only one entity tag is supported")
+	}
+	return parseETag(value)
+}
+
+// ifNoneMatchAny is true if the header If-None-Match is "*", i.e. the request
+// expects no existing version. Other entity tags are not supported.
+func ifNoneMatchAny(r *http.Request) (bool, error) {
+	values, ok := r.Header["If-None-Match"]
+	if !ok {
+		return false, nil
+	}
+	if strings.TrimSpace(strings.Join(values, ",")) != "*" {
+		return false, errors.Wrap(errETag, "only * is supported")
+	}
+	return true, nil
+}
+
+// conditionalError converts err of a conditional write. A precondition on a
+// product not existed fails as well, per RFC 7232.
+func conditionalError(err error) error {
+	if errors.Cause(err) == backend.ErrNotFound {
+		return errors.Wrap(backend.ErrPreconditionFailed, err.Error())
+	}
+	return err
+}
+
+// hashETag formats the sha1 of body as a strong entity tag. It's for
+// representations without a version, e.g. a page of products.
+func hashETag(body []byte) string {
+	return fmt.Sprintf(`"%x"`, sha1.Sum(body))
+}
+
+// notModified sets the headers ETag and Last-Modified, if given, and evaluates
+// If-None-Match or, in its absence, If-Modified-Since per RFC 7232. It writes
+// 304 and returns true if the client's copy is still fresh.
+func notModified(w http.ResponseWriter, r *http.Request, etag string,
+	lastModified time.Time) bool {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified",
+			lastModified.UTC().Format(http.TimeFormat))
+	}
+	if values, ok := r.Header["If-None-Match"]; ok {
+		if etag == "" || !noneMatch(strings.Join(values, ","), etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		// Last-Modified is only precise to seconds
+		if err != nil || lastModified.IsZero() ||
+			lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+	w.Header().Del("Content-Type")
+	// Not Modified
+	w.WriteHeader(304)
+	return true
+}
+
+// noneMatch is true if value of If-None-Match contains etag, by the weak
+// comparison, or is "*".
+func noneMatch(value, etag string) bool {
+	if strings.TrimSpace(value) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(value, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,728 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GraphQLHandler serves Product in GraphQL. It resolves through the
+// ProductBackend of a ProductHandler, with the same cursors, filters and
+// validation as the REST API.
+type GraphQLHandler struct {
+	log     log15.Logger
+	product *ProductHandler
+	schema  graphql.Schema
+	// maxDepth and maxComplexity limit queries, see queryCost. Those not
+	// larger than 0 are unlimited.
+	maxDepth      int
+	maxComplexity int
+}
+
+// graphQLRequest is a request POSTed in json.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// fieldError is an error of a field, with the http status code it'd have in
+// the REST API as "status" of "extensions".
+type fieldError struct {
+	error
+	status int
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *fieldError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"status": e.status}
+}
+
+// HandleGraphQL executes a request POSTed in json, or given by query
+// parameters "query", "operationName" and "variables" of GET. Mutations are
+// only POSTed. Requests not executed, e.g. malformed, invalid or exceeding
+// limits, fail with 400. Otherwise errors are of fields in the response.
+func (h *GraphQLHandler) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == "GET" {
+		qs := r.URL.Query()
+		req.Query = qs.Get("query")
+		req.OperationName = qs.Get("operationName")
+		if v := qs.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				h.writeErrors(w, 400, errors.New("invalid variables"))
+				return
+			}
+		}
+	} else {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			// Internal Server Error
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			h.writeErrors(w, 400, errors.New("invalid data"))
+			return
+		}
+	}
+	if req.Query == "" {
+		h.writeErrors(w, 400, errors.New("missing query"))
+		return
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		h.writeErrors(w, 400, err)
+		return
+	}
+	if v := graphql.ValidateDocument(&h.schema, doc, nil); !v.IsValid {
+		h.writeResult(w, 400, &graphql.Result{Errors: v.Errors})
+		return
+	}
+	op := operationOf(doc, req.OperationName)
+	if op == nil {
+		h.writeErrors(w, 400, errors.New("unknown operationName"))
+		return
+	}
+	if op.Operation == ast.OperationTypeMutation && r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		h.writeErrors(w, 405, errors.New("mutation must be POSTed"))
+		return
+	}
+	depth, complexity := h.queryCost(doc, op, req.Variables)
+	if h.maxDepth > 0 && depth > h.maxDepth {
+		h.writeErrors(w, 400, errors.New(fmt.Sprintf(
+			"query depth %d exceeds %d", depth, h.maxDepth)))
+		return
+	}
+	if h.maxComplexity > 0 && complexity > h.maxComplexity {
+		h.writeErrors(w, 400, errors.New(fmt.Sprintf(
+			"query complexity exceeds %d", h.maxComplexity)))
+		return
+	}
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       r.Context(),
+	})
+	h.writeResult(w, 200, result)
+}
+
+// writeErrors writes code and a result of errs.
+func (h *GraphQLHandler) writeErrors(w http.ResponseWriter, code int, errs ...error) {
+	h.writeResult(w, code, &graphql.Result{
+		Errors: gqlerrors.FormatErrors(errs...)})
+}
+
+func (h *GraphQLHandler) writeResult(w http.ResponseWriter, code int, result *graphql.Result) {
+	bs, err := json.Marshal(result)
+	if err != nil {
+		// Internal Server Error
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(bs)
+}
+
+// operationOf finds the operation of doc named name, which may be empty if
+// doc has only one operation.
+func operationOf(doc *ast.Document, name string) *ast.OperationDefinition {
+	var ret *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if ret != nil {
+				return nil
+			}
+			ret = op
+		} else if op.Name != nil && op.Name.Value == name {
+			return op
+		}
+	}
+	return ret
+}
+
+// queryCost measures op. Depth is the number of nested fields. Complexity is
+// the number of fields, where those under a field taking "limit" count for
+// every Product of the page. Introspection isn't measured.
+func (h *GraphQLHandler) queryCost(doc *ast.Document, op *ast.OperationDefinition, variables map[string]interface{}) (int, int) {
+	c := costCounter{
+		schema:    &h.schema,
+		limit:     h.product.limitToRead,
+		variables: variables,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		costs:     make(map[string][2]int),
+	}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			c.fragments[f.Name.Value] = f
+		}
+	}
+	var root graphql.Named = h.schema.QueryType()
+	if op.Operation == ast.OperationTypeMutation {
+		root = h.schema.MutationType()
+	}
+	return c.selectionSet(root, op.SelectionSet)
+}
+
+// maxCost caps complexity so that it doesn't overflow.
+const maxCost = 1 << 29
+
+// costCounter measures selection sets of a validated document, so fragments
+// are known and not cyclic. Costs of fragments are memorized so that spreading
+// them repeatedly doesn't take exponential time.
+type costCounter struct {
+	schema    *graphql.Schema
+	limit     int
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+	costs     map[string][2]int
+}
+
+func (c *costCounter) selectionSet(parent graphql.Named, set *ast.SelectionSet) (depth int, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, s := range set.Selections {
+		var d, n int
+		switch s := s.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			def := fieldDefinition(parent, s.Name.Value)
+			if def == nil {
+				continue
+			}
+			d, n = c.selectionSet(graphql.GetNamed(def.Type), s.SelectionSet)
+			if size := c.pageSize(def, s); n < maxCost/size {
+				n = 1 + n*size
+			} else {
+				n = maxCost
+			}
+			d++
+		case *ast.InlineFragment:
+			t := parent
+			if s.TypeCondition != nil {
+				t = c.schema.Type(s.TypeCondition.Name.Value)
+			}
+			d, n = c.selectionSet(t, s.SelectionSet)
+		case *ast.FragmentSpread:
+			cost, ok := c.costs[s.Name.Value]
+			if f := c.fragments[s.Name.Value]; !ok && f != nil {
+				cost[0], cost[1] = c.selectionSet(
+					c.schema.Type(f.TypeCondition.Name.Value), f.SelectionSet)
+				c.costs[s.Name.Value] = cost
+			}
+			d, n = cost[0], cost[1]
+		}
+		if d > depth {
+			depth = d
+		}
+		if complexity += n; complexity > maxCost {
+			complexity = maxCost
+		}
+	}
+	return depth, complexity
+}
+
+// pageSize is the number of Products field reads if def takes "limit", or 1.
+func (c *costCounter) pageSize(def *graphql.FieldDefinition, field *ast.Field) int {
+	takesLimit := false
+	for _, arg := range def.Args {
+		takesLimit = takesLimit || arg.Name() == "limit"
+	}
+	if !takesLimit {
+		return 1
+	}
+	n := c.limit
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if i, err := strconv.Atoi(v.Value); err == nil {
+				n = i
+			}
+		case *ast.Variable:
+			if f, ok := c.variables[v.Name.Value].(float64); ok {
+				n = int(f)
+			}
+		}
+	}
+	if n < 1 || n > c.limit {
+		return c.limit
+	}
+	return n
+}
+
+// fieldDefinition finds the field named name of parent, or nil if parent
+// has no fields.
+func fieldDefinition(parent graphql.Named, name string) *graphql.FieldDefinition {
+	switch t := parent.(type) {
+	case *graphql.Object:
+		return t.Fields()[name]
+	case *graphql.Interface:
+		return t.Fields()[name]
+	}
+	return nil
+}
+
+// resolveError converts err returned by the backend.
+func (h *GraphQLHandler) resolveError(err error) error {
+	code := StatusCode(err)
+	if code >= 500 {
+		h.log.Error("Backend failed", "code", code, "err", err)
+	}
+	return &fieldError{error: err, status: code}
+}
+
+// badRequest is err of arguments.
+func badRequest(err error) error {
+	return &fieldError{error: err, status: 400}
+}
+
+// versionOf is the argument "version", or 0 if it's absent.
+func versionOf(args map[string]interface{}) (int64, error) {
+	v, ok := args["version"].(int)
+	if !ok {
+		return 0, nil
+	}
+	if v < 1 {
+		return 0, badRequest(errors.New("invalid version"))
+	}
+	return int64(v), nil
+}
+
+// readBack reads the Product written by a mutation.
+func (h *GraphQLHandler) readBack(ctx context.Context, productID string) (interface{}, error) {
+	product, err := h.product.backend.Read(ctx, productID)
+	if err != nil {
+		return nil, h.resolveError(err)
+	}
+	return product, nil
+}
+
+func (h *GraphQLHandler) resolveProduct(p graphql.ResolveParams) (interface{}, error) {
+	product, err := h.product.backend.Read(p.Context, p.Args["productId"].(string))
+	if errors.Cause(err) == backend.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, h.resolveError(err)
+	}
+	return product, nil
+}
+
+// productPage is a page of Products with the filter selecting them, which
+// is counted only if the total is selected.
+type productPage struct {
+	*model.Products
+	filter *model.Filter
+}
+
+func (h *GraphQLHandler) resolveProducts(p graphql.ResolveParams) (interface{}, error) {
+	order := model.Sort(stringArg(p.Args, "sort"))
+	if !order.IsValid() {
+		return nil, badRequest(errors.New("invalid sort"))
+	}
+	cursor, err := h.product.decodeCursor(order, stringArg(p.Args, "cursor"))
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	limit := h.product.limitToRead
+	if v, ok := p.Args["limit"].(int); ok {
+		if limit, err = h.product.limitOf(strconv.Itoa(v)); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	var filter model.Filter
+	if v, ok := p.Args["filter"]; ok {
+		if err := remarshal(v, &filter); err != nil {
+			return nil, badRequest(errors.New("invalid filter"))
+		}
+		for _, list := range [][]string{filter.Ingredients,
+			filter.WithoutIngredients, filter.SourcingValues,
+			filter.WithoutSourcingValues, filter.DietaryCertifications,
+			filter.WithoutDietaryCertifications} {
+			for _, v := range list {
+				if v == "" {
+					return nil, badRequest(errors.New("invalid filter"))
+				}
+			}
+		}
+	}
+	mps, err := h.product.backend.ReadMany(p.Context, cursor, limit, &filter,
+		order)
+	if err != nil {
+		return nil, h.resolveError(err)
+	}
+	mps.Cursor = h.product.encodeCursor(order, mps.Cursor)
+	return &productPage{Products: mps, filter: &filter}, nil
+}
+
+func (h *GraphQLHandler) resolveTotal(p graphql.ResolveParams) (interface{}, error) {
+	counter, ok := h.product.backend.(ProductCounter)
+	if !ok {
+		return nil, h.resolveError(errors.WithStack(backend.ErrNotImplemented))
+	}
+	total, err := counter.Count(p.Context, p.Source.(*productPage).filter)
+	if err != nil {
+		return nil, h.resolveError(err)
+	}
+	return total, nil
+}
+
+// productOf validates the argument "product" like the body of HandlePut.
+func productOf(args map[string]interface{}) (*model.Product, error) {
+	bs, err := json.Marshal(args["product"])
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	product, err := validateProductFieldsAllPresented(bs)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	return product, nil
+}
+
+func (h *GraphQLHandler) resolveCreate(p graphql.ResolveParams) (interface{}, error) {
+	product, err := productOf(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.product.backend.Create(p.Context, product); err != nil {
+		return nil, h.resolveError(err)
+	}
+	return h.readBack(p.Context, product.ProductID)
+}
+
+func (h *GraphQLHandler) resolveUpsert(p graphql.ResolveParams) (interface{}, error) {
+	product, err := productOf(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	version, err := versionOf(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 {
+		err = conditionalError(h.product.backend.UpdateIfMatch(p.Context,
+			product, version))
+	} else {
+		err = h.product.backend.Upsert(p.Context, product)
+	}
+	if err != nil {
+		return nil, h.resolveError(err)
+	}
+	return h.readBack(p.Context, product.ProductID)
+}
+
+func (h *GraphQLHandler) resolvePatch(p graphql.ResolveParams) (interface{}, error) {
+	productID := p.Args["productId"].(string)
+	var input map[string]interface{}
+	if err := remarshal(p.Args["patch"], &input); err != nil {
+		return nil, badRequest(err)
+	}
+	if err := normalizeIngredients(input); err != nil {
+		return nil, badRequest(err)
+	}
+	if err := normalizeAllergens(input); err != nil {
+		return nil, badRequest(err)
+	}
+	version, err := versionOf(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 {
+		err = conditionalError(h.product.backend.UpdatePartialIfMatch(
+			p.Context, productID, input, version))
+	} else {
+		err = h.product.backend.UpdatePartial(p.Context, productID, input)
+	}
+	if err != nil {
+		return nil, h.resolveError(err)
+	}
+	return h.readBack(p.Context, productID)
+}
+
+func (h *GraphQLHandler) resolveDelete(p graphql.ResolveParams) (interface{}, error) {
+	productID := p.Args["productId"].(string)
+	version, err := versionOf(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 {
+		err = conditionalError(h.product.backend.DeleteIfMatch(p.Context,
+			productID, version))
+	} else {
+		err = h.product.backend.Delete(p.Context, productID)
+	}
+	if err != nil {
+		return nil, h.resolveError(err)
+	}
+	return true, nil
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// createSchema creates the schema, which names fields of Product as in json.
+func (h *GraphQLHandler) createSchema() (graphql.Schema, error) {
+	nonNullString := graphql.NewNonNull(graphql.String)
+	stringList := graphql.NewList(nonNullString)
+
+	allergenValues := graphql.EnumValueConfigMap{}
+	for _, a := range model.MajorAllergens {
+		allergenValues[string(a)] = &graphql.EnumValueConfig{Value: a}
+	}
+	allergenType := graphql.NewEnum(graphql.EnumConfig{
+		Name:   "Allergen",
+		Values: allergenValues,
+	})
+	allergens := graphql.NewList(graphql.NewNonNull(allergenType))
+
+	ingredientType := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "Ingredient",
+		Fields: graphql.Fields{"name": {Type: nonNullString}},
+	})
+	ingredientType.AddFieldConfig("ingredients", &graphql.Field{
+		Type: graphql.NewList(graphql.NewNonNull(ingredientType)),
+	})
+	var ingredientInput *graphql.InputObject
+	ingredientInput = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "IngredientInput",
+		Fields: graphql.InputObjectConfigFieldMapThunk(
+			func() graphql.InputObjectConfigFieldMap {
+				return graphql.InputObjectConfigFieldMap{
+					"name": {Type: nonNullString},
+					"ingredients": {Type: graphql.NewList(
+						graphql.NewNonNull(ingredientInput))},
+				}
+			}),
+	})
+	allergensType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Allergens",
+		Fields: graphql.Fields{
+			"contains":    {Type: allergens},
+			"may_contain": {Type: allergens},
+		},
+	})
+	allergensInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "AllergensInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"contains":    {Type: allergens},
+			"may_contain": {Type: allergens},
+		},
+	})
+
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"productId":              {Type: nonNullString},
+			"name":                   {Type: nonNullString},
+			"image_closed":           {Type: nonNullString},
+			"image_open":             {Type: nonNullString},
+			"description":            {Type: nonNullString},
+			"story":                  {Type: nonNullString},
+			"sourcing_values":        {Type: stringList},
+			"ingredients":            {Type: stringList},
+			"structured_ingredients": {Type: graphql.NewList(graphql.NewNonNull(ingredientType))},
+			"allergy_info":           {Type: nonNullString},
+			"dietary_certifications": {Type: nonNullString},
+			"allergens":              {Type: allergensType},
+			"created_at":             {Type: graphql.DateTime},
+			"updated_at":             {Type: graphql.DateTime},
+			"version": {
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "The version, which is the ETag in REST.",
+			},
+		},
+	})
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductPage",
+		Fields: graphql.Fields{
+			"products": {
+				Type: graphql.NewNonNull(graphql.NewList(
+					graphql.NewNonNull(productType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*productPage).Products.Products, nil
+				},
+			},
+			"cursor": {
+				Type:        graphql.String,
+				Description: "Absent on the last page.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if c := p.Source.(*productPage).Cursor; c != "" {
+						return c, nil
+					}
+					return nil, nil
+				},
+			},
+			"total": {
+				Type:        graphql.Int,
+				Description: "The number of Products on all pages.",
+				Resolve:     h.resolveTotal,
+			},
+		},
+	})
+	filterInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ProductFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"ingredients":                    {Type: stringList},
+			"without_ingredients":            {Type: stringList},
+			"sourcing_values":                {Type: stringList},
+			"without_sourcing_values":        {Type: stringList},
+			"dietary_certifications":         {Type: stringList},
+			"without_dietary_certifications": {Type: stringList},
+			"name_prefix":                    {Type: graphql.String},
+			"free_from":                      {Type: allergens},
+		},
+	})
+
+	// Fields of ProductInput, those required are non-null. Ingredients may
+	// be replaced by structured_ingredients.
+	productFields := func(required bool) graphql.InputObjectConfigFieldMap {
+		s, list := graphql.Input(graphql.String), graphql.Input(stringList)
+		if required {
+			s, list = nonNullString, graphql.NewNonNull(stringList)
+		}
+		return graphql.InputObjectConfigFieldMap{
+			"name":                   {Type: s},
+			"image_closed":           {Type: s},
+			"image_open":             {Type: s},
+			"description":            {Type: s},
+			"story":                  {Type: s},
+			"sourcing_values":        {Type: list},
+			"ingredients":            {Type: stringList},
+			"structured_ingredients": {Type: graphql.NewList(graphql.NewNonNull(ingredientInput))},
+			"allergy_info":           {Type: s},
+			"dietary_certifications": {Type: s},
+			"allergens":              {Type: allergensInput},
+		}
+	}
+	inputFields := productFields(true)
+	inputFields["productId"] = &graphql.InputObjectFieldConfig{Type: nonNullString}
+	productInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   "ProductInput",
+		Fields: inputFields,
+	})
+	patchInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   "ProductPatch",
+		Fields: productFields(false),
+	})
+
+	productID := &graphql.ArgumentConfig{Type: nonNullString}
+	version := &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: "Only if the Product is of the version, like If-Match.",
+	}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": {
+				Type:        productType,
+				Description: "Null if not found.",
+				Args:        graphql.FieldConfigArgument{"productId": productID},
+				Resolve:     h.resolveProduct,
+			},
+			"products": {
+				Type:        graphql.NewNonNull(pageType),
+				Description: "A page of Products like GET /products/.",
+				Args: graphql.FieldConfigArgument{
+					"cursor": {Type: graphql.String},
+					"limit":  {Type: graphql.Int},
+					"sort":   {Type: graphql.String},
+					"filter": {Type: filterInput},
+				},
+				Resolve: h.resolveProducts,
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createProduct": {
+				Type:        graphql.NewNonNull(productType),
+				Description: "Exclusively creates a Product like POST.",
+				Args: graphql.FieldConfigArgument{
+					"product": {Type: graphql.NewNonNull(productInput)},
+				},
+				Resolve: h.resolveCreate,
+			},
+			"upsertProduct": {
+				Type:        graphql.NewNonNull(productType),
+				Description: "Creates or replaces a Product like PUT.",
+				Args: graphql.FieldConfigArgument{
+					"product": {Type: graphql.NewNonNull(productInput)},
+					"version": version,
+				},
+				Resolve: h.resolveUpsert,
+			},
+			"patchProduct": {
+				Type:        graphql.NewNonNull(productType),
+				Description: "Updates fields of a Product like PATCH.",
+				Args: graphql.FieldConfigArgument{
+					"productId": productID,
+					"patch":     {Type: graphql.NewNonNull(patchInput)},
+					"version":   version,
+				},
+				Resolve: h.resolvePatch,
+			},
+			"deleteProduct": {
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Moves a Product to the trash like DELETE.",
+				Args: graphql.FieldConfigArgument{
+					"productId": productID,
+					"version":   version,
+				},
+				Resolve: h.resolveDelete,
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+// CreateGraphQLHandler creates GraphQLHandler resolving through productHandler.
+// MaxDepth and maxComplexity limit queries, those not larger than 0 are
+// unlimited.
+func CreateGraphQLHandler(productHandler *ProductHandler, maxDepth, maxComplexity int) (*GraphQLHandler, error) {
+	h := &GraphQLHandler{
+		log:           log15.New("module", "handler.graphql"),
+		product:       productHandler,
+		maxDepth:      maxDepth,
+		maxComplexity: maxComplexity,
+	}
+	schema, err := h.createSchema()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	h.schema = schema
+	return h, nil
+}
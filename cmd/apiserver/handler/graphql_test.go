@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// postGraphQL POSTs query with variables to h.
+func postGraphQL(h *GraphQLHandler, query string, variables map[string]interface{}) *httptest.ResponseRecorder {
+	bs, _ := json.Marshal(&graphQLRequest{Query: query, Variables: variables})
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "/graphql", bytes.NewBuffer(bs))
+	h.HandleGraphQL(writer, request)
+	return writer
+}
+
+func TestGraphQLHandler_HandleGraphQL_Product(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("Read", mock.Anything, "001").Return(&model.Product{
+		ProductID: "001", Name: "One", Story: "story", Version: 3}, nil)
+	mB.On("Read", mock.Anything, "002").
+		Return(nil, pe.WithStack(backend.ErrNotFound))
+	mB.On("Read", mock.Anything, "003").
+		Return(nil, pe.WithStack(backend.ErrUnavailable))
+	gh, err := CreateGraphQLHandler(CreateProductHandler(mB, 10, nil), 10, 100)
+	assert.NoError(t, err)
+
+	// Only fields selected
+	writer := postGraphQL(gh, `{ product(productId: "001") { name version } }`,
+		nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.JSONEq(t, `{"data": {"product": {"name": "One", "version": 3}}}`,
+		writer.Body.String())
+
+	writer = postGraphQL(gh, `query($id: String!) { product(productId: $id) {
+		name } }`, map[string]interface{}{"id": "002"})
+	assert.Equal(t, 200, writer.Code)
+	assert.JSONEq(t, `{"data": {"product": null}}`, writer.Body.String())
+
+	writer = postGraphQL(gh, `{ product(productId: "003") { name } }`, nil)
+	assert.Equal(t, 200, writer.Code)
+	var result struct {
+		Errors []struct {
+			Extensions map[string]int `json:"extensions"`
+		} `json:"errors"`
+	}
+	json.Unmarshal(writer.Body.Bytes(), &result)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, 503, result.Errors[0].Extensions["status"])
+	}
+
+	// Queries by GET
+	writer = httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/graphql?query="+
+		url.QueryEscape(`{ product(productId: "001") { productId } }`), nil)
+	gh.HandleGraphQL(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	assert.JSONEq(t, `{"data": {"product": {"productId": "001"}}}`,
+		writer.Body.String())
+}
+
+func TestGraphQLHandler_HandleGraphQL_Products(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+	mC := &mocks.ProductCounter{}
+
+	filter := &model.Filter{Ingredients: []string{"cream"},
+		FreeFrom: []model.Allergen{model.Peanuts}}
+	mB.On("ReadMany", mock.Anything, "", 2, filter, model.Sort("-name")).
+		Return(&model.Products{Cursor: "c", Products: []model.Product{
+			{ProductID: "002"}, {ProductID: "001"}}}, nil)
+	mB.On("ReadMany", mock.Anything, "c", 2, filter, model.Sort("-name")).
+		Return(&model.Products{Products: []model.Product{}}, nil)
+	mC.On("Count", mock.Anything, filter).Return(int64(2), nil)
+	ph := CreateProductHandler(countableBackend{mB, mC}, 10, nil)
+	gh, _ := CreateGraphQLHandler(ph, 10, 100)
+
+	query := `query($cursor: String) { products(cursor: $cursor, limit: 2,
+		sort: "-name", filter: {ingredients: ["cream"], free_from: [peanuts]}) {
+		cursor total products { productId } } }`
+	writer := postGraphQL(gh, query, nil)
+	assert.Equal(t, 200, writer.Code)
+	var result struct {
+		Data struct {
+			Products struct {
+				Cursor   *string                  `json:"cursor"`
+				Total    int                      `json:"total"`
+				Products []map[string]interface{} `json:"products"`
+			} `json:"products"`
+		} `json:"data"`
+	}
+	json.Unmarshal(writer.Body.Bytes(), &result)
+	page := result.Data.Products
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, []map[string]interface{}{{"productId": "002"},
+		{"productId": "001"}}, page.Products)
+	if assert.NotNil(t, page.Cursor) {
+		assert.Equal(t, ph.encodeCursor(model.Sort("-name"), "c"),
+			*page.Cursor)
+		// The last page has no cursor
+		writer = postGraphQL(gh, query,
+			map[string]interface{}{"cursor": *page.Cursor})
+		assert.JSONEq(t, `{"data": {"products": {"cursor": null, "total": 2,
+			"products": []}}}`, writer.Body.String())
+	}
+
+	// Counted only if the total is selected
+	mC.AssertNumberOfCalls(t, "Count", 2)
+	postGraphQL(gh, `{ products(limit: 2, sort: "-name", filter: {
+		ingredients: ["cream"], free_from: [peanuts]}) { cursor } }`, nil)
+	mC.AssertNumberOfCalls(t, "Count", 2)
+
+	for _, query := range []string{
+		`{ products(sort: "price") { cursor } }`,
+		`{ products(cursor: "c") { cursor } }`,
+		`{ products(limit: 0) { cursor } }`,
+		`{ products(filter: {ingredients: [""]}) { cursor } }`,
+	} {
+		writer = postGraphQL(gh, query, nil)
+		assert.Contains(t, writer.Body.String(), `"status":400`, query)
+	}
+}
+
+func TestGraphQLHandler_HandleGraphQL_Mutations(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	product := &model.Product{ProductID: "001", Name: "One", Version: 2}
+	mB.On("Read", mock.Anything, "001").Return(product, nil)
+	mB.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mB.On("UpdatePartialIfMatch", mock.Anything, "001", mock.Anything,
+		int64(1)).Return(nil)
+	mB.On("Delete", mock.Anything, "001").Return(nil)
+	gh, _ := CreateGraphQLHandler(CreateProductHandler(mB, 10, nil), 10, 100)
+
+	writer := postGraphQL(gh, `mutation { createProduct(product: {
+		productId: "001", name: "One", image_closed: "", image_open: "",
+		description: "", story: "", sourcing_values: [],
+		structured_ingredients: [{name: "butter", ingredients: [
+		{name: "cream"}, {name: "salt"}]}], allergy_info: "contains milk",
+		dietary_certifications: ""}) { productId version } }`, nil)
+	assert.Equal(t, 200, writer.Code)
+	assert.JSONEq(t, `{"data": {"createProduct": {"productId": "001",
+		"version": 2}}}`, writer.Body.String())
+	mB.AssertCalled(t, "Create", mock.Anything,
+		mock.MatchedBy(func(p *model.Product) bool {
+			return assert.ObjectsAreEqual([]string{"butter (cream, salt)"},
+				p.Ingredients) && p.Allergens.Has(model.Milk)
+		}))
+
+	writer = postGraphQL(gh, `mutation { patchProduct(productId: "001",
+		patch: {name: "Two", allergens: {contains: [soy, milk]}}, version: 1) {
+		name } }`, nil)
+	assert.Equal(t, 200, writer.Code)
+	mB.AssertCalled(t, "UpdatePartialIfMatch", mock.Anything, "001",
+		map[string]interface{}{"name": "Two", "allergens": model.Allergens{
+			Contains:   []model.Allergen{model.Milk, model.Soy},
+			MayContain: []model.Allergen{},
+		}}, int64(1))
+
+	writer = postGraphQL(gh, `mutation { deleteProduct(productId: "001") }`,
+		nil)
+	assert.JSONEq(t, `{"data": {"deleteProduct": true}}`, writer.Body.String())
+
+	// Invalid, e.g. missing fields
+	writer = postGraphQL(gh, `mutation { createProduct(product: {
+		productId: "001", name: "One", image_closed: "", image_open: "",
+		description: "", story: "", sourcing_values: [], allergy_info: "",
+		dietary_certifications: ""}) { productId } }`, nil)
+	assert.Contains(t, writer.Body.String(), `"status":400`)
+	mB.AssertNumberOfCalls(t, "Create", 1)
+
+	// Mutations by GET
+	writer = httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/graphql?query="+
+		url.QueryEscape(`mutation { deleteProduct(productId: "001") }`), nil)
+	gh.HandleGraphQL(writer, request)
+	assert.Equal(t, 405, writer.Code)
+	mB.AssertNumberOfCalls(t, "Delete", 1)
+}
+
+func TestGraphQLHandler_HandleGraphQL_400(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+	gh, _ := CreateGraphQLHandler(CreateProductHandler(mB, 10, nil), 4, 50)
+
+	for _, query := range []string{
+		``,
+		`{ product(productId: "001") { name }`,
+		`{ product(productId: "001") { price } }`,
+		// Too deep
+		`{ products { products { structured_ingredients { ingredients {
+			ingredients { name } } } } } }`,
+		`fragment i on Ingredient { ingredients { ingredients { name } } }
+		{ products { products { structured_ingredients { ...i } } } }`,
+		// Too complex, 1 + 10 * (1 + 5)
+		`{ products { products { productId name story description
+			allergy_info } } }`,
+		`query($n: Int) { products(limit: $n) { products { productId name
+			story description allergy_info } } }`,
+	} {
+		writer := postGraphQL(gh, query, nil)
+		assert.Equal(t, 400, writer.Code, query)
+	}
+	mB.AssertNotCalled(t, "ReadMany", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything)
+
+	// 1 + 8 * (1 + 5)
+	mB.On("ReadMany", mock.Anything, "", 8, &model.Filter{}, model.Sort("")).
+		Return(&model.Products{Products: []model.Product{}}, nil)
+	writer := postGraphQL(gh, `query($n: Int) { products(limit: $n) {
+		products { productId name story description allergy_info } } }`,
+		map[string]interface{}{"n": 8})
+	assert.Equal(t, 200, writer.Code)
+}
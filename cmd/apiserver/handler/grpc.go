@@ -0,0 +1,376 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/cfchou/icecream/pkg/productpb"
+	"github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strconv"
+	"strings"
+)
+
+// ProductService implements productpb.ProductServiceServer. It reads and
+// writes through the ProductBackend of a ProductHandler, with the same
+// cursors, filters and validation as the REST API.
+type ProductService struct {
+	productpb.UnimplementedProductServiceServer
+	log     log15.Logger
+	product *ProductHandler
+}
+
+// fieldsUpdatable are names of fields of productpb.Product in update_mask.
+var fieldsUpdatable = map[string]bool{
+	"name": true, "image_closed": true, "image_open": true,
+	"description": true, "story": true, "sourcing_values": true,
+	"ingredients": true, "allergy_info": true, "dietary_certifications": true,
+	"allergens": true, "structured_ingredients": true,
+}
+
+// GetProduct reads a Product like HandleGet.
+func (s *ProductService) GetProduct(ctx context.Context, req *productpb.GetProductRequest) (*productpb.Product, error) {
+	product, err := s.product.backend.Read(ctx, req.ProductId)
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return productMessage(product), nil
+}
+
+// ListProducts streams pages of products like HandleGetMany, from the cursor
+// if given until the last page. Empty pages are not streamed.
+func (s *ProductService) ListProducts(req *productpb.ListProductsRequest, stream productpb.ProductService_ListProductsServer) error {
+	order := model.Sort(req.Sort)
+	if !order.IsValid() {
+		return invalidArgument(errors.New("invalid sort"))
+	}
+	cursor, err := s.product.decodeCursor(order, req.Cursor)
+	if err != nil {
+		return invalidArgument(err)
+	}
+	limit := s.product.limitToRead
+	if req.PageSize != 0 {
+		limit, err = s.product.limitOf(strconv.Itoa(int(req.PageSize)))
+		if err != nil {
+			return invalidArgument(err)
+		}
+	}
+	filter, err := filterOfMessage(req.Filter)
+	if err != nil {
+		return invalidArgument(err)
+	}
+	for {
+		mps, err := s.product.backend.ReadMany(stream.Context(), cursor, limit,
+			filter, order)
+		if err != nil {
+			return s.error(err)
+		}
+		if len(mps.Products) > 0 {
+			page := &productpb.Products{
+				Cursor: s.product.encodeCursor(order, mps.Cursor),
+			}
+			for i := range mps.Products {
+				page.Products = append(page.Products,
+					productMessage(&mps.Products[i]))
+			}
+			if err := stream.Send(page); err != nil {
+				return err
+			}
+		}
+		if mps.Cursor == "" {
+			return nil
+		}
+		cursor = mps.Cursor
+	}
+}
+
+// CreateProduct exclusively creates a Product like HandlePost.
+func (s *ProductService) CreateProduct(ctx context.Context, req *productpb.CreateProductRequest) (*productpb.Product, error) {
+	product, err := productOfMessage(req.Product)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if err := s.product.backend.Create(ctx, product); err != nil {
+		return nil, s.error(err)
+	}
+	return s.readBack(ctx, product.ProductID)
+}
+
+// UpsertProduct creates or replaces a Product like HandlePut. With a
+// version, it only replaces the Product of the version.
+func (s *ProductService) UpsertProduct(ctx context.Context, req *productpb.UpsertProductRequest) (*productpb.Product, error) {
+	product, err := productOfMessage(req.Product)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	switch {
+	case req.Version < 0:
+		return nil, invalidArgument(errors.New("invalid version"))
+	case req.Version > 0:
+		err = conditionalError(s.product.backend.UpdateIfMatch(ctx, product,
+			req.Version))
+	default:
+		err = s.product.backend.Upsert(ctx, product)
+	}
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return s.readBack(ctx, product.ProductID)
+}
+
+// UpdateProduct updates fields of a Product in update_mask like HandlePatch.
+// With a version, it only updates the Product of the version.
+func (s *ProductService) UpdateProduct(ctx context.Context, req *productpb.UpdateProductRequest) (*productpb.Product, error) {
+	paths := req.UpdateMask.GetPaths()
+	if len(paths) == 0 {
+		return nil, invalidArgument(errors.New("missing update_mask"))
+	}
+	product := req.Product
+	if product == nil {
+		product = &productpb.Product{}
+	}
+	fields, err := inputOfMessage(product)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	input := make(map[string]interface{})
+	for _, path := range paths {
+		if !fieldsUpdatable[path] {
+			return nil, invalidArgument(errors.New("invalid update_mask"))
+		}
+		if v, ok := fields[path]; ok {
+			input[path] = v
+		}
+	}
+	if err := normalizeIngredients(input); err != nil {
+		return nil, invalidArgument(err)
+	}
+	if err := normalizeAllergens(input); err != nil {
+		return nil, invalidArgument(err)
+	}
+	switch {
+	case req.Version < 0:
+		return nil, invalidArgument(errors.New("invalid version"))
+	case req.Version > 0:
+		err = conditionalError(s.product.backend.UpdatePartialIfMatch(ctx,
+			req.ProductId, input, req.Version))
+	default:
+		err = s.product.backend.UpdatePartial(ctx, req.ProductId, input)
+	}
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return s.readBack(ctx, req.ProductId)
+}
+
+// DeleteProduct moves a Product to the trash like HandleDelete. With a
+// version, it only deletes the Product of the version.
+func (s *ProductService) DeleteProduct(ctx context.Context, req *productpb.DeleteProductRequest) (*emptypb.Empty, error) {
+	var err error
+	switch {
+	case req.Version < 0:
+		return nil, invalidArgument(errors.New("invalid version"))
+	case req.Version > 0:
+		err = conditionalError(s.product.backend.DeleteIfMatch(ctx,
+			req.ProductId, req.Version))
+	default:
+		err = s.product.backend.Delete(ctx, req.ProductId)
+	}
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// readBack reads the Product written.
+func (s *ProductService) readBack(ctx context.Context, productID string) (*productpb.Product, error) {
+	product, err := s.product.backend.Read(ctx, productID)
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return productMessage(product), nil
+}
+
+// error converts err returned by the backend.
+func (s *ProductService) error(err error) error {
+	if code := StatusCode(err); code >= 500 {
+		s.log.Error("Backend failed", "code", code, "err", err)
+	}
+	return GRPCError(err)
+}
+
+func invalidArgument(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// allergenMessage converts a, which is valid, e.g. "tree_nuts" to TREE_NUTS.
+func allergenMessage(a model.Allergen) productpb.Allergen {
+	return productpb.Allergen(productpb.Allergen_value[strings.ToUpper(
+		string(a))])
+}
+
+func allergensMessage(allergens []model.Allergen) []productpb.Allergen {
+	ret := make([]productpb.Allergen, 0, len(allergens))
+	for _, a := range allergens {
+		ret = append(ret, allergenMessage(a))
+	}
+	return ret
+}
+
+// allergensOf converts allergens back. ALLERGEN_UNSPECIFIED is converted to
+// an invalid model.Allergen.
+func allergensOf(allergens []productpb.Allergen) []model.Allergen {
+	ret := make([]model.Allergen, 0, len(allergens))
+	for _, a := range allergens {
+		ret = append(ret, model.Allergen(strings.ToLower(a.String())))
+	}
+	return ret
+}
+
+func ingredientsMessage(ingredients []model.Ingredient) []*productpb.Ingredient {
+	ret := make([]*productpb.Ingredient, 0, len(ingredients))
+	for i := range ingredients {
+		ret = append(ret, &productpb.Ingredient{
+			Name:        ingredients[i].Name,
+			Ingredients: ingredientsMessage(ingredients[i].Ingredients),
+		})
+	}
+	return ret
+}
+
+func ingredientsOf(ingredients []*productpb.Ingredient) []model.Ingredient {
+	ret := make([]model.Ingredient, 0, len(ingredients))
+	for _, i := range ingredients {
+		ingredient := model.Ingredient{Name: i.Name}
+		if len(i.Ingredients) > 0 {
+			ingredient.Ingredients = ingredientsOf(i.Ingredients)
+		}
+		ret = append(ret, ingredient)
+	}
+	return ret
+}
+
+func productMessage(p *model.Product) *productpb.Product {
+	return &productpb.Product{
+		ProductId:             p.ProductID,
+		Name:                  p.Name,
+		ImageClosed:           p.ImageClosed,
+		ImageOpen:             p.ImageOpen,
+		Description:           p.Description,
+		Story:                 p.Story,
+		SourcingValues:        p.SourcingValues,
+		Ingredients:           p.Ingredients,
+		AllergyInfo:           p.AllergyInfo,
+		DietaryCertifications: p.DietaryCertifications,
+		Allergens: &productpb.Allergens{
+			Contains:   allergensMessage(p.Allergens.Contains),
+			MayContain: allergensMessage(p.Allergens.MayContain),
+		},
+		StructuredIngredients: ingredientsMessage(p.StructuredIngredients),
+		CreatedAt:             timestamppb.New(p.CreatedAt),
+		UpdatedAt:             timestamppb.New(p.UpdatedAt),
+		Version:               p.Version,
+	}
+}
+
+// inputOfMessage converts m to fields in json written to backends, like the
+// body of HandlePut. Allergens are absent if m has none, so that they're
+// parsed from allergy_info. Ingredients are absent if m has only
+// structured_ingredients.
+func inputOfMessage(m *productpb.Product) (map[string]interface{}, error) {
+	p := model.Product{
+		ProductID:             m.ProductId,
+		Name:                  m.Name,
+		ImageClosed:           m.ImageClosed,
+		ImageOpen:             m.ImageOpen,
+		Description:           m.Description,
+		Story:                 m.Story,
+		SourcingValues:        m.SourcingValues,
+		Ingredients:           m.Ingredients,
+		AllergyInfo:           m.AllergyInfo,
+		DietaryCertifications: m.DietaryCertifications,
+		StructuredIngredients: ingredientsOf(m.StructuredIngredients),
+	}
+	if m.Allergens != nil {
+		p.Allergens = model.Allergens{
+			Contains:   allergensOf(m.Allergens.Contains),
+			MayContain: allergensOf(m.Allergens.MayContain),
+		}
+	}
+	var input map[string]interface{}
+	if err := remarshal(&p, &input); err != nil {
+		return nil, err
+	}
+	for _, k := range []string{"created_at", "updated_at"} {
+		delete(input, k)
+	}
+	if m.Allergens == nil {
+		input["allergens"] = nil
+	}
+	if len(m.StructuredIngredients) == 0 {
+		input["structured_ingredients"] = nil
+	} else if len(m.Ingredients) == 0 {
+		delete(input, "ingredients")
+	}
+	return input, nil
+}
+
+// productOfMessage validates m like the body of HandlePut.
+func productOfMessage(m *productpb.Product) (*model.Product, error) {
+	if m == nil || m.ProductId == "" {
+		return nil, errors.New("invalid data")
+	}
+	input, err := inputOfMessage(m)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	return validateProductFieldsAllPresented(bs)
+}
+
+// filterOfMessage converts m like filterOf.
+func filterOfMessage(m *productpb.Filter) (*model.Filter, error) {
+	filter := &model.Filter{}
+	if m == nil {
+		return filter, nil
+	}
+	filter.Ingredients = m.Ingredients
+	filter.WithoutIngredients = m.WithoutIngredients
+	filter.SourcingValues = m.SourcingValues
+	filter.WithoutSourcingValues = m.WithoutSourcingValues
+	filter.DietaryCertifications = m.DietaryCertifications
+	filter.WithoutDietaryCertifications = m.WithoutDietaryCertifications
+	filter.NamePrefix = m.NamePrefix
+	for _, list := range [][]string{filter.Ingredients,
+		filter.WithoutIngredients, filter.SourcingValues,
+		filter.WithoutSourcingValues, filter.DietaryCertifications,
+		filter.WithoutDietaryCertifications} {
+		for _, v := range list {
+			if v == "" {
+				return nil, errors.New("invalid filter")
+			}
+		}
+	}
+	for _, a := range allergensOf(m.FreeFrom) {
+		if !a.IsValid() {
+			return nil, errors.New("invalid free_from")
+		}
+		filter.FreeFrom = append(filter.FreeFrom, a)
+	}
+	return filter, nil
+}
+
+// CreateProductService creates ProductService with productHandler.
+func CreateProductService(productHandler *ProductHandler) *ProductService {
+	return &ProductService{
+		log:     log15.New("module", "handler.grpc"),
+		product: productHandler,
+	}
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/cfchou/icecream/pkg/productpb"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"io"
+	"net"
+	"testing"
+)
+
+// dialProductService serves ProductService of ph in memory and returns a
+// client of it, and a function to stop both.
+func dialProductService(t *testing.T, ph *ProductHandler) (productpb.ProductServiceClient, func()) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	productpb.RegisterProductServiceServer(s, CreateProductService(ph))
+	go s.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return productpb.NewProductServiceClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestProductService_GetProduct(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("Read", mock.Anything, "001").Return(&model.Product{
+		ProductID: "001",
+		Name:      "One",
+		Allergens: model.Allergens{
+			Contains:   []model.Allergen{model.Milk},
+			MayContain: []model.Allergen{model.TreeNuts},
+		},
+		StructuredIngredients: []model.Ingredient{{Name: "butter",
+			Ingredients: []model.Ingredient{{Name: "cream"}}}},
+		Version: 2,
+	}, nil)
+	mB.On("Read", mock.Anything, "002").
+		Return(nil, pe.WithStack(backend.ErrNotFound))
+	client, stop := dialProductService(t, CreateProductHandler(mB, 10, nil))
+	defer stop()
+
+	p, err := client.GetProduct(context.Background(),
+		&productpb.GetProductRequest{ProductId: "001"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "One", p.Name)
+		assert.Equal(t, int64(2), p.Version)
+		assert.Equal(t, []productpb.Allergen{productpb.Allergen_MILK},
+			p.Allergens.Contains)
+		assert.Equal(t, []productpb.Allergen{productpb.Allergen_TREE_NUTS},
+			p.Allergens.MayContain)
+		assert.Equal(t, "cream",
+			p.StructuredIngredients[0].Ingredients[0].Name)
+	}
+
+	_, err = client.GetProduct(context.Background(),
+		&productpb.GetProductRequest{ProductId: "002"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestProductService_ListProducts(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	filter := &model.Filter{NamePrefix: "C",
+		FreeFrom: []model.Allergen{model.Peanuts}}
+	mB.On("ReadMany", mock.Anything, "", 2, filter, model.Sort("name")).
+		Return(&model.Products{Cursor: "c", Products: []model.Product{
+			{ProductID: "001"}, {ProductID: "002"}}}, nil)
+	mB.On("ReadMany", mock.Anything, "c", 2, filter, model.Sort("name")).
+		Return(&model.Products{Cursor: "d", Products: []model.Product{
+			{ProductID: "003"}, {ProductID: "004"}}}, nil)
+	mB.On("ReadMany", mock.Anything, "d", 2, filter, model.Sort("name")).
+		Return(&model.Products{Products: []model.Product{}}, nil)
+	ph := CreateProductHandler(mB, 10, nil)
+	client, stop := dialProductService(t, ph)
+	defer stop()
+
+	req := &productpb.ListProductsRequest{
+		PageSize: 2,
+		Sort:     "name",
+		Filter: &productpb.Filter{NamePrefix: "C",
+			FreeFrom: []productpb.Allergen{productpb.Allergen_PEANUTS}},
+	}
+	stream, err := client.ListProducts(context.Background(), req)
+	assert.NoError(t, err)
+	var pages []*productpb.Products
+	for {
+		page, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if !assert.NoError(t, err) {
+			return
+		}
+		pages = append(pages, page)
+	}
+	// The empty last page isn't streamed
+	if assert.Len(t, pages, 2) {
+		assert.Equal(t, "002", pages[0].Products[1].ProductId)
+		assert.Equal(t, "004", pages[1].Products[1].ProductId)
+		assert.Equal(t, ph.encodeCursor(model.Sort("name"), "d"),
+			pages[1].Cursor)
+	}
+
+	// Resume from the cursor
+	req.Cursor = pages[0].Cursor
+	stream, _ = client.ListProducts(context.Background(), req)
+	page, err := stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "003", page.Products[0].ProductId)
+	}
+
+	for _, req := range []*productpb.ListProductsRequest{
+		{Sort: "price"},
+		{Cursor: "c"},
+		{PageSize: -1},
+		{Filter: &productpb.Filter{Ingredients: []string{""}}},
+		{Filter: &productpb.Filter{FreeFrom: []productpb.Allergen{
+			productpb.Allergen_ALLERGEN_UNSPECIFIED}}},
+	} {
+		stream, _ := client.ListProducts(context.Background(), req)
+		_, err := stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "%v", req)
+	}
+}
+
+func TestProductService_Write(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("Read", mock.Anything, "001").
+		Return(&model.Product{ProductID: "001", Version: 2}, nil)
+	mB.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mB.On("UpdateIfMatch", mock.Anything, mock.Anything, int64(1)).
+		Return(pe.WithStack(backend.ErrNotFound))
+	mB.On("UpdatePartial", mock.Anything, "001", mock.Anything).Return(nil)
+	mB.On("DeleteIfMatch", mock.Anything, "001", int64(1)).
+		Return(pe.WithStack(backend.ErrPreconditionFailed))
+	client, stop := dialProductService(t, CreateProductHandler(mB, 10, nil))
+	defer stop()
+	ctx := context.Background()
+
+	p, err := client.CreateProduct(ctx, &productpb.CreateProductRequest{
+		Product: &productpb.Product{
+			ProductId: "001",
+			Name:      "One",
+			StructuredIngredients: []*productpb.Ingredient{{Name: "butter",
+				Ingredients: []*productpb.Ingredient{{Name: "cream"},
+					{Name: "salt"}}}},
+			AllergyInfo: "contains milk",
+		},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), p.Version)
+	}
+	mB.AssertCalled(t, "Create", mock.Anything,
+		mock.MatchedBy(func(p *model.Product) bool {
+			return assert.ObjectsAreEqual([]string{"butter (cream, salt)"},
+				p.Ingredients) && p.Allergens.Has(model.Milk)
+		}))
+
+	_, err = client.CreateProduct(ctx, &productpb.CreateProductRequest{
+		Product: &productpb.Product{Name: "One"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Precondition on a product not existed fails as well
+	_, err = client.UpsertProduct(ctx, &productpb.UpsertProductRequest{
+		Product: &productpb.Product{ProductId: "001"}, Version: 1})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Only fields in update_mask
+	_, err = client.UpdateProduct(ctx, &productpb.UpdateProductRequest{
+		ProductId: "001",
+		Product: &productpb.Product{Name: "Two", Story: "ignored",
+			Allergens: &productpb.Allergens{
+				Contains: []productpb.Allergen{productpb.Allergen_SOY}}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name",
+			"allergens"}},
+	})
+	assert.NoError(t, err)
+	mB.AssertCalled(t, "UpdatePartial", mock.Anything, "001",
+		map[string]interface{}{"name": "Two", "allergens": model.Allergens{
+			Contains:   []model.Allergen{model.Soy},
+			MayContain: []model.Allergen{},
+		}})
+	for _, mask := range [][]string{nil, {"productId"}, {"version"}} {
+		_, err = client.UpdateProduct(ctx, &productpb.UpdateProductRequest{
+			ProductId:  "001",
+			Product:    &productpb.Product{Name: "Two"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: mask},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "%v", mask)
+	}
+
+	_, err = client.DeleteProduct(ctx, &productpb.DeleteProductRequest{
+		ProductId: "001", Version: 1})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// normalizeIngredients normalizes "ingredients" of input written to backends,
+// which store only the flat form. If input has "structured_ingredients", it's
+// formatted as "ingredients" and replaces it. Otherwise "ingredients" is
+// repaired in case it was split on every comma. Ingredients of the wrong type
+// are left to backends to reject.
+func normalizeIngredients(input map[string]interface{}) error {
+	raw, ok := input["structured_ingredients"]
+	delete(input, "structured_ingredients")
+	var ingredients []string
+	hasIngredients := false
+	if v, ok := input["ingredients"]; ok {
+		hasIngredients = remarshal(v, &ingredients) == nil
+		if hasIngredients {
+			ingredients = model.NormalizeIngredients(ingredients)
+			input["ingredients"] = ingredients
+		}
+	}
+	if !ok || raw == nil {
+		return nil
+	}
+	var structured []model.Ingredient
+	if err := remarshal(raw, &structured); err != nil {
+		return errors.New("invalid structured_ingredients")
+	}
+	for i := range structured {
+		if !structured[i].IsValid() {
+			return errors.New("invalid structured_ingredients")
+		}
+	}
+	formatted := model.FormatIngredients(structured)
+	if hasIngredients && !reflect.DeepEqual(ingredients, formatted) {
+		return errors.New("ingredients differ from structured_ingredients")
+	}
+	input["ingredients"] = formatted
+	return nil
+}
+
+// remarshal converts v decoded from JSON to ptr.
+func remarshal(v interface{}, ptr interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, ptr)
+}
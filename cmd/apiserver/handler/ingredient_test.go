@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeIngredients(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`{"name": "One"}`, `{"name": "One"}`},
+		{`{"ingredients": ["cream", "liquid sugar (sugar", "water)"]}`,
+			`{"ingredients": ["cream", "liquid sugar (sugar, water)"]}`},
+		{`{"structured_ingredients": [{"name": "cream"},
+			{"name": "butter", "ingredients": [{"name": "cream"},
+			{"name": "salt"}]}]}`,
+			`{"ingredients": ["cream", "butter (cream, salt)"]}`},
+		// What's read can be written back
+		{`{"ingredients": ["butter (cream, salt)"],
+			"structured_ingredients": [{"name": "butter", "ingredients": [
+			{"name": "cream"}, {"name": "salt"}]}]}`,
+			`{"ingredients": ["butter (cream, salt)"]}`},
+		{`{"ingredients": ["cream"], "structured_ingredients": null}`,
+			`{"ingredients": ["cream"]}`},
+		// Left to backends
+		{`{"ingredients": "cream"}`, `{"ingredients": "cream"}`},
+	}
+	for _, c := range cases {
+		var input map[string]interface{}
+		json.Unmarshal([]byte(c.input), &input)
+		if assert.NoError(t, normalizeIngredients(input), c.input) {
+			bs, _ := json.Marshal(input)
+			assert.JSONEq(t, c.expected, string(bs))
+		}
+	}
+
+	for _, s := range []string{
+		`{"structured_ingredients": "cream"}`,
+		`{"structured_ingredients": [{"name": ""}]}`,
+		`{"structured_ingredients": [{"name": "sugar, water"}]}`,
+		`{"ingredients": ["cream"],
+			"structured_ingredients": [{"name": "milk"}]}`,
+	} {
+		var input map[string]interface{}
+		json.Unmarshal([]byte(s), &input)
+		assert.Error(t, normalizeIngredients(input), s)
+	}
+}
+
+func TestProductHandler_HandlePatch_Ingredients(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("UpdatePartial", mock.Anything, "001", mock.Anything).Return(nil)
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("PATCH").Path("/products/{productID}").HandlerFunc(ph.HandlePatch)
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("PATCH", "/products/001",
+		bytes.NewBufferString(`{"structured_ingredients": [{"name": "fudge",
+			"ingredients": [{"name": "sugar"}, {"name": "cocoa"}]}]}`))
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	mB.AssertCalled(t, "UpdatePartial", mock.Anything, "001",
+		map[string]interface{}{"ingredients": []string{"fudge (sugar, cocoa)"}})
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("PATCH", "/products/001",
+		bytes.NewBufferString(`{"structured_ingredients": [{"name": "a, b"}]}`))
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 400, writer.Code)
+}
+
+func TestProductHandler_HandlePut_Ingredients(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("Upsert", mock.Anything, mock.Anything).Return(nil)
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("PUT").Path("/products/{productID}").HandlerFunc(ph.HandlePut)
+
+	product := &model.Product{
+		ProductID:   "001",
+		Ingredients: []string{"cream", "liquid sugar (sugar", "water)"},
+	}
+	writer := httptest.NewRecorder()
+	bs, _ := json.Marshal(product)
+	request, _ := http.NewRequest("PUT", "/products/001", bytes.NewBuffer(bs))
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 201, writer.Code)
+	mB.AssertCalled(t, "Upsert", mock.Anything,
+		mock.MatchedBy(func(p *model.Product) bool {
+			return assert.ObjectsAreEqual([]string{"cream",
+				"liquid sugar (sugar, water)"}, p.Ingredients)
+		}))
+}
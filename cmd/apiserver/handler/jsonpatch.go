@@ -0,0 +1,445 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/pkg/errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// jsonPatchContentType is the media type of JSON Patch documents, RFC
+	// 6902.
+	jsonPatchContentType = "application/json-patch+json"
+	// patchRetries is how many times a JSON Patch is applied again after
+	// losing to a concurrent write.
+	patchRetries = 3
+)
+
+var (
+	errPatchPath = errors.New("invalid path")
+	// errPatchTest is returned when a "test" operation fails.
+	errPatchTest = errors.New("test failed")
+)
+
+// patchOperation is an operation of a JSON Patch document. From is only for
+// "move" and "copy", and Value is for "add", "replace" and "test".
+type patchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+func (o *patchOperation) String() string {
+	if o.Op == "move" || o.Op == "copy" {
+		return fmt.Sprintf("%s %s to %s", o.Op, o.From, o.Path)
+	}
+	return fmt.Sprintf("%s %s", o.Op, o.Path)
+}
+
+// patchError is the failure of the operation Index of a JSON Patch document.
+type patchError struct {
+	Index int
+	Op    string
+	Err   error
+}
+
+func (e *patchError) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("operation %d: %s", e.Index, e.Err)
+	}
+	return fmt.Sprintf("operation %d (%s): %s", e.Index, e.Op, e.Err)
+}
+
+// Cause is for errors.Cause.
+func (e *patchError) Cause() error {
+	return e.Err
+}
+
+// handleJSONPatch applies the JSON Patch document body to the product of
+// productID. The product is read, patched and written back only if it's
+// still of the version read, so the operations apply atomically. With
+// "If-Match", the product read must be of the given ETag.
+func (h *ProductHandler) handleJSONPatch(w http.ResponseWriter, r *http.Request,
+	productID string, body []byte) {
+	ops, err := parseJSONPatch(body)
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	for i := 0; ; i++ {
+		product, err := h.backend.Read(r.Context(), productID)
+		if err != nil {
+			if version != 0 {
+				err = conditionalError(err)
+			}
+			h.writeError(w, r, err)
+			return
+		}
+		if version > 0 && product.Version != version {
+			h.writeError(w, r, errors.Wrap(backend.ErrPreconditionFailed,
+				"version not matched"))
+			return
+		}
+		patched, err := patchProduct(product, ops)
+		if err != nil {
+			WriteProblem(w, r, patchProblem(ops, err))
+			return
+		}
+		err = h.backend.UpdateIfMatch(r.Context(), patched, product.Version)
+		if errors.Cause(err) == backend.ErrPreconditionFailed && version <= 0 {
+			// Lost to a concurrent write
+			if i < patchRetries {
+				continue
+			}
+			err = errors.Wrap(backend.ErrConflict, err.Error())
+		}
+		if err != nil {
+			if version != 0 {
+				err = conditionalError(err)
+			}
+			h.writeError(w, r, err)
+			return
+		}
+		w.Header().Set("ETag", formatETag(product.Version+1))
+		w.WriteHeader(200)
+		return
+	}
+}
+
+// patchProblem converts err of applying ops to a Problem. A failed test is a
+// precondition failed, while others are unprocessable.
+func patchProblem(ops []patchOperation, err error) *Problem {
+	p := &Problem{Status: 422, Code: CodeInvalidPatch, Detail: err.Error()}
+	if errors.Cause(err) == errPatchTest {
+		p.Status = 412
+		p.Code = CodePreconditionFailed
+	}
+	switch e := err.(type) {
+	case *patchError:
+		p.Errors = []FieldError{{Field: ops[e.Index].Path,
+			Code: CodeInvalidValue, Detail: e.Err.Error()}}
+	case invalidFields:
+		p.Detail = "the patched product is invalid"
+		p.Errors = e
+	}
+	return p
+}
+
+// patchProduct applies ops to product as it's represented in json. Derived
+// fields follow what they're derived from, i.e. "structured_ingredients" or
+// "ingredients", whichever is patched, and "allergens" are parsed again from
+// "allergy_info" unless they're patched too.
+func patchProduct(product *model.Product, ops []patchOperation) (*model.Product, error) {
+	bs, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+	var original, doc map[string]interface{}
+	json.Unmarshal(bs, &original)
+	json.Unmarshal(bs, &doc)
+	patched, err := applyJSONPatch(doc, ops)
+	if err != nil {
+		return nil, err
+	}
+	input, ok := patched.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid data")
+	}
+	if input["productId"] != original["productId"] {
+		return nil, invalidFields{{Field: "productId", Code: CodeImmutableField,
+			Detail: "can't be changed"}}
+	}
+	unchanged := func(k string) bool {
+		return reflect.DeepEqual(input[k], original[k])
+	}
+	if !unchanged("structured_ingredients") && unchanged("ingredients") {
+		delete(input, "ingredients")
+	} else if unchanged("structured_ingredients") {
+		delete(input, "structured_ingredients")
+	}
+	if unchanged("allergens") && !unchanged("allergy_info") {
+		delete(input, "allergens")
+	}
+	if bs, err = json.Marshal(input); err != nil {
+		return nil, err
+	}
+	return validateProductFieldsAllPresented(bs)
+}
+
+// parseJSONPatch parses a JSON Patch document. Every operation is checked to
+// have the members its op requires, with valid pointers.
+func parseJSONPatch(body []byte) ([]patchOperation, error) {
+	// Members are decoded raw to tell an absent value from null
+	var raws []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, errors.New("invalid json patch")
+	}
+	ops := make([]patchOperation, len(raws))
+	for i, raw := range raws {
+		op := &ops[i]
+		if err := unmarshalMember(raw, "op", &op.Op); err != nil {
+			return nil, &patchError{Index: i, Err: err}
+		}
+		if err := unmarshalMember(raw, "path", &op.Path); err != nil {
+			return nil, &patchError{Index: i, Err: err}
+		}
+		pointers := []string{op.Path}
+		switch op.Op {
+		case "add", "replace", "test":
+			if err := unmarshalMember(raw, "value", &op.Value); err != nil {
+				return nil, &patchError{Index: i, Err: err}
+			}
+		case "move", "copy":
+			if err := unmarshalMember(raw, "from", &op.From); err != nil {
+				return nil, &patchError{Index: i, Err: err}
+			}
+			pointers = append(pointers, op.From)
+		case "remove":
+		default:
+			return nil, &patchError{Index: i,
+				Err: errors.New(fmt.Sprintf("unknown op:%s", op.Op))}
+		}
+		for _, pointer := range pointers {
+			if _, err := parsePointer(pointer); err != nil {
+				return nil, &patchError{Index: i, Op: op.String(), Err: err}
+			}
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, &patchError{Index: i, Op: op.String(),
+				Err: errors.New("can't move into itself")}
+		}
+	}
+	return ops, nil
+}
+
+// unmarshalMember unmarshals the member name of raw to ptr.
+func unmarshalMember(raw map[string]json.RawMessage, name string,
+	ptr interface{}) error {
+	v, ok := raw[name]
+	if !ok {
+		return errors.New(fmt.Sprintf("missing member:%s", name))
+	}
+	if err := json.Unmarshal(v, ptr); err != nil {
+		return errors.New(fmt.Sprintf("invalid member:%s", name))
+	}
+	return nil
+}
+
+// applyJSONPatch applies ops to doc decoded from JSON, in order, and returns
+// the patched doc. If any operation fails, a *patchError is returned and doc
+// may be partially patched. Values are copied so that doc never shares them.
+func applyJSONPatch(doc interface{}, ops []patchOperation) (interface{}, error) {
+	for i := range ops {
+		op := &ops[i]
+		var err error
+		path, _ := parsePointer(op.Path)
+		switch op.Op {
+		case "add":
+			doc, err = addAt(doc, path, deepCopy(op.Value))
+		case "remove":
+			doc, _, err = removeAt(doc, path)
+		case "replace":
+			// The value must exist
+			if len(path) == 0 {
+				doc = deepCopy(op.Value)
+			} else if _, err = valueAt(doc, path); err == nil {
+				if doc, _, err = removeAt(doc, path); err == nil {
+					doc, err = addAt(doc, path, deepCopy(op.Value))
+				}
+			}
+		case "move":
+			from, _ := parsePointer(op.From)
+			var v interface{}
+			if doc, v, err = removeAt(doc, from); err == nil {
+				doc, err = addAt(doc, path, v)
+			}
+		case "copy":
+			from, _ := parsePointer(op.From)
+			var v interface{}
+			if v, err = valueAt(doc, from); err == nil {
+				doc, err = addAt(doc, path, deepCopy(v))
+			}
+		case "test":
+			var v interface{}
+			if v, err = valueAt(doc, path); err == nil &&
+				!reflect.DeepEqual(v, op.Value) {
+				err = errPatchTest
+			}
+		}
+		if err != nil {
+			return nil, &patchError{Index: i, Op: op.String(), Err: err}
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer parses a JSON Pointer, RFC 6901, into its reference tokens.
+// The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, errors.Wrap(errPatchPath, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		if strings.Contains(strings.NewReplacer("~0", "", "~1", "").
+			Replace(token), "~") {
+			return nil, errors.Wrap(errPatchPath, pointer)
+		}
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses token as an index of array whose length is n. The index
+// may be n, i.e. the end of array, only if end is true, and "-" is n.
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token != strconv.Itoa(i) {
+		return 0, errors.Wrap(errPatchPath, "invalid index "+token)
+	}
+	if i > n || (i == n && !end) {
+		return 0, errors.Wrap(errPatchPath, "index out of range "+token)
+	}
+	return i, nil
+}
+
+// valueAt returns the value of doc referred by path.
+func valueAt(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return nil, errors.Wrap(errPatchPath, "not found "+token)
+			}
+			doc = child
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, errors.Wrap(errPatchPath, "not found "+token)
+		}
+	}
+	return doc, nil
+}
+
+// updateAt replaces the parent of the value referred by path with what f
+// returns, given the parent and the last token of path. Path must not be
+// empty.
+func updateAt(doc interface{}, path []string,
+	f func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return f(doc, path[0])
+	}
+	child, err := valueAt(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = updateAt(child, path[1:], f); err != nil {
+		return nil, err
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		v[path[0]] = child
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(v), false)
+		v[i] = child
+	}
+	return doc, nil
+}
+
+// addAt adds value to doc at path, inserting it if path refers to an index of
+// an array.
+func addAt(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateAt(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch v := parent.(type) {
+		case map[string]interface{}:
+			v[token] = value
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), true)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+			return v, nil
+		}
+		return nil, errors.Wrap(errPatchPath, "not a container "+token)
+	})
+}
+
+// removeAt removes the value at path of doc and returns it.
+func removeAt(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.Wrap(errPatchPath, "can't remove the document")
+	}
+	var removed interface{}
+	doc, err := updateAt(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch v := parent.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return nil, errors.Wrap(errPatchPath, "not found "+token)
+			}
+			removed = child
+			delete(v, token)
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = v[i]
+			return append(v[:i:i], v[i+1:]...), nil
+		}
+		return nil, errors.Wrap(errPatchPath, "not found "+token)
+	})
+	return doc, removed, err
+}
+
+// deepCopy copies v decoded from JSON.
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			m[k] = deepCopy(child)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, child := range v {
+			s[i] = deepCopy(child)
+		}
+		return s
+	}
+	return v
+}
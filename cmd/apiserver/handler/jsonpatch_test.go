@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/gorilla/mux"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	cases := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a": 1}`, `[{"op": "add", "path": "/b", "value": [1]}]`,
+			`{"a": 1, "b": [1]}`},
+		{`{"a": [1, 2]}`, `[{"op": "add", "path": "/a/1", "value": 3}]`,
+			`{"a": [1, 3, 2]}`},
+		{`{"a": [1, 2]}`, `[{"op": "add", "path": "/a/-", "value": 3}]`,
+			`{"a": [1, 2, 3]}`},
+		{`{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/0"}]`, `{"a": [2]}`},
+		{`{"a": {"b": 1, "c": 2}}`, `[{"op": "remove", "path": "/a/b"}]`,
+			`{"a": {"c": 2}}`},
+		{`{"a": [1, 2]}`, `[{"op": "replace", "path": "/a/1", "value": null}]`,
+			`{"a": [1, null]}`},
+		{`{"a": [1, 2], "b": []}`,
+			`[{"op": "move", "from": "/a/0", "path": "/b/-"}]`,
+			`{"a": [2], "b": [1]}`},
+		{`{"a": [1, 2, 3]}`, `[{"op": "move", "from": "/a/0", "path": "/a/2"}]`,
+			`{"a": [2, 3, 1]}`},
+		// Copies don't share values
+		{`{"a": {"b": 1}}`, `[{"op": "copy", "from": "/a", "path": "/c"},
+			{"op": "replace", "path": "/c/b", "value": 2}]`,
+			`{"a": {"b": 1}, "c": {"b": 2}}`},
+		{`{"a/b": {"~": 1}}`, `[{"op": "test", "path": "/a~1b/~0", "value": 1}]`,
+			`{"a/b": {"~": 1}}`},
+		{`{"a": 1}`, `[{"op": "replace", "path": "", "value": {"b": 2}}]`,
+			`{"b": 2}`},
+	}
+	for _, c := range cases {
+		var doc interface{}
+		json.Unmarshal([]byte(c.doc), &doc)
+		ops, err := parseJSONPatch([]byte(c.patch))
+		if !assert.NoError(t, err, c.patch) {
+			continue
+		}
+		patched, err := applyJSONPatch(doc, ops)
+		if assert.NoError(t, err, c.patch) {
+			bs, _ := json.Marshal(patched)
+			assert.JSONEq(t, c.expected, string(bs), c.patch)
+		}
+	}
+}
+
+func TestApplyJSONPatch_Error(t *testing.T) {
+	cases := []struct {
+		patch, expected string
+	}{
+		{`[{"op": "remove", "path": "/b"}]`, "operation 0 (remove /b)"},
+		{`[{"op": "test", "path": "/a", "value": 1},
+			{"op": "test", "path": "/a/1", "value": 3}]`, "test failed"},
+		{`[{"op": "replace", "path": "/a/2", "value": 3}]`, "index out of range"},
+		{`[{"op": "replace", "path": "/a/-", "value": 3}]`, "invalid index"},
+		{`[{"op": "add", "path": "/a/01", "value": 3}]`, "invalid index"},
+		{`[{"op": "add", "path": "/a/0/b", "value": 3}]`, "not a container"},
+		{`[{"op": "copy", "from": "/b", "path": "/c"}]`, "operation 0 (copy"},
+		{`[{"op": "remove", "path": ""}]`, "can't remove the document"},
+	}
+	for _, c := range cases {
+		var doc interface{}
+		json.Unmarshal([]byte(`{"a": [1, 2]}`), &doc)
+		ops, err := parseJSONPatch([]byte(c.patch))
+		if !assert.NoError(t, err, c.patch) {
+			continue
+		}
+		_, err = applyJSONPatch(doc, ops)
+		if assert.Error(t, err, c.patch) {
+			assert.Contains(t, err.Error(), c.expected, c.patch)
+		}
+	}
+}
+
+func TestParseJSONPatch(t *testing.T) {
+	for _, patch := range []string{
+		`{"op": "remove", "path": "/a"}`,
+		`[{"path": "/a"}]`,
+		`[{"op": "delete", "path": "/a"}]`,
+		`[{"op": "remove"}]`,
+		`[{"op": "remove", "path": "a"}]`,
+		`[{"op": "remove", "path": "/a~2"}]`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "copy", "path": "/a"}]`,
+		`[{"op": "move", "from": "/a", "path": "/a/b"}]`,
+	} {
+		_, err := parseJSONPatch([]byte(patch))
+		assert.Error(t, err, patch)
+	}
+	// Null is a value
+	ops, err := parseJSONPatch([]byte(`[{"op": "add", "path": "/a",
+		"value": null}]`))
+	if assert.NoError(t, err) {
+		assert.Nil(t, ops[0].Value)
+	}
+}
+
+func TestProductHandler_HandlePatch_JSONPatch(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	product := &model.Product{
+		ProductID:      productID,
+		Name:           "One",
+		SourcingValues: []string{"Fairtrade"},
+		Ingredients:    []string{"cream", "sugar"},
+		StructuredIngredients: []model.Ingredient{{Name: "cream"},
+			{Name: "sugar"}},
+		AllergyInfo: "contains milk",
+		Allergens: model.Allergens{Contains: []model.Allergen{model.Milk},
+			MayContain: []model.Allergen{}},
+		Version: 2,
+	}
+	mB.On("Read", mock.Anything, productID).Return(product, nil)
+	mB.On("UpdateIfMatch", mock.Anything, mock.Anything, int64(2)).
+		Return(nil).Once()
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("PATCH").Path("/products/{productID}").HandlerFunc(ph.HandlePatch)
+	patch := func(body string, ifMatch string) *httptest.ResponseRecorder {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/products/"+productID,
+			bytes.NewBufferString(body))
+		request.Header.Set("Content-Type", "application/json-patch+json")
+		if ifMatch != "" {
+			request.Header.Set("If-Match", ifMatch)
+		}
+		r.ServeHTTP(writer, request)
+		return writer
+	}
+
+	writer := patch(`[{"op": "test", "path": "/name", "value": "One"},
+		{"op": "add", "path": "/ingredients/-", "value": "eggs"},
+		{"op": "remove", "path": "/sourcing_values/0"},
+		{"op": "replace", "path": "/allergy_info", "value": "contains eggs"}]`,
+		"")
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"3"`, writer.Header().Get("ETag"))
+	mB.AssertCalled(t, "UpdateIfMatch", mock.Anything,
+		mock.MatchedBy(func(p *model.Product) bool {
+			return assert.ObjectsAreEqual([]string{"cream", "sugar", "eggs"},
+				p.Ingredients) &&
+				len(p.SourcingValues) == 0 &&
+				// Allergens are parsed again from allergy_info
+				p.Allergens.Has(model.Eggs) && !p.Allergens.Has(model.Milk)
+		}), int64(2))
+
+	// Structured ingredients replace ingredients
+	mB.On("UpdateIfMatch", mock.Anything, mock.Anything, int64(2)).
+		Return(nil).Once()
+	writer = patch(`[{"op": "add", "path": "/structured_ingredients/0/ingredients",
+		"value": [{"name": "milk"}]}]`, `"2"`)
+	assert.Equal(t, 200, writer.Code)
+	mB.AssertCalled(t, "UpdateIfMatch", mock.Anything,
+		mock.MatchedBy(func(p *model.Product) bool {
+			return assert.ObjectsAreEqual([]string{"cream (milk)", "sugar"},
+				p.Ingredients)
+		}), int64(2))
+
+	// Retried after losing to a concurrent write, then 409
+	mB.On("UpdateIfMatch", mock.Anything, mock.Anything, int64(2)).
+		Return(pe.WithStack(backend.ErrPreconditionFailed))
+	writer = patch(`[{"op": "replace", "path": "/name", "value": "Two"}]`, "")
+	assert.Equal(t, 409, writer.Code)
+	mB.AssertNumberOfCalls(t, "UpdateIfMatch", 2+patchRetries+1)
+
+	for _, c := range []struct {
+		body, ifMatch string
+		code          int
+	}{
+		{`[{"op": "replace", "path": "/name", "value": "Two"}]`, `"1"`, 412},
+		{`[{"op": "test", "path": "/name", "value": "Two"}]`, "", 412},
+		{`[{"op": "remove", "path": "/ingredients/5"}]`, "", 422},
+		{`[{"op": "replace", "path": "/productId", "value": "002"}]`, "", 422},
+		{`[{"op": "remove", "path": "/name"}]`, "", 422},
+		{`[{"op": "replace", "path": "/name", "value": 1}]`, "", 422},
+		{`[{"op": "remove", "path": "/name"}`, "", 400},
+	} {
+		writer := patch(c.body, c.ifMatch)
+		assert.Equal(t, c.code, writer.Code, c.body)
+	}
+	mB.AssertNumberOfCalls(t, "UpdateIfMatch", 2+patchRetries+1)
+
+	// The operation failed is told
+	writer = patch(`[{"op": "remove", "path": "/ingredients/5"}]`, "")
+	p := problemOf(t, writer)
+	assert.Equal(t, CodeInvalidPatch, p.Code)
+	if assert.Len(t, p.Errors, 1) {
+		assert.Equal(t, "/ingredients/5", p.Errors[0].Field)
+	}
+	writer = patch(`[{"op": "test", "path": "/name", "value": "Two"}]`, "")
+	assert.Equal(t, CodePreconditionFailed, problemOf(t, writer).Code)
+}
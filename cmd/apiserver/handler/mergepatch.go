@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// handleMergePatch applies the JSON Merge Patch, RFC 7396, body to the
+// product of productID. Null resets a field to its zero value, and
+// "allergens" merges with those stored, which are read first if it's partial.
+// The product is then written only if it's still of the version read.
+func (h *ProductHandler) handleMergePatch(w http.ResponseWriter, r *http.Request,
+	productID string, body []byte) {
+	var input map[string]interface{}
+	if err := json.Unmarshal(body, &input); err != nil || input == nil {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("invalid data"))
+		return
+	}
+	if err := validateMergePatch(productID, input); err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	// Stored allergens are kept only for members not in the patch
+	allergens, merging := input["allergens"].(map[string]interface{})
+	_, hasContains := allergens["contains"]
+	_, hasMayContain := allergens["may_contain"]
+	merging = merging && !(hasContains && hasMayContain)
+	for i := 0; ; i++ {
+		kvs := deepCopy(input).(map[string]interface{})
+		read := version
+		if merging {
+			product, err := h.backend.Read(r.Context(), productID)
+			if err != nil {
+				if version != 0 {
+					err = conditionalError(err)
+				}
+				h.writeError(w, r, err)
+				return
+			}
+			if version > 0 && product.Version != version {
+				h.writeError(w, r, errors.Wrap(backend.ErrPreconditionFailed,
+					"version not matched"))
+				return
+			}
+			read = product.Version
+			var stored interface{}
+			remarshal(&product.Allergens, &stored)
+			kvs["allergens"] = mergePatch(stored, allergens)
+		}
+		if err := resolveMergePatch(kvs); err != nil {
+			// Bad Request
+			writeBadRequest(w, r, err)
+			return
+		}
+		switch read {
+		case 0:
+			err = h.backend.UpdatePartial(r.Context(), productID, kvs)
+		case anyVersion:
+			err = conditionalError(h.backend.UpdatePartial(r.Context(),
+				productID, kvs))
+		default:
+			err = h.backend.UpdatePartialIfMatch(r.Context(), productID, kvs,
+				read)
+		}
+		if merging && version <= 0 &&
+			errors.Cause(err) == backend.ErrPreconditionFailed {
+			// Lost to a concurrent write
+			if i < patchRetries {
+				continue
+			}
+			err = errors.Wrap(backend.ErrConflict, err.Error())
+		}
+		if err != nil {
+			if version != 0 {
+				err = conditionalError(err)
+			}
+			h.writeError(w, r, err)
+			return
+		}
+		if read > 0 {
+			w.Header().Set("ETag", formatETag(read+1))
+		}
+		w.WriteHeader(200)
+		return
+	}
+}
+
+// validateMergePatch validates input against the schema ProductPatch of the
+// OpenAPI document, and returns every invalid field as invalidFields. Fields
+// read-only are removed.
+func validateMergePatch(productID string, input map[string]interface{}) error {
+	invalid := document.Validate(patchSchema, "", input)
+	if pid, ok := input["productId"].(string); ok && pid != productID {
+		invalid = append(invalid, FieldError{Field: "productId",
+			Code: CodeImmutableField, Detail: "can't be changed"})
+	}
+	deleteReadOnly(patchSchema, input)
+	if len(invalid) == 0 {
+		return nil
+	}
+	invalid.Sort()
+	return invalid
+}
+
+// resolveMergePatch turns kvs of a validated merge patch into fields written
+// to backends. Null "structured_ingredients" resets "ingredients", and null
+// "allergens" are empty unless they're parsed from "allergy_info" given.
+func resolveMergePatch(kvs map[string]interface{}) error {
+	if raw, ok := kvs["structured_ingredients"]; ok && raw == nil {
+		delete(kvs, "structured_ingredients")
+		if _, ok := kvs["ingredients"]; !ok {
+			kvs["ingredients"] = nil
+		}
+	}
+	if info, ok := kvs["allergy_info"]; ok && info == nil &&
+		kvs["allergens"] == nil {
+		kvs["allergens"] = model.ParseAllergyInfo("")
+	}
+	if raw, ok := kvs["allergens"]; ok && raw == nil {
+		if _, ok := kvs["allergy_info"]; !ok {
+			kvs["allergens"] = model.Allergens{}
+		}
+	}
+	if invalid := normalizeFields(kvs); len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// mergePatch merges patch into target per RFC 7396, where null removes a
+// member. Target is not modified.
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return deepCopy(patch)
+	}
+	ret, ok := deepCopy(target).(map[string]interface{})
+	if !ok {
+		ret = make(map[string]interface{})
+	}
+	for k, v := range members {
+		if v == nil {
+			delete(ret, k)
+		} else {
+			ret[k] = mergePatch(ret[k], v)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/gorilla/mux"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Examples of RFC 7396
+	cases := []struct {
+		target, patch, expected string
+	}{
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`,
+			`{"a": {"b": "d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{`{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{`[1, 2]`, `{"a": "b", "c": null}`, `{"a": "b"}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+	}
+	for _, c := range cases {
+		var target, patch interface{}
+		json.Unmarshal([]byte(c.target), &target)
+		json.Unmarshal([]byte(c.patch), &patch)
+		bs, _ := json.Marshal(mergePatch(target, patch))
+		assert.JSONEq(t, c.expected, string(bs), c.patch)
+	}
+}
+
+func TestValidateMergePatch(t *testing.T) {
+	input := map[string]interface{}{
+		"name":       "One",
+		"story":      nil,
+		"created_at": "ignored",
+	}
+	assert.NoError(t, validateMergePatch("001", input))
+	assert.Equal(t, map[string]interface{}{"name": "One", "story": nil},
+		input)
+
+	// Every invalid field is listed
+	err := validateMergePatch("001", map[string]interface{}{
+		"productId":       "002",
+		"name":            1,
+		"sourcing_values": "Fairtrade",
+		"unknown":         "value",
+		"allergens": map[string]interface{}{"contains": []interface{}{"nuts"},
+			"traces": []interface{}{}},
+		"structured_ingredients": []interface{}{
+			map[string]interface{}{"name": ""}},
+	})
+	assert.Equal(t, invalidFields{
+		{Field: "allergens.contains[0]", Code: CodeInvalidValue,
+			Detail: "unknown allergen nuts"},
+		{Field: "allergens.traces", Code: CodeUnknownField,
+			Detail: "unknown field"},
+		{Field: "name", Code: CodeInvalidType, Detail: "not a string"},
+		{Field: "productId", Code: CodeImmutableField,
+			Detail: "can't be changed"},
+		{Field: "sourcing_values", Code: CodeInvalidType, Detail: "not a list"},
+		{Field: "structured_ingredients[0].name", Code: CodeInvalidValue,
+			Detail: `doesn't match ^[^\s,()\[\]]([^,()\[\]]*[^\s,()\[\]])?$`},
+		{Field: "unknown", Code: CodeUnknownField, Detail: "unknown field"},
+	}, err)
+}
+
+func TestProductHandler_HandlePatch_MergePatch(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	mB.On("Read", mock.Anything, productID).Return(&model.Product{
+		ProductID: productID,
+		Allergens: model.Allergens{Contains: []model.Allergen{model.Milk},
+			MayContain: []model.Allergen{model.Peanuts}},
+		Version: 2,
+	}, nil)
+	mB.On("UpdatePartial", mock.Anything, productID, mock.Anything).Return(nil)
+	mB.On("UpdatePartialIfMatch", mock.Anything, productID, mock.Anything,
+		int64(2)).Return(nil).Once()
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("PATCH").Path("/products/{productID}").HandlerFunc(ph.HandlePatch)
+	patch := func(body string) *httptest.ResponseRecorder {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/products/"+productID,
+			bytes.NewBufferString(body))
+		request.Header.Set("Content-Type", "application/merge-patch+json")
+		r.ServeHTTP(writer, request)
+		return writer
+	}
+
+	// Null resets fields
+	writer := patch(`{"story": null, "structured_ingredients": null,
+		"allergens": null}`)
+	assert.Equal(t, 200, writer.Code)
+	mB.AssertCalled(t, "UpdatePartial", mock.Anything, productID,
+		map[string]interface{}{
+			"story":       nil,
+			"ingredients": model.NormalizeIngredients(nil),
+			"allergens": model.Allergens{Contains: []model.Allergen{},
+				MayContain: []model.Allergen{}},
+		})
+	mB.AssertNotCalled(t, "Read", mock.Anything, productID)
+
+	writer = patch(`{"allergy_info": null}`)
+	assert.Equal(t, 200, writer.Code)
+	mB.AssertCalled(t, "UpdatePartial", mock.Anything, productID,
+		map[string]interface{}{
+			"allergy_info": nil,
+			"allergens": model.Allergens{Contains: []model.Allergen{},
+				MayContain: []model.Allergen{}},
+		})
+
+	// Allergens merge with those stored
+	writer = patch(`{"allergens": {"contains": ["soy"]}}`)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"3"`, writer.Header().Get("ETag"))
+	mB.AssertCalled(t, "UpdatePartialIfMatch", mock.Anything, productID,
+		map[string]interface{}{"allergens": model.Allergens{
+			Contains:   []model.Allergen{model.Soy},
+			MayContain: []model.Allergen{model.Peanuts},
+		}}, int64(2))
+
+	// Retried after losing to a concurrent write, then 409
+	mB.On("UpdatePartialIfMatch", mock.Anything, productID, mock.Anything,
+		int64(2)).Return(pe.WithStack(backend.ErrPreconditionFailed))
+	writer = patch(`{"allergens": {"may_contain": null}}`)
+	assert.Equal(t, 409, writer.Code)
+	mB.AssertNumberOfCalls(t, "UpdatePartialIfMatch", 1+patchRetries+1)
+
+	for _, body := range []string{
+		`[]`,
+		`null`,
+		`{"name": 1, "unknown": 1}`,
+		`{"productId": null}`,
+		// Allergens appear only once
+		`{"allergens": {"contains": ["milk"], "may_contain": ["milk"]}}`,
+	} {
+		writer = patch(body)
+		assert.Equal(t, 400, writer.Code, body)
+	}
+	p := problemOf(t, writer)
+	assert.Equal(t, CodeInvalidFields, p.Code)
+	assert.Equal(t, []FieldError{{Field: "allergens", Code: CodeInvalidValue,
+		Detail: "invalid allergens"}}, p.Errors)
+}
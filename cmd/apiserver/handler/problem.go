@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/openapi"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// problemContentType is the media type of Problem, RFC 7807.
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes codes of problems to make their types.
+const problemTypePrefix = "urn:icecream:problem:"
+
+// Codes of problems. Those of a field tell why a field is invalid.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeInvalidFields      = "invalid_fields"
+	CodeInvalidPatch       = "invalid_patch"
+	CodeUnauthorized       = "unauthorized"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeAlreadyExists      = "already_exists"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodeInternal           = "internal"
+	CodeNotImplemented     = "not_implemented"
+	CodeUnavailable        = "unavailable"
+	CodeTimeout            = "timeout"
+
+	CodeUnknownField   = openapi.CodeUnknownField
+	CodeMissingField   = openapi.CodeMissingField
+	CodeInvalidType    = openapi.CodeInvalidType
+	CodeInvalidValue   = openapi.CodeInvalidValue
+	CodeImmutableField = openapi.CodeImmutableField
+)
+
+// problemTitles are titles of problems by their codes.
+var problemTitles = map[string]string{
+	CodeInvalidRequest:     "Invalid request",
+	CodeInvalidFields:      "Invalid fields",
+	CodeInvalidPatch:       "Invalid patch",
+	CodeUnauthorized:       "Unauthorized",
+	CodeNotFound:           "Not found",
+	CodeMethodNotAllowed:   "Method not allowed",
+	CodeAlreadyExists:      "Already exists",
+	CodeConflict:           "Concurrent write",
+	CodePreconditionFailed: "Precondition failed",
+	CodeInternal:           "Internal error",
+	CodeNotImplemented:     "Not implemented",
+	CodeUnavailable:        "Unavailable",
+	CodeTimeout:            "Timeout",
+}
+
+// problemDetails describe problems of errors whose messages aren't meant for
+// clients, e.g. those from backends.
+var problemDetails = map[string]string{
+	CodeInvalidFields:      "some fields are invalid",
+	CodeNotFound:           "no such product",
+	CodeAlreadyExists:      "the product already exists",
+	CodeConflict:           "a concurrent write conflicts, retrying may succeed",
+	CodePreconditionFailed: "the product isn't of the version required",
+	CodeInternal:           "an unexpected failure",
+	CodeNotImplemented:     "the backend doesn't support the operation",
+	CodeUnavailable:        "the db can't be reached for the moment",
+	CodeTimeout:            "the request takes too long",
+}
+
+// Problem is a problem detail, RFC 7807, written in place of a successful
+// response. Code is machine-readable, and Errors tells fields invalid.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError tells why a field of a request is invalid.
+type FieldError = openapi.FieldError
+
+// invalidFields is an error of every invalid field of a request.
+type invalidFields = openapi.Errors
+
+// ErrorCode maps an error returned by backends to the code of a problem, like
+// StatusCode does to a http status code.
+func ErrorCode(err error) string {
+	switch errors.Cause(err) {
+	case backend.ErrNotFound:
+		return CodeNotFound
+	case backend.ErrAlreadyExists:
+		return CodeAlreadyExists
+	case backend.ErrConflict:
+		return CodeConflict
+	case backend.ErrInvalidArgument:
+		return CodeInvalidRequest
+	case backend.ErrPreconditionFailed:
+		return CodePreconditionFailed
+	case backend.ErrUnavailable, context.Canceled:
+		return CodeUnavailable
+	case context.DeadlineExceeded:
+		return CodeTimeout
+	case backend.ErrNotImplemented:
+		return CodeNotImplemented
+	}
+	if _, ok := errors.Cause(err).(invalidFields); ok {
+		return CodeInvalidFields
+	}
+	return CodeInternal
+}
+
+// ProblemOf converts err returned by backends to a Problem. Its detail is
+// what the error means rather than its message.
+func ProblemOf(err error) *Problem {
+	code := ErrorCode(err)
+	p := &Problem{Status: StatusCode(err), Code: code,
+		Detail: problemDetails[code]}
+	if fields, ok := errors.Cause(err).(invalidFields); ok {
+		p.Errors = fields
+	} else if p.Status < 500 && p.Detail == "" {
+		// Messages of invalid requests are of handlers
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// WriteProblem writes p as application/problem+json. Type and Title are
+// derived from Code if they're empty, while Instance is the path of r.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Type == "" {
+		p.Type = problemTypePrefix + p.Code
+	}
+	if p.Title == "" {
+		p.Title = problemTitles[p.Code]
+	}
+	p.Instance = r.URL.Path
+	p.RequestID = RequestIDFrom(r.Context())
+	bs, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	w.Write(bs)
+}
+
+// writeBadRequest writes 400 of err, which tells every invalid field if it's
+// invalidFields.
+func writeBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	p := &Problem{Status: 400, Code: CodeInvalidRequest, Detail: err.Error()}
+	if fields, ok := errors.Cause(err).(invalidFields); ok {
+		p.Code = CodeInvalidFields
+		p.Detail = problemDetails[CodeInvalidFields]
+		p.Errors = fields
+	}
+	WriteProblem(w, r, p)
+}
+
+// NotFound writes 404 for routes not found.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, &Problem{Status: 404, Code: CodeNotFound,
+		Detail: "no such resource"})
+}
+
+// MethodNotAllowed writes 405 for routes not allowing the method of r.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, &Problem{Status: 405, Code: CodeMethodNotAllowed,
+		Detail: r.Method + " isn't allowed"})
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the id of the request, which problems
+// tell.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the id of the request carried by ctx, or "" if it's
+// absent.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// problemOf decodes the problem written to writer.
+func problemOf(t *testing.T, writer *httptest.ResponseRecorder) *Problem {
+	assert.Equal(t, problemContentType, writer.Header().Get("Content-Type"))
+	var p Problem
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &p))
+	return &p
+}
+
+func TestProblemOf(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   string
+		detail string
+	}{
+		{backend.ErrNotFound, CodeNotFound, "no such product"},
+		{backend.ErrPreconditionFailed, CodePreconditionFailed,
+			"the product isn't of the version required"},
+		{pe.New("invalid limit"), CodeInternal, "an unexpected failure"},
+		{backend.ErrInvalidArgument, CodeInvalidRequest,
+			"wrapped: invalid argument"},
+	}
+	for _, c := range cases {
+		p := ProblemOf(pe.Wrap(c.err, "wrapped"))
+		assert.Equal(t, StatusCode(c.err), p.Status, "err:%v", c.err)
+		assert.Equal(t, c.code, p.Code, "err:%v", c.err)
+		assert.Equal(t, c.detail, p.Detail, "err:%v", c.err)
+	}
+
+	fields := invalidFields{{Field: "name", Code: CodeInvalidType,
+		Detail: "not a string"}}
+	p := ProblemOf(fields)
+	assert.Equal(t, 400, p.Status)
+	assert.Equal(t, CodeInvalidFields, p.Code)
+	assert.Equal(t, []FieldError(fields), p.Errors)
+}
+
+func TestWriteProblem(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/products/001", nil)
+	request = request.WithContext(WithRequestID(request.Context(), "req-1"))
+	writer := httptest.NewRecorder()
+	WriteProblem(writer, request, ProblemOf(backend.ErrNotFound))
+	assert.Equal(t, 404, writer.Code)
+	assert.Equal(t, &Problem{
+		Type:      "urn:icecream:problem:not_found",
+		Title:     "Not found",
+		Status:    404,
+		Detail:    "no such product",
+		Instance:  "/products/001",
+		Code:      CodeNotFound,
+		RequestID: "req-1",
+	}, problemOf(t, writer))
+
+	// Every invalid field is told
+	writer = httptest.NewRecorder()
+	writeBadRequest(writer, request, pe.WithStack(invalidFields{
+		{Field: "name", Code: CodeInvalidType, Detail: "not a string"},
+		{Field: "unknown", Code: CodeUnknownField, Detail: "unknown field"},
+	}))
+	assert.Equal(t, 400, writer.Code)
+	p := problemOf(t, writer)
+	assert.Equal(t, CodeInvalidFields, p.Code)
+	assert.Len(t, p.Errors, 2)
+
+	writer = httptest.NewRecorder()
+	writeBadRequest(writer, request, fmt.Errorf("invalid limit"))
+	p = problemOf(t, writer)
+	assert.Equal(t, CodeInvalidRequest, p.Code)
+	assert.Equal(t, "invalid limit", p.Detail)
+	assert.Empty(t, p.Errors)
+}
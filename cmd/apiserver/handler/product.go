@@ -1,54 +1,130 @@
 package handler
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"github.com/cfchou/icecream/cmd/apiserver/openapi"
+	"github.com/cfchou/icecream/pkg/backend"
 	"github.com/cfchou/icecream/pkg/backend/model"
 	"github.com/gorilla/mux"
 	"github.com/inconshreveable/log15"
 	"github.com/pkg/errors"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
-// ProductBackend is an interface for backends capable of accessing Product
+// ProductBackend is an interface for backends capable of accessing Product.
+// Every method honors cancellation and the deadline of ctx. Products read
+// carry their versions, which increase by 1 on every write. Checking the
+// version and writing is atomic in the *IfMatch methods.
 type ProductBackend interface {
 	// Create exclusively creates product. Success only if no Product with the
 	// same ProductId existed.
-	Create(product *model.Product) error
+	Create(ctx context.Context, product *model.Product) error
 
 	// Read finds the Product with the given productID. Return error if not found.
-	Read(productID string) (*model.Product, error)
+	Read(ctx context.Context, productID string) (*model.Product, error)
 
 	// ReadMany reads a page of products. Cursor is from last ReadMany and
 	// represents the end of the previous page. Limit is the number of Products
 	// that will be returned in a page. If cursor is empty then ReadMany begins
-	// from the first page. Limit must be larger than 0. Return error if no
-	// product read.
-	ReadMany(cursor string, limit int) (*model.Products, error)
+	// from the first page. Limit must be larger than 0. Filter, if not nil,
+	// selects products read. Order sorts them, it must be the same for every
+	// page. Return backend.ErrInvalidArgument if cursor is malformed. The page
+	// is empty if no more products are read, and only the last page has no
+	// cursor.
+	ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error)
 
 	// Update updates product. Success only if a Product with the same ProductId
 	// existed. Return error if not existed.
-	Update(product *model.Product) error
+	Update(ctx context.Context, product *model.Product) error
 
-	// UpdatePartial updates product. Success only if a Product with the same
-	// ProductId existed. Return error if not existed.
-	UpdatePartial(productID string, kvs map[string]interface{}) error
+	// UpdatePartial updates fields of product given in kvs, keyed by their
+	// names in json. A nil value resets the field to its zero value. Success
+	// only if a Product with the same ProductId existed. Return error if not
+	// existed.
+	UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error
 
 	// Upsert inserts product. If a Product with the same productID existed
 	// already, then a replacement is performed.
-	Upsert(product *model.Product) error
+	Upsert(ctx context.Context, product *model.Product) error
 
-	// Delete the Product with productID
-	Delete(productID string) error
+	// Delete moves the Product with productID to the trash, where it replaces
+	// one trashed earlier with the same productID. Trashed Products are not
+	// read and don't block creating the same productID.
+	Delete(ctx context.Context, productID string) error
+
+	// UpdateIfMatch updates product like Update but only if the version of
+	// the stored Product is version. Return backend.ErrPreconditionFailed if
+	// not matched.
+	UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error
+
+	// UpdatePartialIfMatch updates product like UpdatePartial but only if the
+	// version of the stored Product is version. Return
+	// backend.ErrPreconditionFailed if not matched.
+	UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error
+
+	// DeleteIfMatch deletes the Product with productID only if its version is
+	// version. Return backend.ErrPreconditionFailed if not matched.
+	DeleteIfMatch(ctx context.Context, productID string, version int64) error
+
+	// ReadRevisions reads revisions of the Product with productID, i.e. its
+	// prior versions kept by Upsert, Update, UpdatePartial and Delete. They
+	// are empty if the Product has never been superseded.
+	ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error)
+
+	// ReadRevision reads the revision of the Product with productID. Return
+	// error if not found.
+	ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error)
+
+	// ReadTrash reads a page of trashed products ordered by productId. Cursor
+	// is from last ReadTrash. Limit must be larger than 0. The cursor of the
+	// last page is empty.
+	ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error)
+
+	// RestoreTrashed moves the trashed Product with productID back, as a new
+	// version. Return backend.ErrAlreadyExists if a Product with the same
+	// productID has been created since.
+	RestoreTrashed(ctx context.Context, productID string) error
+
+	// PurgeTrashed permanently deletes the trashed Product with productID.
+	PurgeTrashed(ctx context.Context, productID string) error
+
+	// PurgeTrashedBefore permanently deletes Products trashed before t and
+	// returns the number of them.
+	PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+// ProductCounter is an optional interface of ProductBackend for backends
+// capable of counting Product.
+type ProductCounter interface {
+	// Count counts Products matching filter. Return
+	// backend.ErrNotImplemented if the backend turns out to be incapable.
+	Count(ctx context.Context, filter *model.Filter) (int64, error)
 }
 
+var (
+	// document describes products written, which are validated against
+	// its schemas.
+	document      = openapi.CreateDocument()
+	productSchema = openapi.Component("Product")
+	patchSchema   = openapi.Component("ProductPatch")
+)
+
 // ProductHandler provides http handlers for various methods.
 type ProductHandler struct {
 	log         log15.Logger
 	limitToRead int
 	backend     ProductBackend
+	// cursorSecret signs cursors given to clients.
+	cursorSecret []byte
 }
 
 // HandleGet reads a Product with the given productID retrieved
@@ -58,75 +134,185 @@ func (h *ProductHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	productID, ok := params["productID"]
 	if !ok {
 		// Bad Request
-		w.WriteHeader(400)
+		writeBadRequest(w, r, errors.New("missing productID"))
 		return
 	}
-	product, err := h.backend.Read(productID)
+	product, err := h.backend.Read(r.Context(), productID)
 	if err != nil {
-		// Not Found
-		w.WriteHeader(404)
-		w.Write([]byte(err.Error()))
+		h.writeError(w, r, err)
 		return
 	}
 	bs, err := json.Marshal(product)
 	if err != nil {
 		// Internal Server Error
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		h.writeError(w, r, err)
+		return
+	}
+	var etag string
+	if product.Version > 0 {
+		etag = formatETag(product.Version)
+	}
+	if notModified(w, r, etag, product.UpdatedAt) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(bs)
 }
 
-// HandleGetMany reads a page of products. Query parameters may include "cursor"
-// and "limit". Cursor is from last HandleGetMany and represents the end of the
-// previous page. Limit is the number of Products that will be returned in a
-// page. If cursor is empty then ReadMany begins from the first page. Limit must
-// be larger than 0.
+// HandleGetMany reads a page of products. Query parameters may include "cursor",
+// "limit" and "sort". Cursor is from last HandleGetMany and represents the end
+// of the previous page. Limit is the number of Products that will be returned
+// in a page. If cursor is empty then ReadMany begins from the first page. Limit
+// must be larger than 0. Sort is a field of model.SortFields, prefixed by "-"
+// for the descending order, and must be the same for every page. Other
+// parameters filter products, see filterOf. The last page has no cursor and
+// may be empty. If "count" is true, the page has the total of filtered
+// products, which fails with 501 if the backend isn't a ProductCounter. URLs of
+// the first and the next page are in the page and Link headers. The page is
+// served with ETag and Last-Modified, the latter doesn't reflect deletions so
+// clients should prefer If-None-Match.
 func (h *ProductHandler) HandleGetMany(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
-	cursor := qs.Get("cursor")
-	limit := qs.Get("limit")
-	var limitToRead = h.limitToRead
-	if limit != "" {
-		n, err := strconv.Atoi(limit)
-		if err != nil {
+	order := model.Sort(qs.Get("sort"))
+	if !order.IsValid() {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("invalid sort"))
+		return
+	}
+	cursor, err := h.decodeCursor(order, qs.Get("cursor"))
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	limitToRead, err := h.limitOf(qs.Get("limit"))
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	filter, err := filterOf(qs)
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	count := false
+	if c := qs.Get("count"); c != "" {
+		if count, err = strconv.ParseBool(c); err != nil {
 			// Bad Request
-			w.WriteHeader(400)
-			w.Write([]byte(err.Error()))
+			writeBadRequest(w, r, errors.New("invalid count"))
 			return
 		}
-		if n < 1 {
-			// Bad Request
-			w.WriteHeader(400)
-			w.Write([]byte("Invalid limit"))
+	}
+	var counter ProductCounter
+	if count {
+		var ok bool
+		if counter, ok = h.backend.(ProductCounter); !ok {
+			h.writeError(w, r, errors.WithStack(backend.ErrNotImplemented))
 			return
-		} else if n > limitToRead {
-			h.log.Warn("limit exceeds limitToRead")
-		} else {
-			limitToRead = n
 		}
 	}
-	mps, err := h.backend.ReadMany(cursor, limitToRead)
+	mps, err := h.backend.ReadMany(r.Context(), cursor, limitToRead, filter,
+		order)
 	if err != nil {
-		// Not Found
-		w.WriteHeader(404)
-		w.Write([]byte(err.Error()))
+		h.writeError(w, r, err)
 		return
 	}
+	if counter != nil {
+		total, err := counter.Count(r.Context(), filter)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		mps.Total = &total
+	}
+	mps.Cursor = h.encodeCursor(order, mps.Cursor)
+	mps.First = pageURL(r, "")
+	if mps.Cursor != "" {
+		mps.Next = pageURL(r, mps.Cursor)
+	}
 	bs, err := json.Marshal(mps)
 	if err != nil {
 		// Internal Server Error
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		h.writeError(w, r, err)
 		return
 	}
+	// Last-Modified misses products deleted from the page, ETag doesn't.
+	var lastModified time.Time
+	for _, p := range mps.Products {
+		if p.UpdatedAt.After(lastModified) {
+			lastModified = p.UpdatedAt
+		}
+	}
+	if notModified(w, r, hashETag(bs), lastModified) {
+		return
+	}
+	if mps.Next != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, mps.Next))
+	}
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="first"`, mps.First))
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(bs)
 	return
 }
 
+// filterOf parses parameters filtering products. "ingredient",
+// "sourcing_value" and "dietary_certification" may be repeated, as well as
+// their negative forms prefixed by "without_". "name_prefix" may not.
+// "free_from" is allergens separated by commas, and may be repeated.
+func filterOf(qs url.Values) (*model.Filter, error) {
+	var filter model.Filter
+	lists := map[string]*[]string{
+		"ingredient":                    &filter.Ingredients,
+		"without_ingredient":            &filter.WithoutIngredients,
+		"sourcing_value":                &filter.SourcingValues,
+		"without_sourcing_value":        &filter.WithoutSourcingValues,
+		"dietary_certification":         &filter.DietaryCertifications,
+		"without_dietary_certification": &filter.WithoutDietaryCertifications,
+	}
+	for k, list := range lists {
+		for _, v := range qs[k] {
+			if v == "" {
+				return nil, errors.New(fmt.Sprintf("empty %s", k))
+			}
+			*list = append(*list, v)
+		}
+	}
+	for _, v := range qs["free_from"] {
+		for _, allergen := range strings.Split(v, ",") {
+			if !model.Allergen(allergen).IsValid() {
+				return nil, errors.New("invalid free_from")
+			}
+			filter.FreeFrom = append(filter.FreeFrom,
+				model.Allergen(allergen))
+		}
+	}
+	if prefixes, ok := qs["name_prefix"]; ok {
+		if len(prefixes) != 1 || prefixes[0] == "" {
+			return nil, errors.New("invalid name_prefix")
+		}
+		filter.NamePrefix = prefixes[0]
+	}
+	return &filter, nil
+}
+
+// limitOf parses limit of a page, which is capped by limitToRead. Empty limit
+// means limitToRead.
+func (h *ProductHandler) limitOf(limit string) (int, error) {
+	if limit == "" {
+		return h.limitToRead, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return 0, errors.New("invalid limit")
+	} else if n > h.limitToRead {
+		h.log.Warn("limit exceeds limitToRead")
+		return h.limitToRead, nil
+	}
+	return n, nil
+}
+
 // HandlePost exclusively creates product. It unmarshals r.Body to
 // model.Product. Note that every field in Product is required.
 // It Success only if no Product with the same ProductId existed.
@@ -134,30 +320,25 @@ func (h *ProductHandler) HandlePost(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		// Internal Server Error
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		h.writeError(w, r, err)
 		return
 	}
 	// validate input
 	product, err := validateProductFieldsAllPresented(body)
 	if err != nil {
 		// Bad Request
-		w.WriteHeader(400)
-		w.Write([]byte(err.Error()))
+		writeBadRequest(w, r, err)
 		return
 	}
 	// productID must not be empty
 	if product.ProductID == "" {
 		// Bad Request
-		w.WriteHeader(400)
-		w.Write([]byte("invalid data"))
+		writeBadRequest(w, r, errors.New("invalid data"))
 		return
 	}
 	// Create exclusively(product must not existed)
-	if err := h.backend.Create(product); err != nil {
-		// Forbidden
-		w.WriteHeader(403)
-		w.Write([]byte(err.Error()))
+	if err := h.backend.Create(r.Context(), product); err != nil {
+		h.writeError(w, r, err)
 		return
 	}
 	// Created
@@ -169,146 +350,214 @@ func (h *ProductHandler) HandlePost(w http.ResponseWriter, r *http.Request) {
 // same productID existed already, then a replacement is performed.
 // Besides a productID retrieved from the url, it unmarshals r.Body to
 // model.Product. Note that every field in Product is required.
+// With "If-None-Match: *", it only creates. With "If-Match", it only replaces
+// the product of the given ETag, or any existing one if it's "*".
 func (h *ProductHandler) HandlePut(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, ok := params["productID"]
 	if !ok {
 		// Bad Request
-		w.WriteHeader(400)
+		writeBadRequest(w, r, errors.New("missing productID"))
 		return
 	}
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		// Internal Server Error
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		h.writeError(w, r, err)
 		return
 	}
 	// validate input
 	product, err := validateProductFieldsAllPresented(body)
 	if err != nil {
 		// Bad Request
-		w.WriteHeader(400)
-		w.Write([]byte(err.Error()))
+		writeBadRequest(w, r, err)
 		return
 	}
 	if product.ProductID != "" && product.ProductID != productID {
 		// Bad Request
-		w.WriteHeader(400)
-		w.Write([]byte("invalid data"))
+		writeBadRequest(w, r, errors.New("invalid data"))
 		return
 	}
 	// sets product.productID if it's empty
 	product.ProductID = productID
 
-	// Upsert to ensure idempotent
-	if err := h.backend.Upsert(product); err != nil {
-		// Forbidden
-		w.WriteHeader(403)
-		w.Write([]byte(err.Error()))
+	version, err := ifMatch(r)
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
 		return
 	}
-	// Created
-	w.WriteHeader(201)
-	return
+	createOnly, err := ifNoneMatchAny(r)
+	if err != nil || (createOnly && version != 0) {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("invalid If-Match or If-None-Match"))
+		return
+	}
+	switch {
+	case createOnly:
+		if err := h.backend.Create(r.Context(), product); err != nil {
+			if errors.Cause(err) == backend.ErrAlreadyExists {
+				err = errors.Wrap(backend.ErrPreconditionFailed, err.Error())
+			}
+			h.writeError(w, r, err)
+			return
+		}
+		w.Header().Set("ETag", formatETag(1))
+		// Created
+		w.WriteHeader(201)
+	case version == anyVersion:
+		if err := h.backend.Update(r.Context(), product); err != nil {
+			h.writeError(w, r, conditionalError(err))
+			return
+		}
+		w.WriteHeader(200)
+	case version != 0:
+		err := h.backend.UpdateIfMatch(r.Context(), product, version)
+		if err != nil {
+			h.writeError(w, r, conditionalError(err))
+			return
+		}
+		w.Header().Set("ETag", formatETag(version+1))
+		w.WriteHeader(200)
+	default:
+		// Upsert to ensure idempotent
+		if err := h.backend.Upsert(r.Context(), product); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		// Created
+		w.WriteHeader(201)
+	}
 }
 
+// HandlePatch updates fields of a product given in r.Body, which is a JSON
+// Merge Patch unless it's a JSON Patch by its Content-Type. With "If-Match",
+// it only updates the product of the given ETag, or any existing one if it's
+// "*".
 func (h *ProductHandler) HandlePatch(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, ok := params["productID"]
 	if !ok {
 		// Bad Request
-		w.WriteHeader(400)
+		writeBadRequest(w, r, errors.New("missing productID"))
 		return
 	}
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		// Internal Server Error
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
-		return
-	}
-
-	var input map[string]interface{}
-	if err := json.Unmarshal(body, &input); err != nil {
-		// Bad Request
-		w.WriteHeader(400)
-		w.Write([]byte("invalid data"))
-		return
-	}
-
-	if pid, ok := input["productId"]; ok && pid != productID {
-		// Bad Request
-		w.WriteHeader(400)
-		w.Write([]byte("invalid data"))
+		h.writeError(w, r, err)
 		return
 	}
-
-	if err := h.backend.UpdatePartial(productID, input); err != nil {
-		// Forbidden
-		w.WriteHeader(403)
-		w.Write([]byte(err.Error()))
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == jsonPatchContentType {
+		h.handleJSONPatch(w, r, productID, body)
 		return
 	}
-	w.WriteHeader(200)
-	return
+	h.handleMergePatch(w, r, productID, body)
 }
 
-// ProductHandle the Product with productID retrieved from the url.
+// HandleDelete moves the Product with productID retrieved from the url to the
+// trash. With "If-Match", it only deletes the product of the given ETag.
 func (h *ProductHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, ok := params["productID"]
 	if !ok {
 		// Bad Request
-		w.WriteHeader(400)
+		writeBadRequest(w, r, errors.New("missing productID"))
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
 		return
 	}
-	if err := h.backend.Delete(productID); err != nil {
-		// Forbidden
-		w.WriteHeader(403)
-		w.Write([]byte(err.Error()))
+	switch version {
+	case 0:
+		err = h.backend.Delete(r.Context(), productID)
+	case anyVersion:
+		err = conditionalError(h.backend.Delete(r.Context(), productID))
+	default:
+		err = conditionalError(h.backend.DeleteIfMatch(r.Context(), productID,
+			version))
+	}
+	if err != nil {
+		h.writeError(w, r, err)
 		return
 	}
 }
 
-// Sanity check(inefficient)
-// TODO: reflect to check data matching names and types of product fields.
+// validateProductFieldsAllPresented validates data against the schema Product
+// of the OpenAPI document and normalizes derived fields. Every invalid field
+// is returned as invalidFields.
 func validateProductFieldsAllPresented(data []byte) (*model.Product, error) {
 	var input map[string]interface{}
 	var product model.Product
-	if err := json.Unmarshal(data, &input); err != nil {
-		return nil, err
+	if err := json.Unmarshal(data, &input); err != nil || input == nil {
+		return nil, errors.New("invalid data")
 	}
-
-	// Check if input has only keys in keyMap
-	keyMap := map[string]int8{
-		"productId": 1, "name": 1, "image_closed": 1, "image_open": 1,
-		"description": 1, "story": 1, "sourcing_values": 1, "ingredients": 1,
-		"allergy_info": 1, "dietary_certifications": 1,
+	invalid := document.Validate(productSchema, "", input)
+	if len(invalid) == 0 {
+		// Fields managed by backends are allowed, so that what's read can be
+		// written back, but ignored.
+		deleteReadOnly(productSchema, input)
+		// "structured_ingredients" may replace "ingredients".
+		invalid = normalizeFields(input)
 	}
-	for k := range input {
-		if _, ok := keyMap[k]; !ok {
-			return nil, errors.New(fmt.Sprintf("extra field:%s", k))
-		}
+	if len(invalid) > 0 {
+		invalid.Sort()
+		return nil, invalid
 	}
-	// Check if input missing any key
-	for k := range keyMap {
-		if _, ok := input[k]; !ok {
-			return nil, errors.New(fmt.Sprintf("missing field:%s", k))
-		}
+	bs, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(data, &product); err != nil {
+	if err := json.Unmarshal(bs, &product); err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
+// deleteReadOnly deletes fields of input read-only by s.
+func deleteReadOnly(s *openapi.Schema, input map[string]interface{}) {
+	for k, prop := range document.Resolve(s).Properties {
+		if document.Resolve(prop).ReadOnly {
+			delete(input, k)
+		}
+	}
+}
+
+// normalizeFields normalizes derived fields of input written to backends, and
+// returns those invalid.
+func normalizeFields(input map[string]interface{}) invalidFields {
+	var invalid invalidFields
+	if err := normalizeIngredients(input); err != nil {
+		invalid = append(invalid, FieldError{Field: "structured_ingredients",
+			Code: CodeInvalidValue, Detail: err.Error()})
+	}
+	if err := normalizeAllergens(input); err != nil {
+		invalid = append(invalid, FieldError{Field: "allergens",
+			Code: CodeInvalidValue, Detail: err.Error()})
+	}
+	return invalid
+}
+
 // CreateProductHandler creates ProductBackend with ProductBackend. Limit is the
-// max number of products read in a page.
-func CreateProductHandler(productBackend ProductBackend, limit int) *ProductHandler {
-	return &ProductHandler{
-		log:         log15.New("module", "handler.product"),
-		limitToRead: limit,
-		backend:     productBackend,
+// max number of products read in a page. CursorSecret signs cursors. If it's
+// empty, a random one is generated and cursors are valid only until the
+// process exits.
+func CreateProductHandler(productBackend ProductBackend, limit int, cursorSecret []byte) *ProductHandler {
+	h := &ProductHandler{
+		log:          log15.New("module", "handler.product"),
+		limitToRead:  limit,
+		backend:      productBackend,
+		cursorSecret: cursorSecret,
+	}
+	if len(h.cursorSecret) == 0 {
+		h.cursorSecret = make([]byte, 32)
+		if _, err := rand.Read(h.cursorSecret); err != nil {
+			panic(err)
+		}
 	}
+	return h
 }
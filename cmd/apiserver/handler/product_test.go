@@ -3,16 +3,18 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
 	"github.com/cfchou/icecream/pkg/backend/model"
 	"github.com/gorilla/mux"
+	pe "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestProductHandler_HandleGet(t *testing.T) {
@@ -20,9 +22,9 @@ func TestProductHandler_HandleGet(t *testing.T) {
 
 	productID := "001"
 	product := &model.Product{ProductID: productID}
-	mB.On("Read", productID).Return(product, nil)
+	mB.On("Read", mock.Anything, productID).Return(product, nil)
 
-	ph := CreateProductHandler(mB, 10)
+	ph := CreateProductHandler(mB, 10, nil)
 	r := mux.NewRouter()
 	r.Methods("GET").Path("/products/{productID}").HandlerFunc(ph.HandleGet)
 
@@ -42,9 +44,9 @@ func TestProductHandler_HandleGet_404CausedByBackend(t *testing.T) {
 
 	productID := "001"
 
-	mB.On("Read", productID).
-		Return(nil, fmt.Errorf("any error"))
-	ph := CreateProductHandler(mB, 10)
+	mB.On("Read", mock.Anything, productID).
+		Return(nil, pe.WithStack(backend.ErrNotFound))
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("GET").Path("/products/{productID}").HandlerFunc(ph.HandleGet)
@@ -65,8 +67,8 @@ func TestProductHandler_HandleGetMany(t *testing.T) {
 		},
 	}
 
-	mB.On("ReadMany", "", 10).Return(products, nil)
-	ph := CreateProductHandler(mB, 10)
+	mB.On("ReadMany", mock.Anything, "", 10, &model.Filter{}, model.Sort("")).Return(products, nil)
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
@@ -82,20 +84,153 @@ func TestProductHandler_HandleGetMany(t *testing.T) {
 	assert.EqualValues(t, *products, result)
 }
 
-func TestProductHandler_HandleGetMany_404CausedByBackend(t *testing.T) {
+func TestProductHandler_HandleGetMany_EmptyLastPage(t *testing.T) {
 	mB := &mocks.ProductBackend{}
 
-	mB.On("ReadMany", "", 10).
-		Return(nil, fmt.Errorf("any error"))
-	ph := CreateProductHandler(mB, 10)
+	mB.On("ReadMany", mock.Anything, "", 10, &model.Filter{}, model.Sort("")).
+		Return(&model.Products{Products: []model.Product{}}, nil)
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
 
 	writer := httptest.NewRecorder()
-	request, _ := http.NewRequest("GET", "/products/", nil)
+	request, _ := http.NewRequest("GET", "http://example.com/products/", nil)
 	r.ServeHTTP(writer, request)
-	assert.Equal(t, 404, writer.Code)
+	assert.Equal(t, 200, writer.Code)
+	assert.JSONEq(t, `{"first": "http://example.com/products/",
+		"products": []}`, writer.Body.String())
+	assert.Equal(t, []string{`<http://example.com/products/>; rel="first"`},
+		writer.Header()["Link"])
+}
+
+// countableBackend is a ProductBackend that is also a ProductCounter.
+type countableBackend struct {
+	*mocks.ProductBackend
+	*mocks.ProductCounter
+}
+
+func TestProductHandler_HandleGetMany_CountLinks(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+	mC := &mocks.ProductCounter{}
+
+	filter := &model.Filter{Ingredients: []string{"cocoa"}}
+	mB.On("ReadMany", mock.Anything, "", 2, filter, model.Sort("name")).
+		Return(&model.Products{
+			Cursor:   "cursor of backend",
+			Products: []model.Product{{ProductID: "001"}, {ProductID: "002"}},
+		}, nil)
+	mC.On("Count", mock.Anything, filter).Return(int64(5), nil)
+	ph := CreateProductHandler(countableBackend{mB, mC}, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "http://example.com/products/"+
+		"?ingredient=cocoa&sort=name&limit=2&count=true", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	var result model.Products
+	json.Unmarshal(writer.Body.Bytes(), &result)
+	if assert.NotNil(t, result.Total) {
+		assert.Equal(t, int64(5), *result.Total)
+	}
+	token := ph.encodeCursor("name", "cursor of backend")
+	next := "http://example.com/products/?count=true&cursor=" + token +
+		"&ingredient=cocoa&limit=2&sort=name"
+	first := "http://example.com/products/?count=true&ingredient=cocoa" +
+		"&limit=2&sort=name"
+	assert.Equal(t, next, result.Next)
+	assert.Equal(t, first, result.First)
+	assert.Equal(t, []string{"<" + next + `>; rel="next"`,
+		"<" + first + `>; rel="first"`}, writer.Header()["Link"])
+
+	// The cursor is replaced rather than added.
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", next, nil)
+	mB.On("ReadMany", mock.Anything, "cursor of backend", 2, filter,
+		model.Sort("name")).Return(&model.Products{
+		Products: []model.Product{{ProductID: "003"}},
+	}, nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	result = model.Products{}
+	json.Unmarshal(writer.Body.Bytes(), &result)
+	assert.Empty(t, result.Next)
+	assert.Equal(t, first, result.First)
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/products/?count=maybe", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 400, writer.Code)
+
+	// Counting is optional to backends.
+	ph = CreateProductHandler(mB, 10, nil)
+	r = mux.NewRouter()
+	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/products/?count=true", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 501, writer.Code)
+}
+
+func TestProductHandler_HandleGetMany_Cursor(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("ReadMany", mock.Anything, "", 2, &model.Filter{}, model.Sort("-name")).
+		Return(&model.Products{
+			Cursor:   "cursor of backend",
+			Products: []model.Product{{ProductID: "001"}, {ProductID: "002"}},
+		}, nil)
+	mB.On("ReadMany", mock.Anything, "cursor of backend", 2, &model.Filter{},
+		model.Sort("-name")).
+		Return(&model.Products{
+			Products: []model.Product{{ProductID: "003"}},
+		}, nil)
+	ph := CreateProductHandler(mB, 10, []byte("secret"))
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
+	get := func(qs string) (int, *model.Products) {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/products/?"+qs, nil)
+		r.ServeHTTP(writer, request)
+		var result model.Products
+		json.Unmarshal(writer.Body.Bytes(), &result)
+		return writer.Code, &result
+	}
+
+	code, page := get("sort=-name&limit=2")
+	assert.Equal(t, 200, code)
+	token := page.Cursor
+	// Opaque
+	assert.NotEmpty(t, token)
+	assert.NotContains(t, token, "backend")
+
+	code, page = get("sort=-name&limit=2&cursor=" + token)
+	assert.Equal(t, 200, code)
+	if assert.Len(t, page.Products, 1) {
+		assert.Equal(t, "003", page.Products[0].ProductID)
+	}
+	assert.Empty(t, page.Cursor)
+
+	// A token is signed by the secret of the handler.
+	other := CreateProductHandler(mB, 10, []byte("other secret"))
+	assert.Equal(t, token, ph.encodeCursor("-name", "cursor of backend"))
+	assert.NotEqual(t, token, other.encodeCursor("-name", "cursor of backend"))
+
+	for _, qs := range []string{
+		"sort=size",
+		"sort=name&cursor=" + token,
+		"cursor=" + token,
+		"sort=-name&cursor=" + token + "x",
+		"sort=-name&cursor=" + token[1:],
+		"sort=-name&cursor=5bbaeea1246ed82dc66b2603",
+	} {
+		code, _ := get(qs)
+		assert.Equal(t, 400, code, qs)
+	}
 }
 
 func TestProductHandler_HandlePost(t *testing.T) {
@@ -104,8 +239,8 @@ func TestProductHandler_HandlePost(t *testing.T) {
 	productID := "001"
 	product := &model.Product{ProductID: productID}
 
-	mB.On("Create", mock.Anything).Return(nil)
-	ph := CreateProductHandler(mB, 10)
+	mB.On("Create", mock.Anything, mock.Anything).Return(nil)
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("POST").Path("/products/").HandlerFunc(ph.HandlePost)
@@ -117,14 +252,15 @@ func TestProductHandler_HandlePost(t *testing.T) {
 	assert.Equal(t, 201, writer.Code)
 }
 
-func TestProductHandler_HandlePost_403CausedByBackend(t *testing.T) {
+func TestProductHandler_HandlePost_409CausedByBackend(t *testing.T) {
 	mB := &mocks.ProductBackend{}
 
 	productID := "001"
 	product := &model.Product{ProductID: productID}
 
-	mB.On("Create", mock.Anything).Return(fmt.Errorf("any error"))
-	ph := CreateProductHandler(mB, 10)
+	mB.On("Create", mock.Anything, mock.Anything).
+		Return(pe.WithStack(backend.ErrAlreadyExists))
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("POST").Path("/products/").HandlerFunc(ph.HandlePost)
@@ -133,7 +269,7 @@ func TestProductHandler_HandlePost_403CausedByBackend(t *testing.T) {
 	bs, _ := json.Marshal(product)
 	request, _ := http.NewRequest("POST", "/products/", bytes.NewBuffer(bs))
 	r.ServeHTTP(writer, request)
-	assert.Equal(t, 403, writer.Code)
+	assert.Equal(t, 409, writer.Code)
 }
 
 func TestProductHandler_HandlePut(t *testing.T) {
@@ -142,8 +278,8 @@ func TestProductHandler_HandlePut(t *testing.T) {
 	productID := "001"
 	product := &model.Product{ProductID: productID}
 
-	mB.On("Upsert", mock.Anything).Return(nil)
-	ph := CreateProductHandler(mB, 10)
+	mB.On("Upsert", mock.Anything, mock.Anything).Return(nil)
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("PUT").Path("/products/{productID}").HandlerFunc(ph.HandlePut)
@@ -155,14 +291,15 @@ func TestProductHandler_HandlePut(t *testing.T) {
 	assert.Equal(t, 201, writer.Code)
 }
 
-func TestProductHandler_HandlePut_403CausedByBackend(t *testing.T) {
+func TestProductHandler_HandlePut_503CausedByBackend(t *testing.T) {
 	mB := &mocks.ProductBackend{}
 
 	productID := "001"
 	product := &model.Product{ProductID: productID}
 
-	mB.On("Upsert", mock.Anything).Return(fmt.Errorf("any error"))
-	ph := CreateProductHandler(mB, 10)
+	mB.On("Upsert", mock.Anything, mock.Anything).
+		Return(pe.WithStack(backend.ErrUnavailable))
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("PUT").Path("/products/{productID}").HandlerFunc(ph.HandlePut)
@@ -171,7 +308,7 @@ func TestProductHandler_HandlePut_403CausedByBackend(t *testing.T) {
 	bs, _ := json.Marshal(product)
 	request, _ := http.NewRequest("PUT", "/products/"+productID, bytes.NewBuffer(bs))
 	r.ServeHTTP(writer, request)
-	assert.Equal(t, 403, writer.Code)
+	assert.Equal(t, 503, writer.Code)
 }
 
 func TestProductHandler_HandlePatch(t *testing.T) {
@@ -180,8 +317,8 @@ func TestProductHandler_HandlePatch(t *testing.T) {
 	productID := "001"
 	product := &model.Product{ProductID: productID}
 
-	mB.On("UpdatePartial", productID, mock.Anything).Return(nil)
-	ph := CreateProductHandler(mB, 10)
+	mB.On("UpdatePartial", mock.Anything, productID, mock.Anything).Return(nil)
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("PATCH").Path("/products/{productID}").HandlerFunc(ph.HandlePatch)
@@ -193,15 +330,15 @@ func TestProductHandler_HandlePatch(t *testing.T) {
 	assert.Equal(t, 200, writer.Code)
 }
 
-func TestProductHandler_HandlePatch_403CausedByBackend(t *testing.T) {
+func TestProductHandler_HandlePatch_400CausedByBackend(t *testing.T) {
 	mB := &mocks.ProductBackend{}
 
 	productID := "001"
 	product := &model.Product{ProductID: productID}
 
-	mB.On("UpdatePartial", productID, mock.Anything).
-		Return(fmt.Errorf("any error"))
-	ph := CreateProductHandler(mB, 10)
+	mB.On("UpdatePartial", mock.Anything, productID, mock.Anything).
+		Return(pe.WithStack(backend.ErrInvalidArgument))
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("PATCH").Path("/products/{productID}").HandlerFunc(ph.HandlePatch)
@@ -210,7 +347,7 @@ func TestProductHandler_HandlePatch_403CausedByBackend(t *testing.T) {
 	bs, _ := json.Marshal(product)
 	request, _ := http.NewRequest("PATCH", "/products/"+productID, bytes.NewBuffer(bs))
 	r.ServeHTTP(writer, request)
-	assert.Equal(t, 403, writer.Code)
+	assert.Equal(t, 400, writer.Code)
 }
 
 func TestProductHandler_HandleDelete(t *testing.T) {
@@ -218,8 +355,26 @@ func TestProductHandler_HandleDelete(t *testing.T) {
 
 	productID := "001"
 
-	mB.On("Delete", productID).Return(nil)
-	ph := CreateProductHandler(mB, 10)
+	mB.On("Delete", mock.Anything, productID).Return(nil)
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("DELETE").Path("/products/{productID}").HandlerFunc(ph.HandleDelete)
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("DELETE", "/products/"+productID, nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+}
+
+func TestProductHandler_HandleDelete_404CausedByBackend(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+
+	mB.On("Delete", mock.Anything, productID).
+		Return(pe.WithStack(backend.ErrNotFound))
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("DELETE").Path("/products/{productID}").HandlerFunc(ph.HandleDelete)
@@ -227,22 +382,342 @@ func TestProductHandler_HandleDelete(t *testing.T) {
 	writer := httptest.NewRecorder()
 	request, _ := http.NewRequest("DELETE", "/products/"+productID, nil)
 	r.ServeHTTP(writer, request)
+	assert.Equal(t, 404, writer.Code)
+}
+
+func TestProductHandler_HandleGet_ETag(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	product := &model.Product{ProductID: productID, Version: 3}
+	mB.On("Read", mock.Anything, productID).Return(product, nil)
+
+	ph := CreateProductHandler(mB, 10, nil)
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/{productID}").HandlerFunc(ph.HandleGet)
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/products/"+productID, nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"3"`, writer.Header().Get("ETag"))
+}
+
+func TestProductHandler_HandlePut_IfMatch(t *testing.T) {
+	productID := "001"
+	product := &model.Product{ProductID: productID}
+	bs, _ := json.Marshal(product)
+
+	cases := []struct {
+		ifMatch string
+		err     error
+		code    int
+		etag    string
+	}{
+		{`"2"`, nil, 200, `"3"`},
+		{`"2"`, pe.WithStack(backend.ErrPreconditionFailed), 412, ""},
+		// Not existed
+		{`"2"`, pe.WithStack(backend.ErrNotFound), 412, ""},
+		{`"2"`, pe.WithStack(backend.ErrUnavailable), 503, ""},
+		// Invalid
+		{`W/"2"`, nil, 400, ""},
+		{`2`, nil, 400, ""},
+		{`"1", "2"`, nil, 400, ""},
+	}
+	for _, c := range cases {
+		mB := &mocks.ProductBackend{}
+		mB.On("UpdateIfMatch", mock.Anything, mock.Anything, int64(2)).
+			Return(c.err)
+		ph := CreateProductHandler(mB, 10, nil)
+
+		r := mux.NewRouter()
+		r.Methods("PUT").Path("/products/{productID}").HandlerFunc(ph.HandlePut)
+
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", "/products/"+productID,
+			bytes.NewBuffer(bs))
+		request.Header.Set("If-Match", c.ifMatch)
+		r.ServeHTTP(writer, request)
+		assert.Equal(t, c.code, writer.Code, "If-Match:%s", c.ifMatch)
+		assert.Equal(t, c.etag, writer.Header().Get("ETag"))
+		mB.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+	}
+}
+
+func TestProductHandler_HandlePut_IfMatchAny(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	product := &model.Product{ProductID: productID}
+
+	mB.On("Update", mock.Anything, mock.Anything).
+		Return(pe.WithStack(backend.ErrNotFound))
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("PUT").Path("/products/{productID}").HandlerFunc(ph.HandlePut)
+
+	writer := httptest.NewRecorder()
+	bs, _ := json.Marshal(product)
+	request, _ := http.NewRequest("PUT", "/products/"+productID, bytes.NewBuffer(bs))
+	request.Header.Set("If-Match", "*")
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 412, writer.Code)
+}
+
+func TestProductHandler_HandlePut_IfNoneMatch(t *testing.T) {
+	productID := "001"
+	product := &model.Product{ProductID: productID}
+	bs, _ := json.Marshal(product)
+
+	cases := []struct {
+		err  error
+		code int
+		etag string
+	}{
+		{nil, 201, `"1"`},
+		{pe.WithStack(backend.ErrAlreadyExists), 412, ""},
+	}
+	for _, c := range cases {
+		mB := &mocks.ProductBackend{}
+		mB.On("Create", mock.Anything, mock.Anything).Return(c.err)
+		ph := CreateProductHandler(mB, 10, nil)
+
+		r := mux.NewRouter()
+		r.Methods("PUT").Path("/products/{productID}").HandlerFunc(ph.HandlePut)
+
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", "/products/"+productID,
+			bytes.NewBuffer(bs))
+		request.Header.Set("If-None-Match", "*")
+		r.ServeHTTP(writer, request)
+		assert.Equal(t, c.code, writer.Code)
+		assert.Equal(t, c.etag, writer.Header().Get("ETag"))
+		mB.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+	}
+}
+
+func TestProductHandler_HandlePut_IfMatchAndIfNoneMatch(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	product := &model.Product{ProductID: productID}
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("PUT").Path("/products/{productID}").HandlerFunc(ph.HandlePut)
+
+	writer := httptest.NewRecorder()
+	bs, _ := json.Marshal(product)
+	request, _ := http.NewRequest("PUT", "/products/"+productID, bytes.NewBuffer(bs))
+	request.Header.Set("If-Match", `"1"`)
+	request.Header.Set("If-None-Match", "*")
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 400, writer.Code)
+}
+
+func TestProductHandler_HandlePatch_IfMatch(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	input := map[string]interface{}{"name": "updated"}
+
+	mB.On("UpdatePartialIfMatch", mock.Anything, productID, input, int64(1)).
+		Return(nil)
+	mB.On("UpdatePartialIfMatch", mock.Anything, productID, input, int64(2)).
+		Return(pe.WithStack(backend.ErrPreconditionFailed))
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("PATCH").Path("/products/{productID}").HandlerFunc(ph.HandlePatch)
+
+	bs, _ := json.Marshal(input)
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("PATCH", "/products/"+productID, bytes.NewBuffer(bs))
+	request.Header.Set("If-Match", `"1"`)
+	r.ServeHTTP(writer, request)
 	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"2"`, writer.Header().Get("ETag"))
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("PATCH", "/products/"+productID, bytes.NewBuffer(bs))
+	request.Header.Set("If-Match", `"2"`)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 412, writer.Code)
+
+	mB.AssertNotCalled(t, "UpdatePartial", mock.Anything, mock.Anything,
+		mock.Anything)
 }
 
-func TestProductHandler_HandleDelete_403CausedByBackend(t *testing.T) {
+func TestProductHandler_HandleDelete_IfMatch(t *testing.T) {
 	mB := &mocks.ProductBackend{}
 
 	productID := "001"
 
-	mB.On("Delete", productID).Return(fmt.Errorf("any error"))
-	ph := CreateProductHandler(mB, 10)
+	mB.On("DeleteIfMatch", mock.Anything, productID, int64(1)).Return(nil)
+	mB.On("DeleteIfMatch", mock.Anything, productID, int64(2)).
+		Return(pe.WithStack(backend.ErrNotFound))
+	ph := CreateProductHandler(mB, 10, nil)
 
 	r := mux.NewRouter()
 	r.Methods("DELETE").Path("/products/{productID}").HandlerFunc(ph.HandleDelete)
 
 	writer := httptest.NewRecorder()
 	request, _ := http.NewRequest("DELETE", "/products/"+productID, nil)
+	request.Header.Set("If-Match", `"1"`)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("DELETE", "/products/"+productID, nil)
+	request.Header.Set("If-Match", `"2"`)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 412, writer.Code)
+
+	mB.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestProductHandler_HandleGet_NotModified(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	updatedAt := time.Date(2018, 5, 1, 10, 0, 0, 500e6, time.UTC)
+	product := &model.Product{ProductID: productID, Version: 3,
+		UpdatedAt: updatedAt}
+	mB.On("Read", mock.Anything, productID).Return(product, nil)
+
+	ph := CreateProductHandler(mB, 10, nil)
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/{productID}").HandlerFunc(ph.HandleGet)
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/products/"+productID, nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	lastModified := writer.Header().Get("Last-Modified")
+	assert.Equal(t, "Tue, 01 May 2018 10:00:00 GMT", lastModified)
+
+	// If-None-Match takes precedence over If-Modified-Since
+	for _, c := range []struct {
+		ifNoneMatch, ifModifiedSince string
+		code                         int
+	}{
+		{`"3"`, "", 304},
+		{`"1", W/"3"`, "", 304},
+		{"*", "", 304},
+		{`"2"`, "", 200},
+		{`"2"`, lastModified, 200},
+		{"", lastModified, 304},
+		{"", "Tue, 01 May 2018 09:59:59 GMT", 200},
+		{"", "invalid", 200},
+	} {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/products/"+productID, nil)
+		if c.ifNoneMatch != "" {
+			request.Header.Set("If-None-Match", c.ifNoneMatch)
+		}
+		if c.ifModifiedSince != "" {
+			request.Header.Set("If-Modified-Since", c.ifModifiedSince)
+		}
+		r.ServeHTTP(writer, request)
+		assert.Equal(t, c.code, writer.Code, "%+v", c)
+		assert.Equal(t, `"3"`, writer.Header().Get("ETag"))
+		if c.code == 304 {
+			assert.Equal(t, 0, writer.Body.Len())
+		}
+	}
+}
+
+func TestProductHandler_HandleGetMany_NotModified(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	products := &model.Products{
+		Products: []model.Product{
+			{ProductID: "001",
+				UpdatedAt: time.Date(2018, 5, 2, 0, 0, 0, 0, time.UTC)},
+			{ProductID: "002",
+				UpdatedAt: time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	mB.On("ReadMany", mock.Anything, "", 10, &model.Filter{}, model.Sort("")).Return(products, nil)
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/products/", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	etag := writer.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	lastModified := writer.Header().Get("Last-Modified")
+	assert.Equal(t, "Wed, 02 May 2018 00:00:00 GMT", lastModified)
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/products/", nil)
+	request.Header.Set("If-None-Match", etag)
 	r.ServeHTTP(writer, request)
-	assert.Equal(t, 403, writer.Code)
+	assert.Equal(t, 304, writer.Code)
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/products/", nil)
+	request.Header.Set("If-Modified-Since", lastModified)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 304, writer.Code)
+
+	// The page changes
+	products.Products = products.Products[:1]
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/products/", nil)
+	request.Header.Set("If-None-Match", etag)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	assert.NotEqual(t, etag, writer.Header().Get("ETag"))
+}
+
+func TestProductHandler_HandleGetMany_Filter(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	products := &model.Products{
+		Products: []model.Product{
+			{ProductID: "001"},
+		},
+	}
+	filter := &model.Filter{
+		Ingredients:                  []string{"cocoa", "cream"},
+		WithoutIngredients:           []string{"eggs"},
+		SourcingValues:               []string{"Fairtrade"},
+		WithoutSourcingValues:        []string{"Non-GMO"},
+		DietaryCertifications:        []string{"Kosher"},
+		WithoutDietaryCertifications: []string{"Halal"},
+		NamePrefix:                   "Choc",
+		FreeFrom: []model.Allergen{model.Peanuts, model.TreeNuts,
+			model.Wheat},
+	}
+	mB.On("ReadMany", mock.Anything, "", 10, filter, model.Sort("")).Return(products, nil)
+	ph := CreateProductHandler(mB, 10, nil)
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/").HandlerFunc(ph.HandleGetMany)
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/products/?ingredient=cocoa"+
+		"&ingredient=cream&without_ingredient=eggs&sourcing_value=Fairtrade"+
+		"&without_sourcing_value=Non-GMO&dietary_certification=Kosher"+
+		"&without_dietary_certification=Halal&name_prefix=Choc"+
+		"&free_from=peanuts,tree_nuts&free_from=wheat", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	mB.AssertCalled(t, "ReadMany", mock.Anything, "", 10, filter,
+		model.Sort(""))
+
+	for _, qs := range []string{"ingredient=", "name_prefix=a&name_prefix=b",
+		"name_prefix=", "free_from=", "free_from=nuts", "free_from=milk,"} {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/products/?"+qs, nil)
+		r.ServeHTTP(writer, request)
+		assert.Equal(t, 400, writer.Code, qs)
+	}
 }
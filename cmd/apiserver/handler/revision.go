@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maskAPIKey hides all but the last 4 characters of apiKey, which is enough to
+// tell keys apart without exposing them to other clients.
+func maskAPIKey(apiKey string) string {
+	if len(apiKey) <= 4 {
+		return strings.Repeat("*", len(apiKey))
+	}
+	return strings.Repeat("*", len(apiKey)-4) + apiKey[len(apiKey)-4:]
+}
+
+// revisionOf parses productID and revision retrieved from the url.
+func revisionOf(r *http.Request) (string, int64, bool) {
+	params := mux.Vars(r)
+	productID, ok := params["productID"]
+	if !ok {
+		return "", 0, false
+	}
+	revision, err := strconv.ParseInt(params["revision"], 10, 64)
+	if err != nil || revision <= 0 {
+		return "", 0, false
+	}
+	return productID, revision, true
+}
+
+// HandleGetRevisions reads revisions of a Product with the given productID
+// retrieved from the url. Revisions are empty if the Product has never been
+// replaced, updated or deleted.
+func (h *ProductHandler) HandleGetRevisions(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	productID, ok := params["productID"]
+	if !ok {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("missing productID"))
+		return
+	}
+	revisions, err := h.backend.ReadRevisions(r.Context(), productID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	for i := range revisions.Revisions {
+		rev := &revisions.Revisions[i]
+		rev.APIKey = maskAPIKey(rev.APIKey)
+	}
+	h.writeJSON(w, r, revisions)
+}
+
+// HandleGetRevision reads a revision of a Product with the given productID and
+// revision retrieved from the url.
+func (h *ProductHandler) HandleGetRevision(w http.ResponseWriter, r *http.Request) {
+	productID, revision, ok := revisionOf(r)
+	if !ok {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("invalid revision"))
+		return
+	}
+	rev, err := h.backend.ReadRevision(r.Context(), productID, revision)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	rev.APIKey = maskAPIKey(rev.APIKey)
+	h.writeJSON(w, r, rev)
+}
+
+// HandleRestore writes the Product of a revision back, which makes a new
+// version and keeps the current one as another revision. A deleted Product is
+// created again. Like HandlePut, If-Match makes it conditional.
+func (h *ProductHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	productID, revision, ok := revisionOf(r)
+	if !ok {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("invalid revision"))
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	rev, err := h.backend.ReadRevision(r.Context(), productID, revision)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	product := &rev.Product
+	switch version {
+	case 0:
+		err = h.backend.Upsert(r.Context(), product)
+	case anyVersion:
+		err = conditionalError(h.backend.Update(r.Context(), product))
+	default:
+		err = conditionalError(h.backend.UpdateIfMatch(r.Context(), product,
+			version))
+		if err == nil {
+			w.Header().Set("ETag", formatETag(version+1))
+		}
+	}
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+}
+
+// writeJSON writes v in json.
+func (h *ProductHandler) writeJSON(w http.ResponseWriter, r *http.Request,
+	v interface{}) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		// Internal Server Error
+		h.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bs)
+}
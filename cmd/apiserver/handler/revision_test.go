@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/gorilla/mux"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func revisionRouter(ph *ProductHandler) *mux.Router {
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/{productID}/revisions").
+		HandlerFunc(ph.HandleGetRevisions)
+	r.Methods("GET").Path("/products/{productID}/revisions/{revision:[0-9]+}").
+		HandlerFunc(ph.HandleGetRevision)
+	r.Methods("POST").
+		Path("/products/{productID}/revisions/{revision:[0-9]+}:restore").
+		HandlerFunc(ph.HandleRestore)
+	return r
+}
+
+func TestProductHandler_HandleGetRevisions(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	revisions := &model.Revisions{
+		Revisions: []model.Revision{
+			{Revision: 1, Version: 1, APIKey: "secretkey1",
+				Product: model.Product{ProductID: productID}},
+			{Revision: 2, Version: 2, APIKey: "key", Deleted: true,
+				Product: model.Product{ProductID: productID}},
+		},
+	}
+	mB.On("ReadRevisions", mock.Anything, productID).Return(revisions, nil)
+
+	r := revisionRouter(CreateProductHandler(mB, 10, nil))
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/products/"+productID+"/revisions",
+		nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+
+	var result model.Revisions
+	bs, _ := ioutil.ReadAll(writer.Body)
+	json.Unmarshal(bs, &result)
+	if assert.Len(t, result.Revisions, 2) {
+		// API keys are masked
+		assert.Equal(t, "******key1", result.Revisions[0].APIKey)
+		assert.Equal(t, "***", result.Revisions[1].APIKey)
+		assert.True(t, result.Revisions[1].Deleted)
+	}
+}
+
+func TestProductHandler_HandleGetRevision(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	revision := &model.Revision{Revision: 3, Version: 2,
+		Product: model.Product{ProductID: productID, Name: "old"}}
+	mB.On("ReadRevision", mock.Anything, productID, int64(3)).
+		Return(revision, nil)
+	mB.On("ReadRevision", mock.Anything, productID, int64(4)).
+		Return(nil, pe.WithStack(backend.ErrNotFound))
+
+	r := revisionRouter(CreateProductHandler(mB, 10, nil))
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET",
+		"/products/"+productID+"/revisions/3", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+
+	var result model.Revision
+	bs, _ := ioutil.ReadAll(writer.Body)
+	json.Unmarshal(bs, &result)
+	assert.Equal(t, "old", result.Product.Name)
+	assert.Equal(t, int64(2), result.Version)
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET",
+		"/products/"+productID+"/revisions/4", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 404, writer.Code)
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET",
+		"/products/"+productID+"/revisions/0", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 400, writer.Code)
+}
+
+func TestProductHandler_HandleRestore(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	revision := &model.Revision{Revision: 3, Version: 2,
+		Product: model.Product{ProductID: productID, Name: "old"}}
+	mB.On("ReadRevision", mock.Anything, productID, int64(3)).
+		Return(revision, nil)
+	mB.On("Upsert", mock.Anything, &revision.Product).Return(nil)
+
+	r := revisionRouter(CreateProductHandler(mB, 10, nil))
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST",
+		"/products/"+productID+"/revisions/3:restore", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	mB.AssertCalled(t, "Upsert", mock.Anything, &revision.Product)
+}
+
+func TestProductHandler_HandleRestore_IfMatch(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	productID := "001"
+	revision := &model.Revision{Revision: 3, Version: 2,
+		Product: model.Product{ProductID: productID, Name: "old"}}
+	mB.On("ReadRevision", mock.Anything, productID, int64(3)).
+		Return(revision, nil)
+	mB.On("UpdateIfMatch", mock.Anything, &revision.Product, int64(5)).
+		Return(nil)
+	mB.On("UpdateIfMatch", mock.Anything, &revision.Product, int64(4)).
+		Return(pe.WithStack(backend.ErrPreconditionFailed))
+
+	r := revisionRouter(CreateProductHandler(mB, 10, nil))
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST",
+		"/products/"+productID+"/revisions/3:restore", nil)
+	request.Header.Set("If-Match", `"5"`)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, `"6"`, writer.Header().Get("ETag"))
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("POST",
+		"/products/"+productID+"/revisions/3:restore", nil)
+	request.Header.Set("If-Match", `"4"`)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 412, writer.Code)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// ProductSearcher is an optional interface of ProductBackend for backends
+// capable of searching Product.
+type ProductSearcher interface {
+	// Search finds Products matching any word of query in name,
+	// description, story or ingredients, ordered by relevance. Cursor is from
+	// last Search of the same query. Limit must be larger than 0. Return
+	// backend.ErrInvalidArgument if query has no words to search, or
+	// backend.ErrNotImplemented if the backend turns out to be incapable.
+	// The page is empty if no more Products match.
+	Search(ctx context.Context, query string, cursor string, limit int) (*model.SearchResults, error)
+}
+
+// HandleSearch searches products by the query parameter "q". Parameters
+// "cursor" and "limit" page results like HandleGetMany. It fails with 501 if
+// the backend isn't a ProductSearcher.
+func (h *ProductHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	searcher, ok := h.backend.(ProductSearcher)
+	if !ok {
+		h.writeError(w, r, errors.WithStack(backend.ErrNotImplemented))
+		return
+	}
+	qs := r.URL.Query()
+	if queries := qs["q"]; len(queries) != 1 || queries[0] == "" {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("invalid q"))
+		return
+	}
+	limit, err := h.limitOf(qs.Get("limit"))
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	cursor, err := h.decodePageCursor(searchPage, qs.Get("cursor"))
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	results, err := searcher.Search(r.Context(), qs.Get("q"), cursor, limit)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	results.Cursor = h.encodePageCursor(searchPage, results.Cursor)
+	h.writeJSON(w, r, results)
+}
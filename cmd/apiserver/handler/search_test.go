@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// searchableBackend is a ProductBackend that is also a ProductSearcher.
+type searchableBackend struct {
+	*mocks.ProductBackend
+	*mocks.ProductSearcher
+}
+
+func searchRouter(ph *ProductHandler) *mux.Router {
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/search").HandlerFunc(ph.HandleSearch)
+	return r
+}
+
+func TestProductHandler_HandleSearch(t *testing.T) {
+	mS := &mocks.ProductSearcher{}
+	hits := []model.SearchHit{
+		{
+			Product:    model.Product{ProductID: "001", Name: "Cookie"},
+			Score:      10,
+			Highlights: map[string]string{"name": "<em>Cookie</em>"},
+		},
+	}
+	mS.On("Search", mock.Anything, "cookie swirl", "1", 5).Return(
+		&model.SearchResults{Cursor: "2", Hits: hits}, nil)
+	ph := CreateProductHandler(searchableBackend{&mocks.ProductBackend{}, mS},
+		10, []byte("secret"))
+	r := searchRouter(ph)
+
+	token := ph.encodePageCursor(searchPage, "1")
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET",
+		"/products/search?q=cookie+swirl&limit=5&cursor="+token, nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	var actual model.SearchResults
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &actual))
+	assert.Equal(t, model.SearchResults{
+		Cursor: ph.encodePageCursor(searchPage, "2"),
+		Hits:   hits,
+	}, actual)
+
+	for _, qs := range []string{
+		"", "q=", "q=a&q=b", "q=cookie&limit=0",
+		// Cursors must be signed ones of search
+		"q=cookie&cursor=1",
+		"q=cookie&cursor=" + token + "x",
+		"q=cookie&cursor=" + ph.encodeCursor("", "1"),
+		"q=cookie&cursor=" + ph.encodePageCursor(trashPage, "1"),
+	} {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/products/search?"+qs, nil)
+		r.ServeHTTP(writer, request)
+		assert.Equal(t, 400, writer.Code, qs)
+	}
+}
+
+func TestProductHandler_HandleSearch_NotImplemented(t *testing.T) {
+	ph := CreateProductHandler(&mocks.ProductBackend{}, 10, nil)
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/products/search?q=cookie", nil)
+	searchRouter(ph).ServeHTTP(writer, request)
+	assert.Equal(t, 501, writer.Code)
+}
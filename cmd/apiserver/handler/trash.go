@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// HandleGetTrash reads a page of trashed products. Query parameters may
+// include "cursor" and "limit" like HandleGetMany. The cursor of the last
+// page is empty.
+func (h *ProductHandler) HandleGetTrash(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	limit, err := h.limitOf(qs.Get("limit"))
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	cursor, err := h.decodePageCursor(trashPage, qs.Get("cursor"))
+	if err != nil {
+		// Bad Request
+		writeBadRequest(w, r, err)
+		return
+	}
+	trash, err := h.backend.ReadTrash(r.Context(), cursor, limit)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	trash.Cursor = h.encodePageCursor(trashPage, trash.Cursor)
+	h.writeJSON(w, r, trash)
+}
+
+// HandleRestoreTrashed moves a trashed Product with the given productID
+// retrieved from the url back. It fails with 409 if a Product with the same
+// productID has been created since.
+func (h *ProductHandler) HandleRestoreTrashed(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	productID, ok := params["productID"]
+	if !ok {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("missing productID"))
+		return
+	}
+	if err := h.backend.RestoreTrashed(r.Context(), productID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+}
+
+// HandlePurgeTrashed permanently deletes a trashed Product with the given
+// productID retrieved from the url.
+func (h *ProductHandler) HandlePurgeTrashed(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	productID, ok := params["productID"]
+	if !ok {
+		// Bad Request
+		writeBadRequest(w, r, errors.New("missing productID"))
+		return
+	}
+	if err := h.backend.PurgeTrashed(r.Context(), productID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+}
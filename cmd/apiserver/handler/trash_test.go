@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/gorilla/mux"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func trashRouter(ph *ProductHandler) *mux.Router {
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/trash/").HandlerFunc(ph.HandleGetTrash)
+	r.Methods("POST").Path("/trash/{productID}:restore").
+		HandlerFunc(ph.HandleRestoreTrashed)
+	r.Methods("DELETE").Path("/trash/{productID}").
+		HandlerFunc(ph.HandlePurgeTrashed)
+	return r
+}
+
+func TestProductHandler_HandleGetTrash(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	products := []model.TrashedProduct{
+		{Product: model.Product{ProductID: "001"}},
+		{Product: model.Product{ProductID: "002"}},
+	}
+	mB.On("ReadTrash", mock.Anything, "000", 2).Return(&model.Trash{
+		Cursor:   "002",
+		Products: products,
+	}, nil)
+
+	ph := CreateProductHandler(mB, 10, []byte("secret"))
+	r := trashRouter(ph)
+	get := func(qs string) (int, *model.Trash) {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/trash/?"+qs, nil)
+		r.ServeHTTP(writer, request)
+		var result model.Trash
+		bs, _ := ioutil.ReadAll(writer.Body)
+		json.Unmarshal(bs, &result)
+		return writer.Code, &result
+	}
+
+	token := ph.encodePageCursor(trashPage, "000")
+	code, result := get("limit=2&cursor=" + token)
+	assert.Equal(t, 200, code)
+	assert.EqualValues(t, model.Trash{
+		Cursor:   ph.encodePageCursor(trashPage, "002"),
+		Products: products,
+	}, *result)
+
+	for _, qs := range []string{
+		"limit=0",
+		"cursor=000",
+		"cursor=" + token + "x",
+		"cursor=" + ph.encodeCursor("", "000"),
+		"cursor=" + ph.encodePageCursor(searchPage, "000"),
+	} {
+		code, _ := get(qs)
+		assert.Equal(t, 400, code, qs)
+	}
+}
+
+func TestProductHandler_HandleRestoreTrashed(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("RestoreTrashed", mock.Anything, "001").Return(nil)
+	mB.On("RestoreTrashed", mock.Anything, "002").
+		Return(pe.WithStack(backend.ErrAlreadyExists))
+
+	r := trashRouter(CreateProductHandler(mB, 10, nil))
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "/trash/001:restore", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("POST", "/trash/002:restore", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 409, writer.Code)
+}
+
+func TestProductHandler_HandlePurgeTrashed_404CausedByBackend(t *testing.T) {
+	mB := &mocks.ProductBackend{}
+
+	mB.On("PurgeTrashed", mock.Anything, "001").
+		Return(pe.WithStack(backend.ErrNotFound))
+
+	r := trashRouter(CreateProductHandler(mB, 10, nil))
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("DELETE", "/trash/001", nil)
+	r.ServeHTTP(writer, request)
+	assert.Equal(t, 404, writer.Code)
+	mB.AssertCalled(t, "PurgeTrashed", mock.Anything, "001")
+}
@@ -1,12 +1,22 @@
 package middleware
 
 import (
+	"context"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/pkg/backend"
 	"github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"net/http"
 )
 
+// APIKeyBackend is an interface for backends capable of authenticating API
+// keys. Authenticate honors cancellation and the deadline of ctx.
 type APIKeyBackend interface {
-	Authenticate(apiKey string) error
+	Authenticate(ctx context.Context, apiKey string) error
 }
 
 type APIKeyMiddleWare struct {
@@ -20,26 +30,92 @@ func (m *APIKeyMiddleWare) Handle(h http.Handler) http.Handler {
 		authorization, ok := r.Header["Authorization"]
 		if !ok || len(authorization) != 1 {
 			m.log.Warn("No or invalid Authorization header")
-			w.WriteHeader(401)
-			w.Write([]byte("No or invalid Authorization header"))
+			unauthorized(w, r, "No or invalid Authorization header")
 			return
 		}
 		apiKey := authorization[0]
 		if apiKey == "" {
 			m.log.Warn("No API Key provided")
-			w.WriteHeader(401)
+			unauthorized(w, r, "No API Key provided")
 			return
 		}
-		if err := m.backend.Authenticate(apiKey); err != nil {
-			m.log.Warn("Invalid API Key")
-			w.WriteHeader(401)
+		if err := m.backend.Authenticate(r.Context(), apiKey); err != nil {
+			if errors.Cause(err) == backend.ErrNotFound {
+				m.log.Warn("Invalid API Key")
+				unauthorized(w, r, "Invalid API Key")
+				return
+			}
+			// The key can't be checked, e.g. the backend is unavailable.
+			code := handler.StatusCode(err)
+			m.log.Error("Authenticate failed", "code", code, "err", err)
+			handler.WriteProblem(w, r, handler.ProblemOf(err))
 			return
 		}
-		h.ServeHTTP(w, r)
+		// Backends record who makes writes.
+		h.ServeHTTP(w, r.WithContext(backend.WithAPIKey(r.Context(), apiKey)))
 	}
 	return http.HandlerFunc(f)
 }
 
+// unauthorized writes 401 of detail.
+func unauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+	handler.WriteProblem(w, r, &handler.Problem{Status: 401,
+		Code: handler.CodeUnauthorized, Detail: detail})
+}
+
+// UnaryInterceptor authenticates gRPC calls like Handle, by the API key in
+// the metadata "authorization".
+func (m *APIKeyMiddleWare) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := m.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, req)
+}
+
+// StreamInterceptor authenticates gRPC streams like UnaryInterceptor.
+func (m *APIKeyMiddleWare) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	ctx, err := m.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return next(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate returns ctx carrying the API key in its metadata, or a gRPC
+// status error.
+func (m *APIKeyMiddleWare) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authorization := md.Get("authorization")
+	if len(authorization) != 1 || authorization[0] == "" {
+		m.log.Warn("No or invalid authorization metadata")
+		return nil, status.Error(codes.Unauthenticated,
+			"No or invalid authorization metadata")
+	}
+	apiKey := authorization[0]
+	if err := m.backend.Authenticate(ctx, apiKey); err != nil {
+		if errors.Cause(err) == backend.ErrNotFound {
+			m.log.Warn("Invalid API Key")
+			return nil, status.Error(codes.Unauthenticated, "Invalid API Key")
+		}
+		// The key can't be checked, e.g. the backend is unavailable.
+		code := handler.GRPCCode(err)
+		m.log.Error("Authenticate failed", "code", code, "err", err)
+		return nil, handler.GRPCError(err)
+	}
+	return backend.WithAPIKey(ctx, apiKey), nil
+}
+
+// serverStream is ServerStream of ctx.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
 func CreateAPIKeyMiddleWare(backend APIKeyBackend) *APIKeyMiddleWare {
 	return &APIKeyMiddleWare{
 		log:     log15.New("module", "middleware.apikey"),
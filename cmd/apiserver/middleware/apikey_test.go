@@ -1,10 +1,16 @@
 package middleware
 
 import (
-	"fmt"
+	"context"
 	"github.com/cfchou/icecream/cmd/apiserver/mocks"
+	"github.com/cfchou/icecream/pkg/backend"
+	pe "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -18,9 +24,11 @@ func TestAPIKeyMiddleWare_Handle(t *testing.T) {
 	expected := []byte("valid apikey")
 	am := CreateAPIKeyMiddleWare(mB)
 
-	mB.On("Authenticate", apiKey).Return(nil)
+	mB.On("Authenticate", mock.Anything, apiKey).Return(nil)
 
 	f := func(w http.ResponseWriter, r *http.Request) {
+		// The key is passed to backends
+		assert.Equal(t, apiKey, backend.APIKeyFrom(r.Context()))
 		w.Write(expected)
 	}
 
@@ -62,7 +70,7 @@ func TestAPIKeyMiddleWare_Handle_401NoAuthorization(t *testing.T) {
 	assert.NotEqual(t, expected, bs)
 
 	// mB is not called
-	mB.AssertNotCalled(t, "Authenticate", mock.Anything)
+	mB.AssertNotCalled(t, "Authenticate", mock.Anything, mock.Anything)
 }
 
 func TestAPIKeyMiddleWare_Handle_401InvalidAPIKey(t *testing.T) {
@@ -73,8 +81,8 @@ func TestAPIKeyMiddleWare_Handle_401InvalidAPIKey(t *testing.T) {
 
 	am := CreateAPIKeyMiddleWare(mB)
 
-	mB.On("Authenticate", mock.Anything).
-		Return(fmt.Errorf("any error"))
+	mB.On("Authenticate", mock.Anything, mock.Anything).
+		Return(pe.WithStack(backend.ErrNotFound))
 
 	f := func(w http.ResponseWriter, r *http.Request) {
 		w.Write(expected)
@@ -88,11 +96,126 @@ func TestAPIKeyMiddleWare_Handle_401InvalidAPIKey(t *testing.T) {
 	request.Header.Set("Authorization", apiKey)
 	mux.ServeHTTP(writer, request)
 	assert.Equal(t, 401, writer.Code)
+	assert.Equal(t, "application/problem+json",
+		writer.Header().Get("Content-Type"))
 
 	// f is not called
 	bs, _ := ioutil.ReadAll(writer.Body)
 	assert.NotEqual(t, expected, bs)
+	assert.Contains(t, string(bs), `"code":"unauthorized"`)
 
 	// mB is called
-	mB.AssertCalled(t, "Authenticate", mock.Anything)
+	mB.AssertCalled(t, "Authenticate", mock.Anything, mock.Anything)
+}
+
+func TestAPIKeyMiddleWare_Handle_503Unavailable(t *testing.T) {
+	mB := &mocks.APIKeyBackend{}
+
+	apiKey := "123"
+	expected := []byte("valid apikey")
+
+	am := CreateAPIKeyMiddleWare(mB)
+
+	mB.On("Authenticate", mock.Anything, mock.Anything).
+		Return(pe.WithStack(backend.ErrUnavailable))
+
+	f := func(w http.ResponseWriter, r *http.Request) {
+		w.Write(expected)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", am.Handle(http.HandlerFunc(f)))
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("Authorization", apiKey)
+	mux.ServeHTTP(writer, request)
+	assert.Equal(t, 503, writer.Code)
+
+	// f is not called
+	bs, _ := ioutil.ReadAll(writer.Body)
+	assert.NotEqual(t, expected, bs)
+
+	// mB is called
+	mB.AssertCalled(t, "Authenticate", mock.Anything, mock.Anything)
+}
+
+func TestAPIKeyMiddleWare_UnaryInterceptor(t *testing.T) {
+	mB := &mocks.APIKeyBackend{}
+
+	am := CreateAPIKeyMiddleWare(mB)
+
+	mB.On("Authenticate", mock.Anything, "123").Return(nil)
+	mB.On("Authenticate", mock.Anything, "456").
+		Return(pe.WithStack(backend.ErrNotFound))
+	mB.On("Authenticate", mock.Anything, "789").
+		Return(pe.WithStack(backend.ErrUnavailable))
+
+	called := 0
+	next := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called++
+		// The key is passed to backends
+		assert.Equal(t, "123", backend.APIKeyFrom(ctx))
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/Service/Method"}
+
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "123"))
+	resp, err := am.UnaryInterceptor(ctx, "req", info, next)
+	assert.NoError(t, err)
+	assert.Equal(t, "req", resp)
+
+	for apiKey, code := range map[string]codes.Code{
+		"":    codes.Unauthenticated,
+		"456": codes.Unauthenticated,
+		"789": codes.Unavailable,
+	} {
+		ctx := metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs("authorization", apiKey))
+		_, err := am.UnaryInterceptor(ctx, "req", info, next)
+		assert.Equal(t, code, status.Code(err), apiKey)
+	}
+	_, err = am.UnaryInterceptor(context.Background(), "req", info, next)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// next is called only once
+	assert.Equal(t, 1, called)
+}
+
+// fakeServerStream is a ServerStream of ctx.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAPIKeyMiddleWare_StreamInterceptor(t *testing.T) {
+	mB := &mocks.APIKeyBackend{}
+
+	am := CreateAPIKeyMiddleWare(mB)
+
+	mB.On("Authenticate", mock.Anything, "123").Return(nil)
+
+	called := 0
+	next := func(srv interface{}, ss grpc.ServerStream) error {
+		called++
+		assert.Equal(t, "123", backend.APIKeyFrom(ss.Context()))
+		return nil
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/Service/Method"}
+
+	ss := &fakeServerStream{ctx: metadata.NewIncomingContext(
+		context.Background(), metadata.Pairs("authorization", "123"))}
+	assert.NoError(t, am.StreamInterceptor(nil, ss, info, next))
+
+	ss = &fakeServerStream{ctx: context.Background()}
+	err := am.StreamInterceptor(nil, ss, info, next)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// next is called only once
+	assert.Equal(t, 1, called)
 }
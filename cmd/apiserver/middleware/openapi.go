@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/openapi"
+	"github.com/gorilla/mux"
+	"github.com/inconshreveable/log15"
+	"io/ioutil"
+	"net/http"
+)
+
+// OpenAPIMiddleWare validates parameters and bodies of requests against the
+// Operations of their routes in an OpenAPI document. It's used by a router of
+// mux, after which route a request matches is known.
+type OpenAPIMiddleWare struct {
+	log      log15.Logger
+	document *openapi.Document
+}
+
+func (m *OpenAPIMiddleWare) Handle(h http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		op := m.document.Operation(r.Method, openapi.PathOf(template))
+		if op == nil {
+			m.log.Warn("Undocumented route", "method", r.Method,
+				"path", template)
+			h.ServeHTTP(w, r)
+			return
+		}
+		var body []byte
+		if op.RequestBody != nil && r.Body != nil {
+			if body, err = ioutil.ReadAll(r.Body); err != nil {
+				handler.WriteProblem(w, r, handler.ProblemOf(err))
+				return
+			}
+			// Handlers read it again
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		err = m.document.ValidateRequest(op, r, mux.Vars(r), body)
+		if fields, ok := err.(openapi.Errors); ok {
+			// Bad Request
+			handler.WriteProblem(w, r, handler.ProblemOf(fields))
+			return
+		} else if err != nil {
+			// Bad Request
+			handler.WriteProblem(w, r, &handler.Problem{Status: 400,
+				Code: handler.CodeInvalidRequest, Detail: err.Error()})
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(f)
+}
+
+func CreateOpenAPIMiddleWare(document *openapi.Document) *OpenAPIMiddleWare {
+	return &OpenAPIMiddleWare{
+		log:      log15.New("module", "middleware.openapi"),
+		document: document,
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/openapi"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPIMiddleWare_Handle(t *testing.T) {
+	om := CreateOpenAPIMiddleWare(openapi.CreateDocument())
+
+	var body []byte
+	called := false
+	f := func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		body, _ = ioutil.ReadAll(r.Body)
+	}
+
+	r := mux.NewRouter()
+	r.Use(om.Handle)
+	r.Methods("GET").Path("/products/").HandlerFunc(f)
+	r.Methods("PATCH").Path("/products/{productID}").HandlerFunc(f)
+	r.Methods("GET").Path("/products/{productID}/revisions/{revision:[0-9]+}").
+		HandlerFunc(f)
+	r.Methods("GET").Path("/undocumented").HandlerFunc(f)
+	serve := func(method, url, contentType, body string) *httptest.ResponseRecorder {
+		called = false
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		request.Header.Set("Content-Type", contentType)
+		r.ServeHTTP(writer, request)
+		return writer
+	}
+	problemOf := func(writer *httptest.ResponseRecorder) *handler.Problem {
+		var p handler.Problem
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &p))
+		return &p
+	}
+
+	// Valid, where the body is read again by f
+	patch := `{"story": null}`
+	writer := serve("PATCH", "/products/001", "application/merge-patch+json",
+		patch)
+	assert.Equal(t, 200, writer.Code)
+	assert.True(t, called)
+	assert.Equal(t, patch, string(body))
+	serve("GET", "/products/?limit=10&sort=-name&free_from=milk,soy", "", "")
+	assert.True(t, called)
+	serve("GET", "/undocumented?limit=x", "", "")
+	assert.True(t, called)
+
+	// Invalid bodies
+	writer = serve("PATCH", "/products/001", "application/merge-patch+json",
+		`{"name": 1, "unknown": 1}`)
+	assert.Equal(t, 400, writer.Code)
+	assert.False(t, called)
+	p := problemOf(writer)
+	assert.Equal(t, handler.CodeInvalidFields, p.Code)
+	assert.Equal(t, []handler.FieldError{
+		{Field: "name", Code: handler.CodeInvalidType, Detail: "not a string"},
+		{Field: "unknown", Code: handler.CodeUnknownField,
+			Detail: "unknown field"},
+	}, p.Errors)
+
+	writer = serve("PATCH", "/products/001", "application/json-patch+json",
+		`{"op": "remove"}`)
+	assert.Equal(t, 400, writer.Code)
+	assert.Equal(t, handler.CodeInvalidRequest, problemOf(writer).Code)
+
+	// Invalid parameters
+	writer = serve("GET", "/products/?limit=0&sort=story", "", "")
+	assert.Equal(t, 400, writer.Code)
+	assert.False(t, called)
+	assert.Len(t, problemOf(writer).Errors, 2)
+
+	writer = serve("GET", "/products/001/revisions/0", "", "")
+	assert.Equal(t, 400, writer.Code)
+	assert.False(t, called)
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/inconshreveable/log15"
+	"net/http"
+)
+
+// requestIDHeader carries the id of a request and its response.
+const requestIDHeader = "X-Request-Id"
+
+// maxRequestIDLen bounds ids given by clients, which are otherwise replaced.
+const maxRequestIDLen = 128
+
+// RequestIDMiddleWare gives every request an id, which is told in the
+// response and in problems written, so that failures can be traced in logs.
+type RequestIDMiddleWare struct {
+	log log15.Logger
+}
+
+func (m *RequestIDMiddleWare) Handle(h http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = m.generate()
+		}
+		w.Header().Set(requestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(handler.WithRequestID(r.Context(), id)))
+	}
+	return http.HandlerFunc(f)
+}
+
+// generate returns a random id of 32 hex digits.
+func (m *RequestIDMiddleWare) generate() string {
+	bs := make([]byte, 16)
+	if _, err := rand.Read(bs); err != nil {
+		m.log.Error("rand.Read failed", "err", err)
+	}
+	return hex.EncodeToString(bs)
+}
+
+func CreateRequestIDMiddleWare() *RequestIDMiddleWare {
+	return &RequestIDMiddleWare{
+		log: log15.New("module", "middleware.requestid"),
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleWare_Handle(t *testing.T) {
+	rm := CreateRequestIDMiddleWare()
+
+	var id string
+	f := func(w http.ResponseWriter, r *http.Request) {
+		id = handler.RequestIDFrom(r.Context())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", rm.Handle(http.HandlerFunc(f)))
+
+	// Generated
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	mux.ServeHTTP(writer, request)
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, writer.Header().Get("X-Request-Id"))
+
+	// Given
+	writer = httptest.NewRecorder()
+	request.Header.Set("X-Request-Id", "req-1")
+	mux.ServeHTTP(writer, request)
+	assert.Equal(t, "req-1", id)
+	assert.Equal(t, "req-1", writer.Header().Get("X-Request-Id"))
+
+	// Replaced if it's too long
+	writer = httptest.NewRecorder()
+	request.Header.Set("X-Request-Id", strings.Repeat("x", 129))
+	mux.ServeHTTP(writer, request)
+	assert.Len(t, id, 32)
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"github.com/inconshreveable/log15"
+	"google.golang.org/grpc"
+	"net/http"
+	"time"
+)
+
+// TimeoutMiddleWare bounds how long a request takes by the deadline of the
+// request's context, which reaches backends.
+type TimeoutMiddleWare struct {
+	log     log15.Logger
+	timeout time.Duration
+}
+
+func (m *TimeoutMiddleWare) Handle(h http.Handler) http.Handler {
+	if m.timeout <= 0 {
+		return h
+	}
+	f := func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), m.timeout)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+		if ctx.Err() == context.DeadlineExceeded {
+			m.log.Warn("Request timed out", "method", r.Method,
+				"url", r.URL.String(), "timeout", m.timeout)
+		}
+	}
+	return http.HandlerFunc(f)
+}
+
+// UnaryInterceptor bounds gRPC calls like Handle. Streams are bounded only by
+// deadlines of clients, as they may take as long as clients read.
+func (m *TimeoutMiddleWare) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	if m.timeout <= 0 {
+		return next(ctx, req)
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	resp, err := next(ctx, req)
+	if ctx.Err() == context.DeadlineExceeded {
+		m.log.Warn("Call timed out", "method", info.FullMethod,
+			"timeout", m.timeout)
+	}
+	return resp, err
+}
+
+// CreateTimeoutMiddleWare creates TimeoutMiddleWare. Requests are not bounded
+// if timeout is not positive.
+func CreateTimeoutMiddleWare(timeout time.Duration) *TimeoutMiddleWare {
+	return &TimeoutMiddleWare{
+		log:     log15.New("module", "middleware.timeout"),
+		timeout: timeout,
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleWare_Handle(t *testing.T) {
+	tm := CreateTimeoutMiddleWare(time.Minute)
+
+	var deadline time.Time
+	var ok bool
+	f := func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", tm.Handle(http.HandlerFunc(f)))
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	mux.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func TestTimeoutMiddleWare_Handle_NoTimeout(t *testing.T) {
+	tm := CreateTimeoutMiddleWare(0)
+
+	ok := true
+	f := func(w http.ResponseWriter, r *http.Request) {
+		_, ok = r.Context().Deadline()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", tm.Handle(http.HandlerFunc(f)))
+
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	mux.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	assert.False(t, ok)
+}
+
+func TestTimeoutMiddleWare_UnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/Service/Method"}
+
+	var deadline time.Time
+	var ok bool
+	next := func(ctx context.Context, req interface{}) (interface{}, error) {
+		deadline, ok = ctx.Deadline()
+		return req, nil
+	}
+
+	tm := CreateTimeoutMiddleWare(time.Minute)
+	resp, err := tm.UnaryInterceptor(context.Background(), "req", info, next)
+	assert.NoError(t, err)
+	assert.Equal(t, "req", resp)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	tm = CreateTimeoutMiddleWare(0)
+	tm.UnaryInterceptor(context.Background(), "req", info, next)
+	assert.False(t, ok)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cfchou/icecream/cmd/apiserver/util"
+	"github.com/cfchou/icecream/pkg/backend/mongodb"
+	"github.com/cfchou/icecream/pkg/backend/mongodb/migration"
+	"github.com/globalsign/mgo"
+	"github.com/spf13/pflag"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: apiserver migrate status|up|down [--to version] [--dry-run] [-c config]
+  status  lists migrations and whether they are applied
+  up      applies pending migrations until --to, or the latest
+  down    reverts applied migrations until --to, or the previous version`
+
+var errMigrateUsage = errors.New(migrateUsage)
+
+// Flags of the subcommand migrate. They are defined with package variables so
+// that they are known before pflag.Parse in init.
+var (
+	migrateTo = pflag.Int("to", -1,
+		"migrate: target version of up or down")
+	migrateDryRun = pflag.Bool("dry-run", false,
+		"migrate: print migrations to run without running them")
+)
+
+// migrate runs the subcommand migrate against the db in dbConf.
+func migrate(args []string, dbConf util.Conf) error {
+	if len(args) != 1 {
+		return errMigrateUsage
+	}
+	if t := dbConf.GetString("type"); t != "" && t != "mongodb" {
+		return fmt.Errorf("migrate supports only db type mongodb, not %s", t)
+	}
+	session, err := mgo.Dial(util.CreateMongoURL(dbConf, appName))
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	migrator, err := mongodb.CreateMigrator(session)
+	if err != nil {
+		return err
+	}
+
+	var steps []migration.Migration
+	verb := "Applied"
+	switch args[0] {
+	case "status":
+		return printStatus(migrator)
+	case "up":
+		target := *migrateTo
+		if target < 0 {
+			target = migrator.Latest()
+		}
+		steps, err = migrator.Up(target, *migrateDryRun)
+	case "down":
+		verb = "Reverted"
+		target := *migrateTo
+		if target < 0 {
+			current, err := migrator.Version()
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				fmt.Println("Nothing to revert")
+				return nil
+			}
+			target = current - 1
+		}
+		steps, err = migrator.Down(target, *migrateDryRun)
+	default:
+		return errMigrateUsage
+	}
+	if *migrateDryRun {
+		verb = "Would have " + strings.ToLower(verb)
+	}
+	for _, mg := range steps {
+		fmt.Printf("%s %d: %s\n", verb, mg.Version, mg.Description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Println("Nothing to do")
+	}
+	return nil
+}
+
+func printStatus(migrator *migration.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED AT\tDESCRIPTION")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, appliedAt, s.Description)
+	}
+	return w.Flush()
+}
@@ -2,6 +2,7 @@
 
 package mocks
 
+import context "context"
 import mock "github.com/stretchr/testify/mock"
 
 // APIKeyBackend is an autogenerated mock type for the APIKeyBackend type
@@ -9,13 +10,13 @@ type APIKeyBackend struct {
 	mock.Mock
 }
 
-// Authenticate provides a mock function with given fields: apiKey
-func (_m *APIKeyBackend) Authenticate(apiKey string) error {
-	ret := _m.Called(apiKey)
+// Authenticate provides a mock function with given fields: ctx, apiKey
+func (_m *APIKeyBackend) Authenticate(ctx context.Context, apiKey string) error {
+	ret := _m.Called(ctx, apiKey)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(apiKey)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, apiKey)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -2,21 +2,23 @@
 
 package mocks
 
+import context "context"
 import mock "github.com/stretchr/testify/mock"
 import model "github.com/cfchou/icecream/pkg/backend/model"
+import time "time"
 
 // ProductBackend is an autogenerated mock type for the ProductBackend type
 type ProductBackend struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: product
-func (_m *ProductBackend) Create(product *model.Product) error {
-	ret := _m.Called(product)
+// Create provides a mock function with given fields: ctx, product
+func (_m *ProductBackend) Create(ctx context.Context, product *model.Product) error {
+	ret := _m.Called(ctx, product)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*model.Product) error); ok {
-		r0 = rf(product)
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Product) error); ok {
+		r0 = rf(ctx, product)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -24,13 +26,13 @@ func (_m *ProductBackend) Create(product *model.Product) error {
 	return r0
 }
 
-// Delete provides a mock function with given fields: productID
-func (_m *ProductBackend) Delete(productID string) error {
-	ret := _m.Called(productID)
+// Delete provides a mock function with given fields: ctx, productID
+func (_m *ProductBackend) Delete(ctx context.Context, productID string) error {
+	ret := _m.Called(ctx, productID)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(productID)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, productID)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -38,13 +40,62 @@ func (_m *ProductBackend) Delete(productID string) error {
 	return r0
 }
 
-// Read provides a mock function with given fields: productID
-func (_m *ProductBackend) Read(productID string) (*model.Product, error) {
-	ret := _m.Called(productID)
+// DeleteIfMatch provides a mock function with given fields: ctx, productID, version
+func (_m *ProductBackend) DeleteIfMatch(ctx context.Context, productID string, version int64) error {
+	ret := _m.Called(ctx, productID, version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, productID, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeTrashed provides a mock function with given fields: ctx, productID
+func (_m *ProductBackend) PurgeTrashed(ctx context.Context, productID string) error {
+	ret := _m.Called(ctx, productID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeTrashedBefore provides a mock function with given fields: ctx, t
+func (_m *ProductBackend) PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error) {
+	ret := _m.Called(ctx, t)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, t)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, t)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Read provides a mock function with given fields: ctx, productID
+func (_m *ProductBackend) Read(ctx context.Context, productID string) (*model.Product, error) {
+	ret := _m.Called(ctx, productID)
 
 	var r0 *model.Product
-	if rf, ok := ret.Get(0).(func(string) *model.Product); ok {
-		r0 = rf(productID)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Product); ok {
+		r0 = rf(ctx, productID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Product)
@@ -52,8 +103,8 @@ func (_m *ProductBackend) Read(productID string) (*model.Product, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(productID)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, productID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -61,13 +112,13 @@ func (_m *ProductBackend) Read(productID string) (*model.Product, error) {
 	return r0, r1
 }
 
-// ReadMany provides a mock function with given fields: cursor, limit
-func (_m *ProductBackend) ReadMany(cursor string, limit int) (*model.Products, error) {
-	ret := _m.Called(cursor, limit)
+// ReadMany provides a mock function with given fields: ctx, cursor, limit, filter, order
+func (_m *ProductBackend) ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error) {
+	ret := _m.Called(ctx, cursor, limit, filter, order)
 
 	var r0 *model.Products
-	if rf, ok := ret.Get(0).(func(string, int) *model.Products); ok {
-		r0 = rf(cursor, limit)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, *model.Filter, model.Sort) *model.Products); ok {
+		r0 = rf(ctx, cursor, limit, filter, order)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Products)
@@ -75,8 +126,8 @@ func (_m *ProductBackend) ReadMany(cursor string, limit int) (*model.Products, e
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, int) error); ok {
-		r1 = rf(cursor, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, *model.Filter, model.Sort) error); ok {
+		r1 = rf(ctx, cursor, limit, filter, order)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -84,13 +135,124 @@ func (_m *ProductBackend) ReadMany(cursor string, limit int) (*model.Products, e
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: product
-func (_m *ProductBackend) Update(product *model.Product) error {
-	ret := _m.Called(product)
+// ReadRevision provides a mock function with given fields: ctx, productID, revision
+func (_m *ProductBackend) ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error) {
+	ret := _m.Called(ctx, productID, revision)
+
+	var r0 *model.Revision
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) *model.Revision); ok {
+		r0 = rf(ctx, productID, revision)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Revision)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64) error); ok {
+		r1 = rf(ctx, productID, revision)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReadRevisions provides a mock function with given fields: ctx, productID
+func (_m *ProductBackend) ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error) {
+	ret := _m.Called(ctx, productID)
+
+	var r0 *model.Revisions
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Revisions); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Revisions)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReadTrash provides a mock function with given fields: ctx, cursor, limit
+func (_m *ProductBackend) ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error) {
+	ret := _m.Called(ctx, cursor, limit)
+
+	var r0 *model.Trash
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) *model.Trash); ok {
+		r0 = rf(ctx, cursor, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Trash)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, cursor, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreTrashed provides a mock function with given fields: ctx, productID
+func (_m *ProductBackend) RestoreTrashed(ctx context.Context, productID string) error {
+	ret := _m.Called(ctx, productID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Update provides a mock function with given fields: ctx, product
+func (_m *ProductBackend) Update(ctx context.Context, product *model.Product) error {
+	ret := _m.Called(ctx, product)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Product) error); ok {
+		r0 = rf(ctx, product)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateIfMatch provides a mock function with given fields: ctx, product, version
+func (_m *ProductBackend) UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error {
+	ret := _m.Called(ctx, product, version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Product, int64) error); ok {
+		r0 = rf(ctx, product, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdatePartial provides a mock function with given fields: ctx, productID, kvs
+func (_m *ProductBackend) UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error {
+	ret := _m.Called(ctx, productID, kvs)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*model.Product) error); ok {
-		r0 = rf(product)
+	if rf, ok := ret.Get(0).(func(context.Context, string, map[string]interface{}) error); ok {
+		r0 = rf(ctx, productID, kvs)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -98,13 +260,13 @@ func (_m *ProductBackend) Update(product *model.Product) error {
 	return r0
 }
 
-// UpdatePartial provides a mock function with given fields: productID, kvs
-func (_m *ProductBackend) UpdatePartial(productID string, kvs map[string]interface{}) error {
-	ret := _m.Called(productID, kvs)
+// UpdatePartialIfMatch provides a mock function with given fields: ctx, productID, kvs, version
+func (_m *ProductBackend) UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
+	ret := _m.Called(ctx, productID, kvs, version)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, map[string]interface{}) error); ok {
-		r0 = rf(productID, kvs)
+	if rf, ok := ret.Get(0).(func(context.Context, string, map[string]interface{}, int64) error); ok {
+		r0 = rf(ctx, productID, kvs, version)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -112,13 +274,13 @@ func (_m *ProductBackend) UpdatePartial(productID string, kvs map[string]interfa
 	return r0
 }
 
-// Upsert provides a mock function with given fields: product
-func (_m *ProductBackend) Upsert(product *model.Product) error {
-	ret := _m.Called(product)
+// Upsert provides a mock function with given fields: ctx, product
+func (_m *ProductBackend) Upsert(ctx context.Context, product *model.Product) error {
+	ret := _m.Called(ctx, product)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*model.Product) error); ok {
-		r0 = rf(product)
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Product) error); ok {
+		r0 = rf(ctx, product)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -0,0 +1,3 @@
+// Package openapi describes the REST API of apiserver by an OpenAPI 3
+// document, and validates requests against it
+package openapi
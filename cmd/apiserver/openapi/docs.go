@@ -0,0 +1,29 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// docsPage renders the document at its URL by ReDoc.
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Icecream API</title>
+<meta charset="utf-8">
+</head>
+<body>
+<redoc spec-url="{{.}}"></redoc>
+<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`))
+
+// DocsHandler serves a docs page of the document served at specURL.
+func DocsHandler(specURL string) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		docsPage.Execute(w, specURL)
+	}
+	return http.HandlerFunc(f)
+}
@@ -0,0 +1,637 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Media types of bodies.
+const (
+	jsonType       = "application/json"
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+	problemType    = "application/problem+json"
+)
+
+// securityScheme names the API key in "Authorization".
+const securityScheme = "apiKey"
+
+// CreateDocument creates the Document of every route of apiserver. Schemas
+// of products are also what handlers validate products against.
+func CreateDocument() *Document {
+	d := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title: "Icecream",
+			Description: "CRUD of ice cream products. Errors are " +
+				"application/problem+json, RFC 7807.",
+			Version: "1.0.0",
+		},
+		Components: Components{
+			Schemas: componentSchemas(),
+			SecuritySchemes: map[string]*SecurityScheme{
+				securityScheme: {Type: "apiKey", In: "header",
+					Name: "Authorization",
+					Description: "An API key, which has to go with " +
+						"SSL to be secure."},
+			},
+		},
+		Security: []SecurityRequirement{{securityScheme: {}}},
+	}
+	d.Paths = map[string]PathItem{
+		"/products/search": {
+			"get": {
+				OperationID: "searchProducts",
+				Summary:     "Search products",
+				Description: "Finds products matching any word of q in " +
+					"name, description, story or ingredients, ordered " +
+					"by relevance. It fails with 501 if the backend " +
+					"can't search.",
+				Tags: []string{"products"},
+				Parameters: []*Parameter{
+					{Name: "q", In: "query", Required: true,
+						Schema: &Schema{Type: "string", MinLength: 1}},
+					cursorParameter, limitParameter,
+				},
+				Responses: responses("200", "A page of hits",
+					Component("SearchResults")),
+			},
+		},
+		"/products/{productID}": {
+			"get": {
+				OperationID: "getProduct",
+				Summary:     "Read a product",
+				Description: "The product is served with ETag and " +
+					"Last-Modified, and is not modified if it's of " +
+					"If-None-Match or not modified since " +
+					"If-Modified-Since.",
+				Tags: []string{"products"},
+				Parameters: []*Parameter{productIDParameter,
+					header("If-None-Match"), header("If-Modified-Since")},
+				Responses: withNotModified(responses("200", "The product",
+					Component("Product"))),
+			},
+			"put": {
+				OperationID: "putProduct",
+				Summary:     "Create or replace a product",
+				Description: "With \"If-None-Match: *\" it only creates, " +
+					"and with If-Match it only replaces the product of " +
+					"the given ETag. productId of the body is that of " +
+					"the path if it's empty.",
+				Tags: []string{"products"},
+				Parameters: []*Parameter{productIDParameter,
+					header("If-Match"), header("If-None-Match")},
+				RequestBody: &RequestBody{Required: true,
+					Content: content(jsonType, Component("Product"))},
+				Responses: withCreated(responses("200", "Replaced", nil)),
+			},
+			"patch": {
+				OperationID: "patchProduct",
+				Summary:     "Update a product partially",
+				Description: "A JSON Merge Patch, RFC 7396, where null " +
+					"resets a field, or a JSON Patch, RFC 6902, applied " +
+					"atomically. Allergens given partially are merged " +
+					"with those stored. With If-Match it only updates " +
+					"the product of the given ETag.",
+				Tags:       []string{"products"},
+				Parameters: []*Parameter{productIDParameter, header("If-Match")},
+				RequestBody: &RequestBody{Required: true,
+					Content: map[string]*MediaType{
+						jsonType:       {Schema: Component("ProductPatch")},
+						mergePatchType: {Schema: Component("ProductPatch")},
+						jsonPatchType:  {Schema: Component("JSONPatch")},
+					}},
+				Responses: responses("200", "Updated", nil),
+			},
+			"delete": {
+				OperationID: "deleteProduct",
+				Summary:     "Delete a product",
+				Description: "The product is moved to the trash. With " +
+					"If-Match it only deletes the product of the given " +
+					"ETag.",
+				Tags:       []string{"products"},
+				Parameters: []*Parameter{productIDParameter, header("If-Match")},
+				Responses:  responses("200", "Deleted", nil),
+			},
+		},
+		"/products/": {
+			"get": {
+				OperationID: "listProducts",
+				Summary:     "Read a page of products",
+				Description: "Pages are sorted by sort and filtered by " +
+					"the rest of parameters. URLs of the first and the " +
+					"next page are also in Link.",
+				Tags: []string{"products"},
+				Parameters: append([]*Parameter{cursorParameter,
+					limitParameter,
+					{Name: "sort", In: "query", AllowEmptyValue: true,
+						Description: "A field, prefixed by \"-\" for " +
+							"the descending order",
+						Schema: &Schema{Type: "string", Enum: sorts()}},
+					{Name: "count", In: "query", AllowEmptyValue: true,
+						Description: "Whether the page has the total",
+						Schema:      &Schema{Type: "boolean"}},
+					header("If-None-Match"), header("If-Modified-Since"),
+				}, filterParameters()...),
+				Responses: withNotModified(responses("200",
+					"A page of products", Component("Products"))),
+			},
+			"post": {
+				OperationID: "createProduct",
+				Summary:     "Create a product",
+				Description: "It fails with 409 if the product exists.",
+				Tags:        []string{"products"},
+				RequestBody: &RequestBody{Required: true,
+					Content: content(jsonType, Component("Product"))},
+				Responses: responses("201", "Created", nil),
+			},
+		},
+		"/trash/": {
+			"get": {
+				OperationID: "listTrash",
+				Summary:     "Read a page of trashed products",
+				Tags:        []string{"trash"},
+				Parameters:  []*Parameter{cursorParameter, limitParameter},
+				Responses: responses("200", "A page of trashed products",
+					Component("Trash")),
+			},
+		},
+		"/trash/{productID}:restore": {
+			"post": {
+				OperationID: "restoreTrashed",
+				Summary:     "Restore a trashed product",
+				Description: "It fails with 409 if a product of the same " +
+					"productId has been created since.",
+				Tags:       []string{"trash"},
+				Parameters: []*Parameter{productIDParameter},
+				Responses:  responses("200", "Restored", nil),
+			},
+		},
+		"/trash/{productID}": {
+			"delete": {
+				OperationID: "purgeTrashed",
+				Summary:     "Delete a trashed product permanently",
+				Tags:        []string{"trash"},
+				Parameters:  []*Parameter{productIDParameter},
+				Responses:   responses("200", "Purged", nil),
+			},
+		},
+		"/products/{productID}/revisions": {
+			"get": {
+				OperationID: "listRevisions",
+				Summary:     "Read revisions of a product",
+				Tags:        []string{"revisions"},
+				Parameters:  []*Parameter{productIDParameter},
+				Responses: responses("200", "Revisions",
+					Component("Revisions")),
+			},
+		},
+		"/products/{productID}/revisions/{revision}": {
+			"get": {
+				OperationID: "getRevision",
+				Summary:     "Read a revision of a product",
+				Tags:        []string{"revisions"},
+				Parameters: []*Parameter{productIDParameter,
+					revisionParameter},
+				Responses: responses("200", "The revision",
+					Component("Revision")),
+			},
+		},
+		"/products/{productID}/revisions/{revision}:restore": {
+			"post": {
+				OperationID: "restoreRevision",
+				Summary:     "Write a revision of a product back",
+				Description: "It makes a new version, like PUT, and a " +
+					"deleted product is created again.",
+				Tags: []string{"revisions"},
+				Parameters: []*Parameter{productIDParameter,
+					revisionParameter, header("If-Match")},
+				Responses: responses("200", "Restored", nil),
+			},
+		},
+		"/graphql": {
+			"get": {
+				OperationID: "queryGraphQL",
+				Summary:     "Execute a GraphQL query",
+				Description: "Errors are in errors of the result rather " +
+					"than problems.",
+				Tags: []string{"graphql"},
+				Parameters: []*Parameter{
+					{Name: "query", In: "query",
+						Schema: &Schema{Type: "string"}},
+					{Name: "operationName", In: "query",
+						Schema: &Schema{Type: "string"}},
+					{Name: "variables", In: "query",
+						Description: "Variables in json",
+						Schema:      &Schema{Type: "string"}},
+				},
+				Responses: graphQLResponses(),
+			},
+			"post": {
+				OperationID: "executeGraphQL",
+				Summary:     "Execute a GraphQL query or mutation",
+				Description: "Errors are in errors of the result rather " +
+					"than problems.",
+				Tags: []string{"graphql"},
+				RequestBody: &RequestBody{Required: true,
+					Content: content(jsonType, Component("GraphQLRequest"))},
+				Responses: graphQLResponses(),
+			},
+		},
+		"/debug/vars": {
+			"get": {
+				OperationID: "getVars",
+				Summary:     "Read metrics, e.g. stats of caches",
+				Tags:        []string{"debug"},
+				Responses: responses("200", "Metrics",
+					&Schema{Type: "object"}),
+			},
+		},
+		"/openapi.json": {
+			"get": {
+				OperationID: "getOpenAPI",
+				Summary:     "Read this document",
+				Tags:        []string{"docs"},
+				Responses: responses("200", "The document",
+					&Schema{Type: "object"}),
+				Security: &[]SecurityRequirement{},
+			},
+		},
+		"/docs": {
+			"get": {
+				OperationID: "getDocs",
+				Summary:     "Read the docs page of this document",
+				Description: "It's served only if openapi.docs is true " +
+					"in the config.",
+				Tags: []string{"docs"},
+				Responses: map[string]*Response{"200": {
+					Description: "The docs page",
+					Content: map[string]*MediaType{
+						"text/html": {Schema: &Schema{Type: "string"}}},
+				}},
+				Security: &[]SecurityRequirement{},
+			},
+		},
+	}
+	return d
+}
+
+// componentSchemas are schemas of bodies, which are referred to by names.
+func componentSchemas() map[string]*Schema {
+	// Lists may be null for empty ones, as they're read
+	str := &Schema{Type: "string"}
+	strs := &Schema{Type: "array", Items: str, Nullable: true}
+	timestamp := &Schema{Type: "string", Format: "date-time", ReadOnly: true}
+	page := func(name string, items *Schema) *Schema {
+		return &Schema{Type: "object", Properties: map[string]*Schema{
+			"cursor": {Type: "string", Description: "Absent on the last " +
+				"page, otherwise the cursor of the next page"},
+			name: {Type: "array", Items: items},
+		}}
+	}
+
+	product := &Schema{
+		Type: "object",
+		Description: "productId is the primary key. ingredients may be " +
+			"replaced by structured_ingredients, which ingredients are " +
+			"formatted from. allergens are parsed from allergy_info " +
+			"unless they're given. Timestamps are maintained by the " +
+			"server and ignored in requests.",
+		Properties: map[string]*Schema{
+			"productId":              str,
+			"name":                   str,
+			"image_closed":           str,
+			"image_open":             str,
+			"description":            str,
+			"story":                  str,
+			"sourcing_values":        strs,
+			"ingredients":            strs,
+			"allergy_info":           str,
+			"dietary_certifications": str,
+			"allergens":              Component("Allergens"),
+			"structured_ingredients": {Type: "array",
+				Items: Component("Ingredient"), Nullable: true},
+			"created_at": timestamp,
+			"updated_at": timestamp,
+		},
+		Required: []string{"productId", "name", "image_closed",
+			"image_open", "description", "story", "sourcing_values",
+			"allergy_info", "dietary_certifications"},
+		AnyOf: []*Schema{{Required: []string{"ingredients"}},
+			{Required: []string{"structured_ingredients"}}},
+		AdditionalProperties: false,
+	}
+
+	// Every field of a patch is optional and null resets it, except
+	// productId, which can't be changed.
+	patch := &Schema{
+		Type: "object",
+		Description: "A JSON Merge Patch of a product, where null resets " +
+			"a field to its zero value. Allergens given partially are " +
+			"merged with those stored.",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+	for k, prop := range product.Properties {
+		if prop.Ref == "" && k != "productId" {
+			copied := *prop
+			copied.Nullable = true
+			prop = &copied
+		}
+		patch.Properties[k] = prop
+	}
+
+	// TrashedProduct embeds Product
+	trashed := *product
+	trashed.Description = ""
+	trashed.Properties = map[string]*Schema{
+		"deleted_at": {Type: "string", Format: "date-time"}}
+	for k, prop := range product.Properties {
+		trashed.Properties[k] = prop
+	}
+
+	var allergenEnum []interface{}
+	for _, a := range model.MajorAllergens {
+		allergenEnum = append(allergenEnum, string(a))
+	}
+	minRevision := int64(1)
+
+	return map[string]*Schema{
+		"Product":      product,
+		"ProductPatch": patch,
+		"Allergen": {Type: "string", Enum: allergenEnum,
+			Description: "A major allergen"},
+		"Allergens": {
+			Type: "object",
+			Description: "Allergens a product contains or may contain. " +
+				"They're parsed from allergy_info if they're null.",
+			Properties: map[string]*Schema{
+				"contains": {Type: "array", Items: Component("Allergen"),
+					Nullable: true},
+				"may_contain": {Type: "array", Items: Component("Allergen"),
+					Nullable: true},
+			},
+			AdditionalProperties: false,
+			Nullable:             true,
+		},
+		"Ingredient": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"name": {Type: "string",
+					Description: "A name without delimiters ,()[] and " +
+						"leading or trailing spaces",
+					Pattern: `^[^\s,()\[\]]([^,()\[\]]*[^\s,()\[\]])?$`},
+				"ingredients": {Type: "array", Items: Component("Ingredient")},
+			},
+			Required:             []string{"name"},
+			AdditionalProperties: false,
+		},
+		"JSONPatch": {Type: "array", Items: Component("JSONPatchOperation"),
+			Description: "A JSON Patch, RFC 6902"},
+		"JSONPatchOperation": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"op": {Type: "string", Enum: []interface{}{"add", "remove",
+					"replace", "move", "copy", "test"}},
+				"path": {Type: "string", Description: "A JSON Pointer"},
+				"from": {Type: "string", Description: "A JSON Pointer " +
+					"of move and copy"},
+				"value": {Description: "The value of add, replace and test"},
+			},
+			Required: []string{"op", "path"},
+		},
+		"Products": func() *Schema {
+			s := page("products", Component("Product"))
+			s.Properties["total"] = &Schema{Type: "integer",
+				Description: "The number of products on all pages, if " +
+					"count is true"}
+			s.Properties["first"] = &Schema{Type: "string",
+				Description: "The URL of the first page"}
+			s.Properties["next"] = &Schema{Type: "string",
+				Description: "The URL of the next page"}
+			return s
+		}(),
+		"TrashedProduct": &trashed,
+		"Trash":          page("products", Component("TrashedProduct")),
+		"SearchResults":  page("hits", Component("SearchHit")),
+		"SearchHit": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"product": Component("Product"),
+				"score": {Type: "number", Description: "Larger for a " +
+					"more relevant product, among hits of a search"},
+				"highlights": {Type: "object",
+					Description: "Snippets of matched fields, where " +
+						"matched words are enclosed by <em>",
+					AdditionalProperties: str},
+			},
+		},
+		"Revision": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"revision": {Type: "integer", Minimum: &minRevision},
+				"version":  {Type: "integer"},
+				"product":  Component("Product"),
+				"deleted":  {Type: "boolean"},
+				"apikey": {Type: "string", Description: "The masked API " +
+					"key of the write superseding the product"},
+				"revised_at": {Type: "string", Format: "date-time"},
+			},
+		},
+		"Revisions": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"revisions": {Type: "array", Items: Component("Revision")},
+			},
+		},
+		"GraphQLRequest": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"query":         str,
+				"operationName": {Type: "string", Nullable: true},
+				"variables":     {Type: "object", Nullable: true},
+			},
+		},
+		"GraphQLResult": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"data":   {Type: "object", Nullable: true},
+				"errors": {Type: "array", Items: &Schema{Type: "object"}},
+			},
+		},
+		"Problem": {
+			Type:        "object",
+			Description: "A problem detail, RFC 7807",
+			Properties: map[string]*Schema{
+				"type":       str,
+				"title":      str,
+				"status":     {Type: "integer"},
+				"detail":     str,
+				"instance":   str,
+				"code":       str,
+				"request_id": str,
+				"errors": {Type: "array", Items: Component("FieldError"),
+					Description: "Every invalid field"},
+			},
+			Required: []string{"type", "title", "status", "code"},
+		},
+		"FieldError": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"field": str,
+				"code": {Type: "string", Enum: []interface{}{
+					CodeUnknownField, CodeMissingField, CodeInvalidType,
+					CodeInvalidValue, CodeImmutableField}},
+				"detail": str,
+			},
+			Required: []string{"field", "code", "detail"},
+		},
+	}
+}
+
+var (
+	productIDParameter = &Parameter{Name: "productID", In: "path",
+		Required: true, Schema: &Schema{Type: "string"}}
+	revisionParameter = &Parameter{Name: "revision", In: "path",
+		Required: true, Schema: &Schema{Type: "integer", Minimum: &one}}
+	cursorParameter = &Parameter{Name: "cursor", In: "query",
+		AllowEmptyValue: true,
+		Description:     "The cursor of the previous page",
+		Schema:          &Schema{Type: "string"}}
+	limitParameter = &Parameter{Name: "limit", In: "query",
+		AllowEmptyValue: true,
+		Description: "The number of products in a page, capped by " +
+			"limitToRead of the config",
+		Schema: &Schema{Type: "integer", Minimum: &one}}
+	one = int64(1)
+)
+
+// filterParameters are parameters filtering products. Lists may be repeated.
+func filterParameters() []*Parameter {
+	var ps []*Parameter
+	for _, name := range []string{"ingredient", "sourcing_value",
+		"dietary_certification"} {
+		for _, p := range []string{name, "without_" + name} {
+			ps = append(ps, &Parameter{Name: p, In: "query",
+				Schema: &Schema{Type: "array",
+					Items: &Schema{Type: "string", MinLength: 1}}})
+		}
+	}
+	var allergens []string
+	for _, a := range model.MajorAllergens {
+		allergens = append(allergens, regexp.QuoteMeta(string(a)))
+	}
+	allergen := "(" + strings.Join(allergens, "|") + ")"
+	return append(ps,
+		&Parameter{Name: "free_from", In: "query",
+			Description: "Allergens separated by commas",
+			Schema: &Schema{Type: "array", Items: &Schema{Type: "string",
+				Pattern: fmt.Sprintf("^%s(,%s)*$", allergen, allergen)}}},
+		&Parameter{Name: "name_prefix", In: "query",
+			Schema: &Schema{Type: "string", MinLength: 1}})
+}
+
+// sorts are values of the parameter sort.
+func sorts() []interface{} {
+	var ret []interface{}
+	for _, field := range model.SortFields {
+		ret = append(ret, field, "-"+field)
+	}
+	return ret
+}
+
+func header(name string) *Parameter {
+	return &Parameter{Name: name, In: "header", Schema: &Schema{Type: "string"}}
+}
+
+func content(mediaType string, s *Schema) map[string]*MediaType {
+	return map[string]*MediaType{mediaType: {Schema: s}}
+}
+
+// responses are the response of status with an optional body s, and
+// problems of any other status.
+func responses(status, description string, s *Schema) map[string]*Response {
+	ret := map[string]*Response{
+		status: {Description: description},
+		"default": {Description: "A problem",
+			Content: content(problemType, Component("Problem"))},
+	}
+	if s != nil {
+		ret[status].Content = content(jsonType, s)
+	}
+	return ret
+}
+
+func withCreated(rs map[string]*Response) map[string]*Response {
+	rs["201"] = &Response{Description: "Created"}
+	return rs
+}
+
+func withNotModified(rs map[string]*Response) map[string]*Response {
+	rs["304"] = &Response{Description: "Not modified"}
+	return rs
+}
+
+func graphQLResponses() map[string]*Response {
+	result := content(jsonType, Component("GraphQLResult"))
+	return map[string]*Response{
+		"200": {Description: "The result", Content: result},
+		"400": {Description: "The request can't be executed",
+			Content: result},
+	}
+}
+
+// pathVariable is a variable of a path template of mux with its pattern,
+// which OpenAPI doesn't have.
+var pathVariable = regexp.MustCompile(`\{([^{}:]+):[^{}]*\}`)
+
+// PathOf converts template of a route of mux to the path of its Operation.
+func PathOf(template string) string {
+	return pathVariable.ReplaceAllString(template, "{$1}")
+}
+
+// CheckRoutes returns an error if any route of routers has no Operation.
+func (d *Document) CheckRoutes(routers ...*mux.Router) error {
+	walk := func(route *mux.Route, router *mux.Router,
+		ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return errors.New(fmt.Sprintf("no methods:%s", template))
+		}
+		for _, method := range methods {
+			if d.Operation(method, PathOf(template)) == nil {
+				return errors.New(fmt.Sprintf("undocumented route:%s %s",
+					method, template))
+			}
+		}
+		return nil
+	}
+	for _, router := range routers {
+		if err := router.Walk(walk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves d in json.
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bs, err := json.Marshal(d)
+	if err != nil {
+		// Internal Server Error
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", jsonType)
+	w.Write(bs)
+}
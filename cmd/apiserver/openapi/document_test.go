@@ -0,0 +1,72 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateDocument(t *testing.T) {
+	d := CreateDocument()
+
+	// Every ref is resolved
+	bs, err := json.Marshal(d)
+	assert.NoError(t, err)
+	for _, part := range strings.Split(string(bs), `"$ref":"`)[1:] {
+		ref := part[:strings.Index(part, `"`)]
+		assert.NotNil(t, d.Resolve(&Schema{Ref: ref}), ref)
+	}
+
+	ids := make(map[string]bool)
+	for path, item := range d.Paths {
+		for method, op := range item {
+			assert.False(t, ids[op.OperationID], op.OperationID)
+			ids[op.OperationID] = true
+			assert.NotEmpty(t, op.Responses, "%s %s", method, path)
+			// Path parameters are declared
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					assert.Contains(t, path, "{"+p.Name+"}")
+				}
+			}
+		}
+	}
+}
+
+func TestPathOf(t *testing.T) {
+	assert.Equal(t, "/products/{productID}/revisions/{revision}:restore",
+		PathOf("/products/{productID}/revisions/{revision:[0-9]+}:restore"))
+	assert.Equal(t, "/products/", PathOf("/products/"))
+}
+
+func TestDocument_CheckRoutes(t *testing.T) {
+	d := CreateDocument()
+	f := func(w http.ResponseWriter, r *http.Request) {}
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/products/{productID}").HandlerFunc(f)
+	r.Methods("GET").Path("/products/{productID}/revisions/{revision:[0-9]+}").
+		HandlerFunc(f)
+	assert.NoError(t, d.CheckRoutes(r))
+
+	r.Methods("POST").Path("/products/{productID}").HandlerFunc(f)
+	assert.EqualError(t, d.CheckRoutes(r),
+		"undocumented route:POST /products/{productID}")
+}
+
+func TestDocument_ServeHTTP(t *testing.T) {
+	d := CreateDocument()
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/openapi.json", nil)
+	d.ServeHTTP(writer, request)
+	assert.Equal(t, 200, writer.Code)
+	assert.Equal(t, "application/json", writer.Header().Get("Content-Type"))
+
+	var served map[string]interface{}
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &served))
+	assert.Equal(t, Version, served["openapi"])
+}
@@ -0,0 +1,134 @@
+package openapi
+
+import (
+	"strings"
+)
+
+// Version is the version of OpenAPI documents are of.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document. Only what describes apiserver is modeled.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem is Operations of a path keyed by their methods in lower case.
+type PathItem map[string]*Operation
+
+// Operation is a method of a path. Security overrides that of Document if
+// it's not nil, where an empty one needs no API key.
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []*Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]*Response   `json:"responses"`
+	Security    *[]SecurityRequirement `json:"security,omitempty"`
+}
+
+// Parameter is a parameter of an Operation. In is one of "path", "query" and
+// "header", where headers are not validated.
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	// AllowEmptyValue makes an empty value of a query parameter the same
+	// as its absence.
+	AllowEmptyValue bool    `json:"allowEmptyValue,omitempty"`
+	Schema          *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is how clients authenticate, e.g. by an API key in a header.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+}
+
+// SecurityRequirement is SecuritySchemes an Operation needs keyed by their
+// names.
+type SecurityRequirement map[string][]string
+
+// Schema is a schema of OpenAPI 3.0, a subset of JSON Schema. Ref refers to
+// one of Components, in which case the rest is empty. AdditionalProperties is
+// either a bool or a *Schema. AnyOf is only of "required", so values must
+// also be valid against the rest.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+}
+
+// refPrefix prefixes names of schemas of Components to make their Refs.
+const refPrefix = "#/components/schemas/"
+
+// Component refers to the schema name of Components.
+func Component(name string) *Schema {
+	return &Schema{Ref: refPrefix + name}
+}
+
+// Operation returns the Operation of method and path, or nil if there's none.
+// Path is a template like "/products/{productID}".
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Resolve returns the schema s refers to, or s itself if it's not a Ref.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+	return s
+}
@@ -0,0 +1,309 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codes of FieldError, which tell why a field is invalid.
+const (
+	CodeUnknownField   = "unknown_field"
+	CodeMissingField   = "missing_field"
+	CodeInvalidType    = "invalid_type"
+	CodeInvalidValue   = "invalid_value"
+	CodeImmutableField = "immutable_field"
+)
+
+// FieldError tells why a field of a request is invalid. Field is its name,
+// where members and items of fields are like "allergens.contains[0]", or a
+// JSON Pointer in a JSON Patch.
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// Errors is an error of every invalid field of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	details := make([]string, len(e))
+	for i, f := range e {
+		details[i] = f.Field + ": " + f.Detail
+	}
+	return "invalid fields: " + strings.Join(details, "; ")
+}
+
+// Sort orders e by fields.
+func (e Errors) Sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		return e[i].Field < e[j].Field
+	})
+}
+
+// Validate validates v decoded from json against s, and returns what's
+// invalid. Field is the name of v. ReadOnly properties are ignored as v is of
+// a request.
+func (d *Document) Validate(s *Schema, field string, v interface{}) Errors {
+	name := "value"
+	if s != nil && s.Ref != "" {
+		name = strings.ToLower(strings.TrimPrefix(s.Ref, refPrefix))
+	}
+	s = d.Resolve(s)
+	if s == nil {
+		return nil
+	}
+	invalid := func(code, detail string) Errors {
+		return Errors{{Field: field, Code: code, Detail: detail}}
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return invalid(CodeInvalidType, "can't be null")
+	}
+	if !isType(s.Type, v) {
+		return invalid(CodeInvalidType, "not "+article(s.Type))
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return invalid(CodeInvalidValue, fmt.Sprintf("unknown %s %v", name, v))
+	}
+	var errs Errors
+	switch v := v.(type) {
+	case string:
+		if len(v) < s.MinLength {
+			return invalid(CodeInvalidValue,
+				fmt.Sprintf("shorter than %d", s.MinLength))
+		}
+		if s.Pattern != "" && !patternOf(s.Pattern).MatchString(v) {
+			return invalid(CodeInvalidValue, "doesn't match "+s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && v < float64(*s.Minimum) {
+			return invalid(CodeInvalidValue,
+				fmt.Sprintf("less than %d", *s.Minimum))
+		}
+	case []interface{}:
+		for i, item := range v {
+			errs = append(errs, d.Validate(s.Items,
+				fmt.Sprintf("%s[%d]", field, i), item)...)
+		}
+	case map[string]interface{}:
+		errs = d.validateObject(s, field, v)
+	}
+	return errs
+}
+
+// validateObject validates members of v against properties of s.
+func (d *Document) validateObject(s *Schema, field string,
+	v map[string]interface{}) Errors {
+	member := func(k string) string {
+		if field == "" {
+			return k
+		}
+		return field + "." + k
+	}
+	var errs Errors
+	for k, m := range v {
+		prop, ok := s.Properties[k]
+		switch {
+		case ok && d.Resolve(prop).ReadOnly:
+		case ok:
+			errs = append(errs, d.Validate(prop, member(k), m)...)
+		case s.AdditionalProperties == false:
+			errs = append(errs, FieldError{Field: member(k),
+				Code: CodeUnknownField, Detail: "unknown field"})
+		default:
+			if additional, ok := s.AdditionalProperties.(*Schema); ok {
+				errs = append(errs, d.Validate(additional, member(k), m)...)
+			}
+		}
+	}
+	errs = append(errs, d.missing(s.Required, field, v)...)
+	if len(s.AnyOf) == 0 {
+		return errs
+	}
+	// Missing fields of the first alternative are told if none matches
+	var first Errors
+	for i, alt := range s.AnyOf {
+		missing := d.missing(alt.Required, field, v)
+		if len(missing) == 0 {
+			return errs
+		} else if i == 0 {
+			first = missing
+		}
+	}
+	return append(errs, first...)
+}
+
+// missing returns errors of fields required but absent in v.
+func (d *Document) missing(required []string, field string,
+	v map[string]interface{}) Errors {
+	var errs Errors
+	for _, k := range required {
+		if _, ok := v[k]; !ok {
+			if field != "" {
+				k = field + "." + k
+			}
+			errs = append(errs, FieldError{Field: k, Code: CodeMissingField,
+				Detail: "missing field"})
+		}
+	}
+	return errs
+}
+
+// isType is true if v decoded from json is of typ. Any v is of the empty
+// typ.
+func isType(typ string, v interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+// patterns caches patterns of schemas compiled.
+var patterns sync.Map
+
+// patternOf compiles pattern, which must be valid.
+func patternOf(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
+
+// article is typ with its indefinite article.
+func article(typ string) string {
+	switch typ {
+	case "array":
+		return "a list"
+	case "integer", "object":
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateParameter validates values of p in a request, which are absent if
+// they're empty. Only an array may have more than one value.
+func (d *Document) ValidateParameter(p *Parameter, values []string) Errors {
+	if p.AllowEmptyValue && len(values) == 1 && values[0] == "" {
+		values = nil
+	}
+	if len(values) == 0 {
+		if p.Required {
+			return Errors{{Field: p.Name, Code: CodeMissingField,
+				Detail: "missing parameter"}}
+		}
+		return nil
+	}
+	s := d.Resolve(p.Schema)
+	if s.Type == "array" {
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = d.parseParameter(s.Items, value)
+		}
+		return d.Validate(p.Schema, p.Name, items)
+	}
+	if len(values) > 1 {
+		return Errors{{Field: p.Name, Code: CodeInvalidValue,
+			Detail: "can't be repeated"}}
+	}
+	return d.Validate(p.Schema, p.Name, d.parseParameter(s, values[0]))
+}
+
+// parseParameter converts value to the type of s. Value is left as it is if
+// it's not of the type, which fails the validation.
+func (d *Document) parseParameter(s *Schema, value string) interface{} {
+	switch d.Resolve(s).Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// ValidateRequest validates parameters of r in path and query, and body of
+// the media type by "Content-Type" against op. Body of an unknown media type
+// is validated as "application/json" if op takes it. It returns Errors if
+// anything is invalid, or an error if body is not json.
+func (d *Document) ValidateRequest(op *Operation, r *http.Request,
+	pathParams map[string]string, body []byte) error {
+	var errs Errors
+	qs := r.URL.Query()
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			if v, ok := pathParams[p.Name]; ok {
+				errs = append(errs, d.ValidateParameter(p, []string{v})...)
+			}
+		case "query":
+			errs = append(errs, d.ValidateParameter(p, qs[p.Name])...)
+		}
+	}
+	if op.RequestBody != nil {
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		media := op.RequestBody.Content[contentType]
+		if media == nil {
+			media = op.RequestBody.Content["application/json"]
+		}
+		if media != nil && (len(body) > 0 || op.RequestBody.Required) {
+			var v interface{}
+			if err := json.Unmarshal(body, &v); err != nil {
+				return errors.New("invalid data")
+			}
+			invalid := d.Validate(media.Schema, "", v)
+			if len(invalid) == 1 && invalid[0].Field == "" {
+				// Body itself is of the wrong type
+				return errors.New("invalid data")
+			}
+			errs = append(errs, invalid...)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	errs.Sort()
+	return errs
+}
@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+// decode decodes s as a request body.
+func decode(s string) interface{} {
+	var v interface{}
+	json.Unmarshal([]byte(s), &v)
+	return v
+}
+
+func TestDocument_Validate(t *testing.T) {
+	d := CreateDocument()
+	product := Component("Product")
+
+	valid := `{"productId": "001", "name": "One", "image_closed": "",
+		"image_open": "", "description": "", "story": "",
+		"sourcing_values": ["Fairtrade"], "allergy_info": "",
+		"dietary_certifications": "", "created_at": 1,
+		"structured_ingredients": [{"name": "sugar"}],
+		"allergens": {"contains": ["milk"]}}`
+	assert.Empty(t, d.Validate(product, "", decode(valid)))
+
+	errs := d.Validate(product, "", decode(`{"productId": "001",
+		"name": 1, "image_closed": null, "sourcing_values": ["a", 1],
+		"allergens": {"contains": ["nuts"], "traces": []},
+		"structured_ingredients": [{"name": " sugar"}], "unknown": 1}`))
+	errs.Sort()
+	assert.Equal(t, Errors{
+		{"allergens.contains[0]", CodeInvalidValue, "unknown allergen nuts"},
+		{"allergens.traces", CodeUnknownField, "unknown field"},
+		{"allergy_info", CodeMissingField, "missing field"},
+		{"description", CodeMissingField, "missing field"},
+		{"dietary_certifications", CodeMissingField, "missing field"},
+		{"image_closed", CodeInvalidType, "can't be null"},
+		{"image_open", CodeMissingField, "missing field"},
+		{"name", CodeInvalidType, "not a string"},
+		{"sourcing_values[1]", CodeInvalidType, "not a string"},
+		{"story", CodeMissingField, "missing field"},
+		{"structured_ingredients[0].name", CodeInvalidValue,
+			`doesn't match ^[^\s,()\[\]]([^,()\[\]]*[^\s,()\[\]])?$`},
+		{"unknown", CodeUnknownField, "unknown field"},
+	}, errs)
+
+	// Either ingredients or structured_ingredients is required
+	errs = d.Validate(product, "", decode(`{"productId": "001",
+		"name": "One", "image_closed": "", "image_open": "",
+		"description": "", "story": "", "sourcing_values": [],
+		"allergy_info": "", "dietary_certifications": ""}`))
+	assert.Equal(t, Errors{{"ingredients", CodeMissingField,
+		"missing field"}}, errs)
+
+	// Null resets fields of a patch, but not productId
+	patch := Component("ProductPatch")
+	assert.Empty(t, d.Validate(patch, "", decode(`{"story": null,
+		"structured_ingredients": null, "allergens": {"contains": null}}`)))
+	assert.Equal(t, Errors{{"productId", CodeInvalidType, "can't be null"}},
+		d.Validate(patch, "", decode(`{"productId": null}`)))
+
+	assert.Empty(t, d.Validate(Component("JSONPatch"), "",
+		decode(`[{"op": "add", "path": "/story", "value": null}]`)))
+	assert.Equal(t, Errors{{"[0].op", CodeInvalidValue, "unknown value put"}},
+		d.Validate(Component("JSONPatch"), "",
+			decode(`[{"op": "put", "path": "/story"}]`)))
+}
+
+func TestDocument_ValidateParameter(t *testing.T) {
+	d := CreateDocument()
+	op := d.Operation("GET", "/products/")
+	parameter := func(name string) *Parameter {
+		for _, p := range op.Parameters {
+			if p.Name == name {
+				return p
+			}
+		}
+		t.Fatalf("no parameter %s", name)
+		return nil
+	}
+
+	cases := []struct {
+		name   string
+		values []string
+		errs   Errors
+	}{
+		{"limit", nil, nil},
+		{"limit", []string{""}, nil},
+		{"limit", []string{"10"}, nil},
+		{"limit", []string{"x"}, Errors{{"limit", CodeInvalidType,
+			"not an integer"}}},
+		{"limit", []string{"0"}, Errors{{"limit", CodeInvalidValue,
+			"less than 1"}}},
+		{"limit", []string{"1", "2"}, Errors{{"limit", CodeInvalidValue,
+			"can't be repeated"}}},
+		{"sort", []string{"-name"}, nil},
+		{"sort", []string{"story"}, Errors{{"sort", CodeInvalidValue,
+			"unknown value story"}}},
+		{"count", []string{"true"}, nil},
+		{"ingredient", []string{"milk", "sugar"}, nil},
+		{"ingredient", []string{"milk", ""}, Errors{{"ingredient[1]",
+			CodeInvalidValue, "shorter than 1"}}},
+		{"free_from", []string{"milk,tree_nuts", "soy"}, nil},
+		{"free_from", []string{"milk,nuts"}, Errors{{"free_from[0]",
+			CodeInvalidValue, "doesn't match " +
+				parameter("free_from").Schema.Items.Pattern}}},
+		{"name_prefix", []string{""}, Errors{{"name_prefix",
+			CodeInvalidValue, "shorter than 1"}}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.errs, d.ValidateParameter(parameter(c.name),
+			c.values), "%s=%v", c.name, c.values)
+	}
+
+	search := d.Operation("GET", "/products/search")
+	assert.Equal(t, Errors{{"q", CodeMissingField, "missing parameter"}},
+		d.ValidateParameter(search.Parameters[0], nil))
+}
+
+func TestDocument_ValidateRequest(t *testing.T) {
+	d := CreateDocument()
+	op := d.Operation("PATCH", "/products/{productID}")
+	request := func(contentType, body string) *http.Request {
+		r, _ := http.NewRequest("PATCH", "/products/001",
+			bytes.NewBufferString(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+	validate := func(r *http.Request, body string) error {
+		return d.ValidateRequest(op, r, map[string]string{"productID": "001"},
+			[]byte(body))
+	}
+
+	// By the media type
+	body := `[{"op": "remove", "path": "/story"}]`
+	assert.NoError(t, validate(request("application/json-patch+json", body),
+		body))
+	assert.EqualError(t, validate(request("application/merge-patch+json",
+		body), body), "invalid data")
+
+	body = `{"story": null}`
+	assert.NoError(t, validate(request("application/merge-patch+json", body),
+		body))
+	// Unknown media types are of application/json
+	assert.NoError(t, validate(request("", body), body))
+	assert.NoError(t, validate(request("text/plain; charset=utf-8", body),
+		body))
+
+	body = `{"name": 1}`
+	assert.Equal(t, Errors{{"name", CodeInvalidType, "not a string"}},
+		validate(request("application/json", body), body))
+	assert.EqualError(t, validate(request("application/json", "{"), "{"),
+		"invalid data")
+
+	// Path parameters
+	op = d.Operation("GET", "/products/{productID}/revisions/{revision}")
+	r, _ := http.NewRequest("GET", "/products/001/revisions/0", nil)
+	assert.Equal(t, Errors{{"revision", CodeInvalidValue, "less than 1"}},
+		d.ValidateRequest(op, r, map[string]string{"productID": "001",
+			"revision": "0"}, nil))
+}
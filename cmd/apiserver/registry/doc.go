@@ -0,0 +1,2 @@
+// Package registry provides backends for apiserver by name
+package registry
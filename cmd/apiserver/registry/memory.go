@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/middleware"
+	"github.com/cfchou/icecream/cmd/apiserver/util"
+	"github.com/cfchou/icecream/pkg/backend/memory"
+	pe "github.com/pkg/errors"
+	"io"
+	"os"
+)
+
+func init() {
+	Register("memory", createMemoryBackends)
+}
+
+// createMemoryBackends creates in-memory backends and preloads them from the
+// files given by "products" and "apikeys" in dbConf, if any.
+func createMemoryBackends(dbConf util.Conf) (handler.ProductBackend,
+	middleware.APIKeyBackend, func(), error) {
+	productBackend, _ := memory.CreateMemoryProductBackend()
+	apiKeyBackend, _ := memory.CreateMemoryAPIKeyBackend()
+	if path := dbConf.GetString("products"); path != "" {
+		if err := loadFile(path, productBackend.Load); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if path := dbConf.GetString("apikeys"); path != "" {
+		if err := loadFile(path, apiKeyBackend.Load); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return productBackend, apiKeyBackend, func() {}, nil
+}
+
+func loadFile(path string, load func(r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return pe.WithStack(err)
+	}
+	defer f.Close()
+	return load(f)
+}
@@ -0,0 +1,41 @@
+package registry
+
+import (
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/middleware"
+	"github.com/cfchou/icecream/cmd/apiserver/util"
+	"github.com/cfchou/icecream/pkg/backend/mongodb"
+	"github.com/globalsign/mgo"
+	pe "github.com/pkg/errors"
+)
+
+func init() {
+	Register("mongodb", createMongoBackends)
+}
+
+// createMongoBackends dials mongoDB by "host", "port", "database", "user" and
+// "password" in dbConf, and ensures indexes backends rely on.
+func createMongoBackends(dbConf util.Conf) (handler.ProductBackend,
+	middleware.APIKeyBackend, func(), error) {
+	url := util.CreateMongoURL(dbConf, dbConf.GetString("appName"))
+	session, err := mgo.Dial(url)
+	if err != nil {
+		return nil, nil, nil, pe.Wrap(err, "mgo.Dial failed")
+	}
+	// Uniqueness of productId and apikey is enforced by indexes.
+	if err := mongodb.EnsureIndexes(session); err != nil {
+		session.Close()
+		return nil, nil, nil, err
+	}
+	if migrator, err := mongodb.CreateMigrator(session); err != nil {
+		log.Warn("CreateMigrator failed", "err", err)
+	} else if version, err := migrator.Version(); err != nil {
+		log.Warn("Unknown version of migrations", "err", err)
+	} else if version < migrator.Latest() {
+		log.Warn("Pending migrations, run apiserver migrate up",
+			"version", version, "latest", migrator.Latest())
+	}
+	productBackend, _ := mongodb.CreateMongoProductBackend(session)
+	apiKeyBackend, _ := mongodb.CreateMongoAPIKeyBackend(session)
+	return productBackend, apiKeyBackend, session.Close, nil
+}
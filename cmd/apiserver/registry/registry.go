@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"fmt"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/middleware"
+	"github.com/cfchou/icecream/cmd/apiserver/util"
+	"github.com/inconshreveable/log15"
+	pe "github.com/pkg/errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultType is the type of backends created when no type is configured.
+const DefaultType = "mongodb"
+
+// Factory creates backends from dbConf, the config sub-tree of "db". The
+// returned function releases resources held by the backends.
+type Factory func(dbConf util.Conf) (handler.ProductBackend,
+	middleware.APIKeyBackend, func(), error)
+
+var (
+	log       = log15.New("module", "registry")
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a Factory available by name. It panics if Register is called
+// twice with the same name or if factory is nil.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic("registry: Register factory is nil")
+	}
+	if _, ok := factories[name]; ok {
+		panic("registry: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Types returns a sorted list of names of registered factories.
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create creates backends by the factory registered as "type" in dbConf, or
+// DefaultType if "type" is empty.
+func Create(dbConf util.Conf) (handler.ProductBackend,
+	middleware.APIKeyBackend, func(), error) {
+	name := dbConf.GetString("type")
+	if name == "" {
+		name = DefaultType
+	}
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, nil, nil, pe.New(fmt.Sprintf(
+			"unknown db type:%s, available:%s", name,
+			strings.Join(Types(), ",")))
+	}
+	log.Info("Create backends", "type", name)
+	return factory(dbConf)
+}
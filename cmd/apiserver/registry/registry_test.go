@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"context"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/middleware"
+	"github.com/cfchou/icecream/cmd/apiserver/util"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var ctx = context.Background()
+
+func TestTypes(t *testing.T) {
+	assert.Equal(t, []string{"memory", "mongodb", "sqlite"}, Types())
+}
+
+func TestRegister_Twice(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("memory", createMemoryBackends)
+	})
+}
+
+func TestCreate(t *testing.T) {
+	dbConf := viper.New()
+	dbConf.Set("type", "memory")
+	dbConf.Set("products", "../../../icecream.json")
+	dbConf.Set("apikeys", "../../../apikey.json")
+
+	productBackend, apiKeyBackend, closer, err := Create(dbConf)
+	assert.NoError(t, err)
+	defer closer()
+
+	product, err := productBackend.Read(ctx, "646")
+	assert.NoError(t, err)
+	assert.Equal(t, "646", product.ProductID)
+	assert.NoError(t, apiKeyBackend.Authenticate(ctx, "testkey"))
+}
+
+func TestCreate_SQLiteLoadsOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "icecream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbConf := viper.New()
+	dbConf.Set("type", "sqlite")
+	dbConf.Set("path", filepath.Join(dir, "icecream.db"))
+	dbConf.Set("products", "../../../icecream.json")
+
+	productBackend, _, closer, err := Create(dbConf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, productBackend.UpdatePartial(ctx, "646",
+		map[string]interface{}{"name": "changed"}))
+	closer()
+
+	// Products changed are kept on restart
+	productBackend, _, closer, err = Create(dbConf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer closer()
+	product, err := productBackend.Read(ctx, "646")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "changed", product.Name)
+		assert.Equal(t, int64(2), product.Version)
+	}
+}
+
+func TestCreate_Factory(t *testing.T) {
+	called := false
+	Register("test", func(dbConf util.Conf) (handler.ProductBackend,
+		middleware.APIKeyBackend, func(), error) {
+		called = true
+		assert.Equal(t, "value", dbConf.GetString("key"))
+		return nil, nil, func() {}, nil
+	})
+	defer func() {
+		mu.Lock()
+		delete(factories, "test")
+		mu.Unlock()
+	}()
+
+	dbConf := viper.New()
+	dbConf.Set("type", "test")
+	dbConf.Set("key", "value")
+	_, _, _, err := Create(dbConf)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestCreate_UnknownType(t *testing.T) {
+	dbConf := viper.New()
+	dbConf.Set("type", "unknown")
+	_, _, _, err := Create(dbConf)
+	assert.Error(t, err)
+}
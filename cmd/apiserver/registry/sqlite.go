@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/cmd/apiserver/middleware"
+	"github.com/cfchou/icecream/cmd/apiserver/util"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	sqlbackend "github.com/cfchou/icecream/pkg/backend/sql"
+	_ "github.com/mattn/go-sqlite3"
+	pe "github.com/pkg/errors"
+	"io"
+)
+
+func init() {
+	Register("sqlite", createSQLiteBackends)
+}
+
+// createSQLiteBackends opens the database file "path" in dbConf and creates
+// the schema if necessary. Products in the file given by "products" in
+// dbConf, if any, are loaded only if the database has none, so that changes
+// made since are kept. API keys in the file given by "apikeys" are added.
+func createSQLiteBackends(dbConf util.Conf) (handler.ProductBackend,
+	middleware.APIKeyBackend, func(), error) {
+	db, err := sql.Open("sqlite3", dbConf.GetString("path"))
+	if err != nil {
+		return nil, nil, nil, pe.Wrap(err, "sql.Open failed")
+	}
+	// SQLite allows only one writer at a time
+	db.SetMaxOpenConns(1)
+	closer := func() { db.Close() }
+	if err := sqlbackend.CreateSchema(db); err != nil {
+		closer()
+		return nil, nil, nil, err
+	}
+	productBackend, _ := sqlbackend.CreateSQLProductBackend(db)
+	apiKeyBackend, _ := sqlbackend.CreateSQLAPIKeyBackend(db)
+	empty, err := sqlbackend.IsEmpty(db)
+	if err != nil {
+		closer()
+		return nil, nil, nil, err
+	}
+	if path := dbConf.GetString("products"); path != "" && empty {
+		err := loadFile(path, func(r io.Reader) error {
+			return decodeAll(r, func(dec *json.Decoder) error {
+				var product model.Product
+				if err := dec.Decode(&product); err != nil {
+					return err
+				}
+				product.Ingredients = model.NormalizeIngredients(
+					product.Ingredients)
+				if product.Allergens.IsEmpty() {
+					product.Allergens = model.ParseAllergyInfo(
+						product.AllergyInfo)
+				}
+				return productBackend.Create(context.Background(), &product)
+			})
+		})
+		if err != nil {
+			closer()
+			return nil, nil, nil, err
+		}
+	}
+	if path := dbConf.GetString("apikeys"); path != "" {
+		err := loadFile(path, func(r io.Reader) error {
+			return decodeAll(r, func(dec *json.Decoder) error {
+				var key model.APIKey
+				if err := dec.Decode(&key); err != nil {
+					return err
+				}
+				return apiKeyBackend.Add(key.APIKey)
+			})
+		})
+		if err != nil {
+			closer()
+			return nil, nil, nil, err
+		}
+	}
+	return productBackend, apiKeyBackend, closer, nil
+}
+
+// decodeAll calls f on a stream of JSON values until EOF.
+func decodeAll(r io.Reader, f func(dec *json.Decoder) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		if err := f(dec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return pe.WithStack(err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"github.com/cfchou/icecream/cmd/apiserver/handler"
+	"github.com/cfchou/icecream/pkg/backend"
+	"time"
+)
+
+// purgeTrash purges products trashed for longer than retention, then again
+// every interval, which must be positive. It never returns.
+func purgeTrash(b handler.ProductBackend, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		n, err := b.PurgeTrashedBefore(ctx, backend.Now().Add(-retention))
+		cancel()
+		if err != nil {
+			log.Error("PurgeTrashedBefore failed", "err", err)
+		} else if n > 0 {
+			log.Info("Purge trash", "purged", n)
+		}
+		<-ticker.C
+	}
+}
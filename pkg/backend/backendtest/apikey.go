@@ -0,0 +1,34 @@
+package backendtest
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend"
+	"testing"
+)
+
+// APIKeyBackend has the same method set as middleware.APIKeyBackend.
+type APIKeyBackend interface {
+	Authenticate(ctx context.Context, apiKey string) error
+}
+
+// APIKeyBackendFactory creates a backend storing apiKeys and a function to
+// release it.
+type APIKeyBackendFactory func(t *testing.T, apiKeys []string) (APIKeyBackend, func())
+
+// TestAPIKeyBackend runs the suite against backends created by newBackend.
+func TestAPIKeyBackend(t *testing.T, newBackend APIKeyBackendFactory) {
+	t.Run("Authenticate", func(t *testing.T) {
+		b, release := newBackend(t, []string{"testkey", "0123456789"})
+		defer release()
+
+		requireNoError(t, b.Authenticate(ctx, "testkey"))
+		requireNoError(t, b.Authenticate(ctx, "0123456789"))
+		assertCause(t, backend.ErrNotFound, b.Authenticate(ctx, "nokey"))
+		assertCause(t, backend.ErrNotFound, b.Authenticate(ctx, ""))
+		assertCause(t, backend.ErrNotFound, b.Authenticate(ctx, "TESTKEY"))
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		assertCause(t, context.Canceled, b.Authenticate(canceled, "testkey"))
+	})
+}
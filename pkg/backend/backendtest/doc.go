@@ -0,0 +1,16 @@
+/*
+Package backendtest provides a conformance test suite for backends of Product
+and APIKey. A backend package runs the suite in its own tests, e.g.
+
+	func TestMemoryProductBackend_Conformance(t *testing.T) {
+		backendtest.TestProductBackend(t, func(t *testing.T) (backendtest.ProductBackend, func()) {
+			b, _ := CreateMemoryProductBackend()
+			return b, func() {}
+		})
+	}
+
+The suite pins down the contract documented in handler.ProductBackend and
+middleware.APIKeyBackend so that every backend behaves the same, including
+the errors in package backend returned as the cause.
+*/
+package backendtest
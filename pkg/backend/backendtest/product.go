@@ -0,0 +1,1246 @@
+package backendtest
+
+import (
+	"context"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// ProductBackend has the same method set as handler.ProductBackend.
+type ProductBackend interface {
+	Create(ctx context.Context, product *model.Product) error
+	Read(ctx context.Context, productID string) (*model.Product, error)
+	ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error)
+	Update(ctx context.Context, product *model.Product) error
+	UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error
+	Upsert(ctx context.Context, product *model.Product) error
+	Delete(ctx context.Context, productID string) error
+	UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error
+	UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error
+	DeleteIfMatch(ctx context.Context, productID string, version int64) error
+	ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error)
+	ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error)
+	ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error)
+	RestoreTrashed(ctx context.Context, productID string) error
+	PurgeTrashed(ctx context.Context, productID string) error
+	PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+// ProductSearcher has the same method set as handler.ProductSearcher. Cases of
+// Search are skipped for backends not implementing it.
+type ProductSearcher interface {
+	Search(ctx context.Context, query string, cursor string, limit int) (*model.SearchResults, error)
+}
+
+// ProductCounter has the same method set as handler.ProductCounter. Cases of
+// Count are skipped for backends not implementing it.
+type ProductCounter interface {
+	Count(ctx context.Context, filter *model.Filter) (int64, error)
+}
+
+// ctx is passed to backends by cases that don't test cancellation.
+var ctx = context.Background()
+
+// ProductBackendFactory creates an empty backend and a function to release it.
+type ProductBackendFactory func(t *testing.T) (ProductBackend, func())
+
+// createProduct creates a Product having every field set.
+func createProduct(productID string) *model.Product {
+	return &model.Product{
+		ProductID:             productID,
+		Name:                  "name " + productID,
+		ImageClosed:           "/closed/" + productID + ".png",
+		ImageOpen:             "/open/" + productID + ".png",
+		Description:           "description " + productID,
+		Story:                 "story " + productID,
+		SourcingValues:        []string{"Non-GMO", "Fairtrade"},
+		Ingredients:           []string{"cream", "skim milk", productID},
+		AllergyInfo:           "contains milk",
+		DietaryCertifications: "Kosher",
+	}
+}
+
+// normalize makes nil and empty slices compare equal since backends may not
+// tell them apart. Versions and timestamps are left to cases checking them.
+func normalize(product *model.Product) *model.Product {
+	cp := *product
+	cp.Version = 0
+	cp.CreatedAt = time.Time{}
+	cp.UpdatedAt = time.Time{}
+	if cp.SourcingValues == nil {
+		cp.SourcingValues = []string{}
+	}
+	if cp.Ingredients == nil {
+		cp.Ingredients = []string{}
+	}
+	if cp.Allergens.Contains == nil {
+		cp.Allergens.Contains = []model.Allergen{}
+	}
+	if cp.Allergens.MayContain == nil {
+		cp.Allergens.MayContain = []model.Allergen{}
+	}
+	// Cases leave it to be derived by backends.
+	if cp.StructuredIngredients == nil {
+		cp.StructuredIngredients = model.ParseIngredients(cp.Ingredients)
+	}
+	return &cp
+}
+
+func assertProduct(t *testing.T, expected *model.Product, actual *model.Product) {
+	if assert.NotNil(t, actual) {
+		assert.Equal(t, normalize(expected), normalize(actual))
+	}
+}
+
+func requireNoError(t *testing.T, err error) {
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+}
+
+func assertCause(t *testing.T, expected error, err error) {
+	if assert.Error(t, err) {
+		assert.Equal(t, expected, pe.Cause(err))
+	}
+}
+
+// TestProductBackend runs the suite against backends created by newBackend.
+// Every case runs as a subtest on its own backend.
+func TestProductBackend(t *testing.T, newBackend ProductBackendFactory) {
+	cases := []struct {
+		name string
+		f    func(t *testing.T, b ProductBackend)
+	}{
+		{"Create", testCreate},
+		{"CreateExisted", testCreateExisted},
+		{"CreateInvalid", testCreateInvalid},
+		{"ReadNotFound", testReadNotFound},
+		{"Upsert", testUpsert},
+		{"Update", testUpdate},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"UpdatePartial", testUpdatePartial},
+		{"UpdatePartialNull", testUpdatePartialNull},
+		{"UpdatePartialInvalid", testUpdatePartialInvalid},
+		{"UpdatePartialNotFound", testUpdatePartialNotFound},
+		{"ReadMany", testReadMany},
+		{"ReadManyInvalid", testReadManyInvalid},
+		{"ReadManyKeepsOrder", testReadManyKeepsOrder},
+		{"ReadManySorted", testReadManySorted},
+		{"ReadManyFilter", testReadManyFilter},
+		{"ReadManyFilterPages", testReadManyFilterPages},
+		{"StructuredIngredients", testStructuredIngredients},
+		{"Allergens", testAllergens},
+		{"Count", testCount},
+		{"Search", testSearch},
+		{"SearchInvalid", testSearchInvalid},
+		{"SearchAfterWrites", testSearchAfterWrites},
+		{"SearchPages", testSearchPages},
+		{"Delete", testDelete},
+		{"DeleteNotFound", testDeleteNotFound},
+		{"Canceled", testCanceled},
+		{"Version", testVersion},
+		{"UpdateIfMatch", testUpdateIfMatch},
+		{"UpdatePartialIfMatch", testUpdatePartialIfMatch},
+		{"DeleteIfMatch", testDeleteIfMatch},
+		{"IfMatchInvalid", testIfMatchInvalid},
+		{"Timestamps", testTimestamps},
+		{"Revisions", testRevisions},
+		{"RevisionsAfterRecreate", testRevisionsAfterRecreate},
+		{"ReadRevisionInvalid", testReadRevisionInvalid},
+		{"Trash", testTrash},
+		{"ReadTrashPages", testReadTrashPages},
+		{"RestoreTrashed", testRestoreTrashed},
+		{"RestoreTrashedExisted", testRestoreTrashedExisted},
+		{"PurgeTrashed", testPurgeTrashed},
+		{"PurgeTrashedBefore", testPurgeTrashedBefore},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			b, release := newBackend(t)
+			defer release()
+			c.f(t, b)
+		})
+	}
+}
+
+func testCreate(t *testing.T, b ProductBackend) {
+	product := createProduct("001")
+	requireNoError(t, b.Create(ctx, product))
+
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, product, result)
+}
+
+func testCreateExisted(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	// Create is exclusive and doesn't modify the existing one.
+	product := createProduct("001")
+	product.Name = "another"
+	assertCause(t, backend.ErrAlreadyExists, b.Create(ctx, product))
+
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, createProduct("001"), result)
+}
+
+func testCreateInvalid(t *testing.T, b ProductBackend) {
+	assertCause(t, backend.ErrInvalidArgument, b.Create(ctx, createProduct("")))
+	assertCause(t, backend.ErrInvalidArgument, b.Upsert(ctx, createProduct("")))
+	assertCause(t, backend.ErrInvalidArgument, b.Update(ctx, createProduct("")))
+}
+
+func testReadNotFound(t *testing.T, b ProductBackend) {
+	_, err := b.Read(ctx, "001")
+	assertCause(t, backend.ErrNotFound, err)
+
+	_, err = b.Read(ctx, "")
+	assertCause(t, backend.ErrInvalidArgument, err)
+}
+
+func testUpsert(t *testing.T, b ProductBackend) {
+	product := createProduct("001")
+	requireNoError(t, b.Upsert(ctx, product))
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, product, result)
+
+	// Upsert replaces every field.
+	product = &model.Product{
+		ProductID:   "001",
+		Name:        "replaced",
+		Ingredients: []string{"milk"},
+	}
+	requireNoError(t, b.Upsert(ctx, product))
+	result, err = b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, product, result)
+}
+
+func testUpdate(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	product := &model.Product{
+		ProductID:      "001",
+		Name:           "updated",
+		SourcingValues: []string{"Caring Dairy"},
+	}
+	requireNoError(t, b.Update(ctx, product))
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, product, result)
+}
+
+func testUpdateNotFound(t *testing.T, b ProductBackend) {
+	assertCause(t, backend.ErrNotFound, b.Update(ctx, createProduct("001")))
+
+	// Update doesn't insert.
+	_, err := b.Read(ctx, "001")
+	assertCause(t, backend.ErrNotFound, err)
+}
+
+func testUpdatePartial(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	requireNoError(t, b.UpdatePartial(ctx, "001", map[string]interface{}{
+		"productId":   "001",
+		"story":       "updated",
+		"ingredients": []interface{}{"soy", "milk"},
+	}))
+	expected := createProduct("001")
+	expected.Story = "updated"
+	expected.Ingredients = []string{"soy", "milk"}
+
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, expected, result)
+}
+
+func testUpdatePartialNull(t *testing.T, b ProductBackend) {
+	product := createProduct("001")
+	product.Allergens = model.Allergens{Contains: []model.Allergen{model.Milk}}
+	requireNoError(t, b.Create(ctx, product))
+
+	// Null resets fields to their zero values
+	requireNoError(t, b.UpdatePartial(ctx, "001", map[string]interface{}{
+		"story":           nil,
+		"sourcing_values": nil,
+		"allergens":       nil,
+	}))
+	expected := createProduct("001")
+	expected.Story = ""
+	expected.SourcingValues = []string{}
+
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, expected, result)
+	assert.True(t, result.Allergens.IsEmpty())
+}
+
+func testUpdatePartialInvalid(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	for _, kvs := range []map[string]interface{}{
+		// unknown key
+		{"unknown": "value"},
+		// mismatched productId
+		{"productId": "002"},
+		// mismatched type
+		{"name": 1},
+		{"ingredients": "milk"},
+	} {
+		assertCause(t, backend.ErrInvalidArgument, b.UpdatePartial(ctx, "001", kvs))
+	}
+	assertCause(t, backend.ErrInvalidArgument, b.UpdatePartial(ctx, "",
+		map[string]interface{}{"name": "updated"}))
+
+	// Nothing is changed.
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, createProduct("001"), result)
+}
+
+func testUpdatePartialNotFound(t *testing.T, b ProductBackend) {
+	assertCause(t, backend.ErrNotFound, b.UpdatePartial(ctx, "001",
+		map[string]interface{}{"name": "updated"}))
+}
+
+// readAll reads every page in order and checks pages but the last one are
+// full.
+func readAll(t *testing.T, b ProductBackend, limit int, order model.Sort) []string {
+	var ids []string
+	cursor := ""
+	for {
+		page, err := b.ReadMany(ctx, cursor, limit, nil, order)
+		requireNoError(t, err)
+		if len(page.Products) > limit ||
+			(page.Cursor != "" && len(page.Products) != limit) {
+			t.Fatalf("Invalid page, limit:%d, count:%d, cursor:%q", limit,
+				len(page.Products), page.Cursor)
+		}
+		for _, p := range page.Products {
+			ids = append(ids, p.ProductID)
+		}
+		if page.Cursor == "" {
+			return ids
+		}
+		if len(ids) > 1000 {
+			t.Fatal("ReadMany doesn't end")
+		}
+		cursor = page.Cursor
+	}
+}
+
+func testReadMany(t *testing.T, b ProductBackend) {
+	// empty
+	page, err := b.ReadMany(ctx, "", 10, nil, "")
+	requireNoError(t, err)
+	assert.Empty(t, page.Products)
+	assert.Empty(t, page.Cursor)
+
+	var expected []string
+	for i := 0; i < 7; i++ {
+		id := fmt.Sprintf("%03d", i)
+		requireNoError(t, b.Create(ctx, createProduct(id)))
+		expected = append(expected, id)
+	}
+	// Every product is read exactly once, in the order of creation.
+	for _, limit := range []int{1, 2, 3, 6, 7, 8} {
+		assert.Equal(t, expected, readAll(t, b, limit, ""),
+			"limit:%d", limit)
+	}
+
+	page, err = b.ReadMany(ctx, "", 3, nil, "")
+	requireNoError(t, err)
+	for i, p := range page.Products {
+		assertProduct(t, createProduct(expected[i]), &p)
+	}
+}
+
+func testReadManyInvalid(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	_, err := b.ReadMany(ctx, "", 0, nil, "")
+	assertCause(t, backend.ErrInvalidArgument, err)
+	_, err = b.ReadMany(ctx, "", -1, nil, "")
+	assertCause(t, backend.ErrInvalidArgument, err)
+	for _, order := range []model.Sort{"-", "size", "-size", "--name"} {
+		_, err = b.ReadMany(ctx, "", 10, nil, order)
+		assertCause(t, backend.ErrInvalidArgument, err)
+	}
+
+	// Malformed cursors are rejected rather than read from the first page.
+	requireNoError(t, b.Create(ctx, createProduct("002")))
+	for _, order := range []model.Sort{"", "name", "-updated_at"} {
+		page, err := b.ReadMany(ctx, "", 1, nil, order)
+		requireNoError(t, err)
+		for _, cursor := range []string{"malformed", page.Cursor + "x",
+			backend.JoinCursor("name", "malformed")} {
+			_, err = b.ReadMany(ctx, cursor, 1, nil, order)
+			assertCause(t, backend.ErrInvalidArgument, err)
+		}
+	}
+}
+
+// sortedIDs sorts products, which are in the order of insertion, by order
+// like ReadMany does and returns their productIds.
+func sortedIDs(products []model.Product, order model.Sort) []string {
+	field := order.Field()
+	sorted := append([]model.Product{}, products...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a := backend.SortKey(&sorted[i], field)
+		b := backend.SortKey(&sorted[j], field)
+		if field == "updated_at" {
+			ma, _ := strconv.ParseInt(a, 10, 64)
+			mb, _ := strconv.ParseInt(b, 10, 64)
+			return ma < mb
+		}
+		return a < b
+	})
+	var ids []string
+	for _, p := range sorted {
+		ids = append(ids, p.ProductID)
+	}
+	if order.IsDescending() {
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+	return ids
+}
+
+func testReadManySorted(t *testing.T, b ProductBackend) {
+	// Names and productIds are not in the order of insertion, and some names
+	// are the same.
+	products := []struct{ productID, name string }{
+		{"005", "Fudge"}, {"001", "Caramel"}, {"006", "Vanilla"},
+		{"000", "Caramel"}, {"004", "Fudge"}, {"002", "Apple"},
+		{"003", "Caramel"},
+	}
+	for _, p := range products {
+		product := createProduct(p.productID)
+		product.Name = p.name
+		requireNoError(t, b.Create(ctx, product))
+	}
+	// Timestamps are in milliseconds.
+	time.Sleep(2 * time.Millisecond)
+	requireNoError(t, b.UpdatePartial(ctx, "006",
+		map[string]interface{}{"story": "updated"}))
+	time.Sleep(2 * time.Millisecond)
+	requireNoError(t, b.UpdatePartial(ctx, "001",
+		map[string]interface{}{"story": "updated"}))
+
+	page, err := b.ReadMany(ctx, "", 10, nil, "")
+	requireNoError(t, err)
+	for _, order := range []model.Sort{"name", "-name", "productId",
+		"-productId", "updated_at", "-updated_at"} {
+		expected := sortedIDs(page.Products, order)
+		for _, limit := range []int{1, 2, 3, 7, 8} {
+			assert.Equal(t, expected, readAll(t, b, limit, order),
+				"sort:%s, limit:%d", order, limit)
+		}
+	}
+	assert.Equal(t, []string{"002", "001", "000", "003", "005", "004",
+		"006"}, readAll(t, b, 2, "name"))
+	assert.Equal(t, []string{"006", "004", "005", "003", "000", "001",
+		"002"}, readAll(t, b, 2, "-name"))
+
+	// Sorted pages are filtered as well.
+	page, err = b.ReadMany(ctx, "", 10,
+		&model.Filter{NamePrefix: "Caramel"}, "-productId")
+	requireNoError(t, err)
+	var productIDs []string
+	for _, p := range page.Products {
+		productIDs = append(productIDs, p.ProductID)
+	}
+	assert.Equal(t, []string{"003", "001", "000"}, productIDs)
+}
+
+func testReadManyKeepsOrder(t *testing.T, b ProductBackend) {
+	for _, id := range []string{"001", "002", "003"} {
+		requireNoError(t, b.Create(ctx, createProduct(id)))
+	}
+	// Replacing or updating doesn't move a product.
+	requireNoError(t, b.Upsert(ctx, createProduct("001")))
+	requireNoError(t, b.Update(ctx, createProduct("002")))
+	requireNoError(t, b.UpdatePartial(ctx, "001",
+		map[string]interface{}{"name": "updated"}))
+	// Deleting doesn't disturb the others.
+	requireNoError(t, b.Delete(ctx, "002"))
+	requireNoError(t, b.Create(ctx, createProduct("004")))
+
+	assert.Equal(t, []string{"001", "003", "004"}, readAll(t, b, 2, ""))
+}
+
+func testDelete(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	requireNoError(t, b.Create(ctx, createProduct("002")))
+
+	requireNoError(t, b.Delete(ctx, "001"))
+	_, err := b.Read(ctx, "001")
+	assertCause(t, backend.ErrNotFound, err)
+
+	_, err = b.Read(ctx, "002")
+	assert.NoError(t, err)
+
+	// Deleted productId can be created again.
+	assert.NoError(t, b.Create(ctx, createProduct("001")))
+}
+
+func testDeleteNotFound(t *testing.T, b ProductBackend) {
+	assertCause(t, backend.ErrNotFound, b.Delete(ctx, "001"))
+	assertCause(t, backend.ErrInvalidArgument, b.Delete(ctx, ""))
+}
+
+func testCanceled(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := b.Read(canceled, "001")
+	assertCause(t, context.Canceled, err)
+	_, err = b.ReadMany(canceled, "", 10, nil, "")
+	assertCause(t, context.Canceled, err)
+	assertCause(t, context.Canceled, b.Create(canceled, createProduct("002")))
+	assertCause(t, context.Canceled, b.Delete(canceled, "001"))
+
+	// Nothing is changed.
+	_, err = b.Read(ctx, "001")
+	requireNoError(t, err)
+	_, err = b.Read(ctx, "002")
+	assertCause(t, backend.ErrNotFound, err)
+}
+
+// assertVersion checks the version of the Product of productID.
+func assertVersion(t *testing.T, b ProductBackend, productID string, version int64) {
+	result, err := b.Read(ctx, productID)
+	requireNoError(t, err)
+	assert.Equal(t, version, result.Version, "productId:%s", productID)
+}
+
+func testVersion(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	assertVersion(t, b, "001", 1)
+	requireNoError(t, b.Upsert(ctx, createProduct("002")))
+	assertVersion(t, b, "002", 1)
+
+	// Every write increases the version by 1, even if nothing is changed.
+	requireNoError(t, b.Upsert(ctx, createProduct("001")))
+	assertVersion(t, b, "001", 2)
+	requireNoError(t, b.Update(ctx, createProduct("001")))
+	assertVersion(t, b, "001", 3)
+	requireNoError(t, b.UpdatePartial(ctx, "001",
+		map[string]interface{}{"ingredients": []interface{}{"milk"}}))
+	assertVersion(t, b, "001", 4)
+	assertVersion(t, b, "002", 1)
+
+	page, err := b.ReadMany(ctx, "", 10, nil, "")
+	requireNoError(t, err)
+	if assert.Len(t, page.Products, 2) {
+		assert.Equal(t, int64(4), page.Products[0].Version)
+		assert.Equal(t, int64(1), page.Products[1].Version)
+	}
+
+	// A product created again starts over.
+	requireNoError(t, b.Delete(ctx, "001"))
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	assertVersion(t, b, "001", 1)
+}
+
+func testUpdateIfMatch(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	product := createProduct("001")
+	product.Name = "updated"
+	requireNoError(t, b.UpdateIfMatch(ctx, product, 1))
+	assertVersion(t, b, "001", 2)
+
+	// The version read is stale.
+	stale := createProduct("001")
+	stale.Name = "stale"
+	assertCause(t, backend.ErrPreconditionFailed,
+		b.UpdateIfMatch(ctx, stale, 1))
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, product, result)
+	assert.Equal(t, int64(2), result.Version)
+
+	assertCause(t, backend.ErrNotFound,
+		b.UpdateIfMatch(ctx, createProduct("002"), 1))
+	_, err = b.Read(ctx, "002")
+	assertCause(t, backend.ErrNotFound, err)
+}
+
+func testUpdatePartialIfMatch(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	requireNoError(t, b.UpdatePartialIfMatch(ctx, "001",
+		map[string]interface{}{"name": "updated"}, 1))
+	assertCause(t, backend.ErrPreconditionFailed,
+		b.UpdatePartialIfMatch(ctx, "001",
+			map[string]interface{}{"name": "stale"}, 1))
+
+	expected := createProduct("001")
+	expected.Name = "updated"
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, expected, result)
+	assert.Equal(t, int64(2), result.Version)
+
+	assertCause(t, backend.ErrNotFound, b.UpdatePartialIfMatch(ctx, "002",
+		map[string]interface{}{"name": "updated"}, 1))
+}
+
+func testDeleteIfMatch(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	requireNoError(t, b.Update(ctx, createProduct("001")))
+
+	assertCause(t, backend.ErrPreconditionFailed,
+		b.DeleteIfMatch(ctx, "001", 1))
+	assertVersion(t, b, "001", 2)
+
+	requireNoError(t, b.DeleteIfMatch(ctx, "001", 2))
+	_, err := b.Read(ctx, "001")
+	assertCause(t, backend.ErrNotFound, err)
+	assertCause(t, backend.ErrNotFound, b.DeleteIfMatch(ctx, "001", 2))
+}
+
+func testIfMatchInvalid(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+
+	for _, version := range []int64{0, -1} {
+		assertCause(t, backend.ErrInvalidArgument,
+			b.UpdateIfMatch(ctx, createProduct("001"), version))
+		assertCause(t, backend.ErrInvalidArgument,
+			b.UpdatePartialIfMatch(ctx, "001",
+				map[string]interface{}{"name": "updated"}, version))
+		assertCause(t, backend.ErrInvalidArgument,
+			b.DeleteIfMatch(ctx, "001", version))
+	}
+	assertVersion(t, b, "001", 1)
+}
+
+// readTimestamps reads timestamps of the Product of productID and checks they
+// are within [from, backend.Now()].
+func readTimestamps(t *testing.T, b ProductBackend, productID string, from time.Time) (time.Time, time.Time) {
+	result, err := b.Read(ctx, productID)
+	requireNoError(t, err)
+	to := backend.Now()
+	for _, ts := range []time.Time{result.CreatedAt, result.UpdatedAt} {
+		if ts.Before(from) || ts.After(to) {
+			t.Errorf("%s not in [%s, %s]", ts, from, to)
+		}
+	}
+	return result.CreatedAt, result.UpdatedAt
+}
+
+func testTimestamps(t *testing.T, b ProductBackend) {
+	// Timestamps given are ignored.
+	product := createProduct("001")
+	product.CreatedAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	product.UpdatedAt = product.CreatedAt
+
+	from := backend.Now()
+	requireNoError(t, b.Create(ctx, product))
+	createdAt, updatedAt := readTimestamps(t, b, "001", from)
+	assert.True(t, createdAt.Equal(updatedAt))
+
+	from = backend.Now()
+	requireNoError(t, b.Upsert(ctx, createProduct("002")))
+	readTimestamps(t, b, "002", from)
+
+	// Every write changes updated_at only.
+	writes := []func() error{
+		func() error { return b.Upsert(ctx, product) },
+		func() error { return b.Update(ctx, product) },
+		func() error {
+			return b.UpdatePartial(ctx, "001",
+				map[string]interface{}{"name": "updated"})
+		},
+		func() error { return b.UpdateIfMatch(ctx, product, 4) },
+	}
+	for i, write := range writes {
+		// Make sure the time of the write is later.
+		time.Sleep(2 * time.Millisecond)
+		from = backend.Now()
+		requireNoError(t, write())
+		c, u := readTimestamps(t, b, "001", createdAt)
+		assert.True(t, c.Equal(createdAt), "write:%d", i)
+		assert.False(t, u.Before(from), "write:%d", i)
+		assert.True(t, u.After(updatedAt), "write:%d", i)
+		updatedAt = u
+	}
+}
+
+func testRevisions(t *testing.T, b ProductBackend) {
+	ctx := backend.WithAPIKey(ctx, "key1")
+	revisions, err := b.ReadRevisions(ctx, "001")
+	requireNoError(t, err)
+	assert.Empty(t, revisions.Revisions)
+
+	// Create keeps no revision since nothing is superseded.
+	v1 := createProduct("001")
+	requireNoError(t, b.Create(ctx, v1))
+	v2 := createProduct("001")
+	v2.Name = "v2"
+	requireNoError(t, b.Upsert(ctx, v2))
+	v3 := createProduct("001")
+	v3.Ingredients = []string{"v3"}
+	requireNoError(t, b.Update(backend.WithAPIKey(ctx, "key2"), v3))
+	requireNoError(t, b.UpdatePartial(ctx, "001",
+		map[string]interface{}{"story": "v4"}))
+	v4 := *v3
+	v4.Story = "v4"
+	from := backend.Now()
+	requireNoError(t, b.Delete(ctx, "001"))
+
+	revisions, err = b.ReadRevisions(ctx, "001")
+	requireNoError(t, err)
+	expected := []*model.Product{v1, v2, v3, &v4}
+	if !assert.Len(t, revisions.Revisions, len(expected)) {
+		return
+	}
+	for i, rev := range revisions.Revisions {
+		assert.Equal(t, int64(i+1), rev.Revision)
+		assert.Equal(t, int64(i+1), rev.Version)
+		assertProduct(t, expected[i], &rev.Product)
+		assert.Equal(t, i == len(expected)-1, rev.Deleted)
+		assert.False(t, rev.RevisedAt.IsZero())
+
+		result, err := b.ReadRevision(ctx, "001", rev.Revision)
+		requireNoError(t, err)
+		assert.Equal(t, rev, *result)
+	}
+	// The API key is of the write superseding the revision.
+	assert.Equal(t, "key1", revisions.Revisions[0].APIKey)
+	assert.Equal(t, "key2", revisions.Revisions[1].APIKey)
+	assert.Equal(t, "key1", revisions.Revisions[2].APIKey)
+	assert.False(t, revisions.Revisions[3].RevisedAt.Before(from))
+
+	_, err = b.ReadRevision(ctx, "001", 5)
+	assertCause(t, backend.ErrNotFound, err)
+	_, err = b.ReadRevision(ctx, "002", 1)
+	assertCause(t, backend.ErrNotFound, err)
+}
+
+func testRevisionsAfterRecreate(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	requireNoError(t, b.Delete(ctx, "001"))
+	// Writes failed keep no revision.
+	assertCause(t, backend.ErrNotFound, b.Delete(ctx, "001"))
+
+	product := createProduct("001")
+	product.Name = "recreated"
+	requireNoError(t, b.Upsert(ctx, product))
+	requireNoError(t, b.Upsert(ctx, createProduct("001")))
+	assertCause(t, backend.ErrPreconditionFailed,
+		b.UpdateIfMatch(ctx, product, 1))
+
+	// Revisions are numbered on, while versions start over.
+	revisions, err := b.ReadRevisions(ctx, "001")
+	requireNoError(t, err)
+	if assert.Len(t, revisions.Revisions, 2) {
+		assert.Equal(t, int64(2), revisions.Revisions[1].Revision)
+		assert.Equal(t, int64(1), revisions.Revisions[1].Version)
+		assert.False(t, revisions.Revisions[1].Deleted)
+		assertProduct(t, product, &revisions.Revisions[1].Product)
+		assert.Empty(t, revisions.Revisions[1].APIKey)
+	}
+}
+
+func testReadRevisionInvalid(t *testing.T, b ProductBackend) {
+	_, err := b.ReadRevisions(ctx, "")
+	assertCause(t, backend.ErrInvalidArgument, err)
+	_, err = b.ReadRevision(ctx, "", 1)
+	assertCause(t, backend.ErrInvalidArgument, err)
+	_, err = b.ReadRevision(ctx, "001", 0)
+	assertCause(t, backend.ErrInvalidArgument, err)
+}
+
+func readTrash(t *testing.T, b ProductBackend) []model.TrashedProduct {
+	trash, err := b.ReadTrash(ctx, "", 100)
+	requireNoError(t, err)
+	return trash.Products
+}
+
+func testTrash(t *testing.T, b ProductBackend) {
+	assert.Empty(t, readTrash(t, b))
+
+	product := createProduct("001")
+	requireNoError(t, b.Create(ctx, product))
+	requireNoError(t, b.UpdatePartial(ctx, "001",
+		map[string]interface{}{"name": "updated"}))
+	product.Name = "updated"
+	from := backend.Now()
+	requireNoError(t, b.Delete(ctx, "001"))
+
+	trash := readTrash(t, b)
+	if assert.Len(t, trash, 1) {
+		assertProduct(t, product, &trash[0].Product)
+		assert.Equal(t, int64(2), trash[0].Version)
+		assert.False(t, trash[0].DeletedAt.Before(from))
+	}
+	// Trashed products are not read or written.
+	_, err := b.Read(ctx, "001")
+	assertCause(t, backend.ErrNotFound, err)
+	page, err := b.ReadMany(ctx, "", 10, nil, "")
+	requireNoError(t, err)
+	assert.Empty(t, page.Products)
+	assertCause(t, backend.ErrNotFound, b.Update(ctx, product))
+	assertCause(t, backend.ErrNotFound, b.Delete(ctx, "001"))
+
+	// A trashed productId can be created and deleted again, which replaces
+	// the one trashed earlier.
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	requireNoError(t, b.Delete(ctx, "001"))
+	trash = readTrash(t, b)
+	if assert.Len(t, trash, 1) {
+		assertProduct(t, createProduct("001"), &trash[0].Product)
+		assert.Equal(t, int64(1), trash[0].Version)
+	}
+}
+
+func testReadTrashPages(t *testing.T, b ProductBackend) {
+	for _, productID := range []string{"003", "001", "002"} {
+		requireNoError(t, b.Create(ctx, createProduct(productID)))
+		requireNoError(t, b.Delete(ctx, productID))
+	}
+	trash, err := b.ReadTrash(ctx, "", 2)
+	requireNoError(t, err)
+	if assert.Len(t, trash.Products, 2) {
+		assert.Equal(t, "001", trash.Products[0].ProductID)
+		assert.Equal(t, "002", trash.Products[1].ProductID)
+	}
+	trash, err = b.ReadTrash(ctx, trash.Cursor, 2)
+	requireNoError(t, err)
+	if assert.Len(t, trash.Products, 1) {
+		assert.Equal(t, "003", trash.Products[0].ProductID)
+	}
+	assert.Empty(t, trash.Cursor)
+
+	// An exactly full page is the last
+	trash, err = b.ReadTrash(ctx, "", 3)
+	requireNoError(t, err)
+	assert.Len(t, trash.Products, 3)
+	assert.Empty(t, trash.Cursor)
+
+	_, err = b.ReadTrash(ctx, "", 0)
+	assertCause(t, backend.ErrInvalidArgument, err)
+}
+
+func testRestoreTrashed(t *testing.T, b ProductBackend) {
+	for _, productID := range []string{"001", "002", "003"} {
+		requireNoError(t, b.Create(ctx, createProduct(productID)))
+	}
+	before, err := b.Read(ctx, "002")
+	requireNoError(t, err)
+	requireNoError(t, b.Delete(ctx, "002"))
+
+	requireNoError(t, b.RestoreTrashed(ctx, "002"))
+	assert.Empty(t, readTrash(t, b))
+	after, err := b.Read(ctx, "002")
+	requireNoError(t, err)
+	assertProduct(t, before, after)
+	// It's a new version created earlier.
+	assert.Equal(t, int64(2), after.Version)
+	assert.True(t, after.CreatedAt.Equal(before.CreatedAt))
+	assert.False(t, after.UpdatedAt.Before(before.UpdatedAt))
+
+	// It's where it was in pages.
+	products, err := b.ReadMany(ctx, "", 10, nil, "")
+	requireNoError(t, err)
+	if assert.Len(t, products.Products, 3) {
+		assert.Equal(t, "002", products.Products[1].ProductID)
+	}
+
+	assertCause(t, backend.ErrNotFound, b.RestoreTrashed(ctx, "002"))
+	assertCause(t, backend.ErrInvalidArgument, b.RestoreTrashed(ctx, ""))
+}
+
+func testRestoreTrashedExisted(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	requireNoError(t, b.Delete(ctx, "001"))
+	product := createProduct("001")
+	product.Name = "recreated"
+	requireNoError(t, b.Create(ctx, product))
+
+	assertCause(t, backend.ErrAlreadyExists, b.RestoreTrashed(ctx, "001"))
+	// Neither is changed.
+	assert.Len(t, readTrash(t, b), 1)
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assertProduct(t, product, result)
+}
+
+func testPurgeTrashed(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	requireNoError(t, b.Delete(ctx, "001"))
+
+	requireNoError(t, b.PurgeTrashed(ctx, "001"))
+	assert.Empty(t, readTrash(t, b))
+	assertCause(t, backend.ErrNotFound, b.PurgeTrashed(ctx, "001"))
+	assertCause(t, backend.ErrNotFound, b.RestoreTrashed(ctx, "001"))
+	assertCause(t, backend.ErrInvalidArgument, b.PurgeTrashed(ctx, ""))
+}
+
+func testPurgeTrashedBefore(t *testing.T, b ProductBackend) {
+	requireNoError(t, b.Create(ctx, createProduct("001")))
+	requireNoError(t, b.Create(ctx, createProduct("002")))
+	requireNoError(t, b.Delete(ctx, "001"))
+	// Make sure the products are deleted at different times.
+	time.Sleep(2 * time.Millisecond)
+	from := backend.Now()
+	requireNoError(t, b.Delete(ctx, "002"))
+
+	n, err := b.PurgeTrashedBefore(ctx, from)
+	requireNoError(t, err)
+	assert.Equal(t, 1, n)
+	trash := readTrash(t, b)
+	if assert.Len(t, trash, 1) {
+		assert.Equal(t, "002", trash[0].ProductID)
+	}
+}
+
+// createFilteredProducts creates products for filters to select from.
+func createFilteredProducts(t *testing.T, b ProductBackend) {
+	products := []struct {
+		productID, name, certification string
+		ingredients, sourcingValues    []string
+	}{
+		{"001", "Chocolate Fudge", "Kosher",
+			[]string{"cream", "cocoa"}, []string{"Fairtrade"}},
+		{"002", "Chocolate Chip", "",
+			[]string{"cream", "cocoa", "eggs"}, []string{"Non-GMO"}},
+		{"003", "Cherry Garcia", "Kosher",
+			[]string{"cream", "cherries"}, []string{"Fairtrade", "Non-GMO"}},
+		{"004", "chocolate therapy", "Halal",
+			[]string{"cocoa", "soy"}, nil},
+	}
+	for _, p := range products {
+		product := createProduct(p.productID)
+		product.Name = p.name
+		product.DietaryCertifications = p.certification
+		product.Ingredients = p.ingredients
+		product.SourcingValues = p.sourcingValues
+		requireNoError(t, b.Create(ctx, product))
+	}
+}
+
+func testReadManyFilter(t *testing.T, b ProductBackend) {
+	createFilteredProducts(t, b)
+	cases := []struct {
+		filter   model.Filter
+		expected []string
+	}{
+		{model.Filter{}, []string{"001", "002", "003", "004"}},
+		{model.Filter{Ingredients: []string{"cocoa"}},
+			[]string{"001", "002", "004"}},
+		{model.Filter{Ingredients: []string{"cocoa", "cream"}},
+			[]string{"001", "002"}},
+		{model.Filter{WithoutIngredients: []string{"eggs", "soy"}},
+			[]string{"001", "003"}},
+		{model.Filter{Ingredients: []string{"cocoa"},
+			WithoutIngredients: []string{"eggs"}}, []string{"001", "004"}},
+		{model.Filter{SourcingValues: []string{"Fairtrade"}},
+			[]string{"001", "003"}},
+		{model.Filter{WithoutSourcingValues: []string{"Fairtrade"}},
+			[]string{"002", "004"}},
+		{model.Filter{DietaryCertifications: []string{"Kosher", "Halal"}},
+			[]string{"001", "003", "004"}},
+		{model.Filter{WithoutDietaryCertifications: []string{"Kosher"}},
+			[]string{"002", "004"}},
+		// Case-sensitive
+		{model.Filter{NamePrefix: "Chocolate"}, []string{"001", "002"}},
+		{model.Filter{NamePrefix: "C"}, []string{"001", "002", "003"}},
+		{model.Filter{NamePrefix: "Cherry Garcia"}, []string{"003"}},
+		{model.Filter{NamePrefix: "Ch", SourcingValues: []string{"Non-GMO"},
+			DietaryCertifications: []string{"Kosher"}}, []string{"003"}},
+	}
+	for _, c := range cases {
+		filter := c.filter
+		page, err := b.ReadMany(ctx, "", 10, &filter, "")
+		requireNoError(t, err)
+		var productIDs []string
+		for _, p := range page.Products {
+			productIDs = append(productIDs, p.ProductID)
+		}
+		assert.Equal(t, c.expected, productIDs, "%+v", c.filter)
+	}
+
+	page, err := b.ReadMany(ctx, "", 10,
+		&model.Filter{Ingredients: []string{"nuts"}}, "")
+	requireNoError(t, err)
+	assert.Empty(t, page.Products)
+}
+
+func testReadManyFilterPages(t *testing.T, b ProductBackend) {
+	createFilteredProducts(t, b)
+	filter := &model.Filter{Ingredients: []string{"cream"}}
+	page, err := b.ReadMany(ctx, "", 2, filter, "")
+	requireNoError(t, err)
+	if assert.Len(t, page.Products, 2) {
+		assert.Equal(t, "001", page.Products[0].ProductID)
+		assert.Equal(t, "002", page.Products[1].ProductID)
+	}
+	page, err = b.ReadMany(ctx, page.Cursor, 2, filter, "")
+	requireNoError(t, err)
+	if assert.Len(t, page.Products, 1) {
+		assert.Equal(t, "003", page.Products[0].ProductID)
+	}
+	assert.Empty(t, page.Cursor)
+}
+
+func testStructuredIngredients(t *testing.T, b ProductBackend) {
+	product := createProduct("001")
+	product.Ingredients = []string{"cream", "liquid sugar (sugar, water)"}
+	requireNoError(t, b.Create(ctx, product))
+	expected := []model.Ingredient{{Name: "cream"}, {Name: "liquid sugar",
+		Ingredients: []model.Ingredient{{Name: "sugar"}, {Name: "water"}}}}
+
+	result, err := b.Read(ctx, "001")
+	requireNoError(t, err)
+	assert.Equal(t, expected, result.StructuredIngredients)
+	page, err := b.ReadMany(ctx, "", 10, nil, "")
+	requireNoError(t, err)
+	if assert.Len(t, page.Products, 1) {
+		assert.Equal(t, expected, page.Products[0].StructuredIngredients)
+	}
+
+	// Derived from what's written rather than what's given
+	requireNoError(t, b.UpdatePartial(ctx, "001", map[string]interface{}{
+		"ingredients": []string{"butter (cream", "salt)"},
+	}))
+	result, err = b.Read(ctx, "001")
+	requireNoError(t, err)
+	assert.Equal(t, []model.Ingredient{{Name: "butter", Ingredients: []model.Ingredient{
+		{Name: "cream"}, {Name: "salt"}}}}, result.StructuredIngredients)
+
+	revisions, err := b.ReadRevisions(ctx, "001")
+	requireNoError(t, err)
+	if assert.Len(t, revisions.Revisions, 1) {
+		assert.Equal(t, expected,
+			revisions.Revisions[0].Product.StructuredIngredients)
+	}
+	requireNoError(t, b.Delete(ctx, "001"))
+	if trash := readTrash(t, b); assert.Len(t, trash, 1) {
+		assert.Len(t, trash[0].Product.StructuredIngredients, 1)
+	}
+}
+
+func testAllergens(t *testing.T, b ProductBackend) {
+	allergens := []model.Allergens{
+		{Contains: []model.Allergen{model.Milk, model.Eggs},
+			MayContain: []model.Allergen{model.Peanuts}},
+		{MayContain: []model.Allergen{model.TreeNuts, model.Wheat}},
+		{Contains: []model.Allergen{model.Milk}},
+		{},
+	}
+	for i, a := range allergens {
+		product := createProduct(fmt.Sprintf("%03d", i+1))
+		product.Allergens = a
+		requireNoError(t, b.Create(ctx, product))
+		result, err := b.Read(ctx, product.ProductID)
+		requireNoError(t, err)
+		assertProduct(t, product, result)
+	}
+
+	cases := []struct {
+		freeFrom []model.Allergen
+		expected []string
+	}{
+		{[]model.Allergen{model.Peanuts}, []string{"002", "003", "004"}},
+		{[]model.Allergen{model.Milk}, []string{"002", "004"}},
+		{[]model.Allergen{model.Wheat, model.Eggs}, []string{"003", "004"}},
+		{[]model.Allergen{model.Sesame}, []string{"001", "002", "003", "004"}},
+	}
+	for _, c := range cases {
+		page, err := b.ReadMany(ctx, "", 10,
+			&model.Filter{FreeFrom: c.freeFrom}, "")
+		requireNoError(t, err)
+		var productIDs []string
+		for _, p := range page.Products {
+			productIDs = append(productIDs, p.ProductID)
+		}
+		assert.Equal(t, c.expected, productIDs, "%v", c.freeFrom)
+	}
+
+	requireNoError(t, b.UpdatePartial(ctx, "003", map[string]interface{}{
+		"allergens": map[string]interface{}{
+			"contains":    []string{"soy"},
+			"may_contain": []string{"sesame"},
+		},
+	}))
+	result, err := b.Read(ctx, "003")
+	requireNoError(t, err)
+	assert.Equal(t, []model.Allergen{model.Soy}, result.Allergens.Contains)
+	assert.Equal(t, []model.Allergen{model.Sesame}, result.Allergens.MayContain)
+	page, err := b.ReadMany(ctx, "", 10,
+		&model.Filter{FreeFrom: []model.Allergen{model.Milk}}, "")
+	requireNoError(t, err)
+	assert.Len(t, page.Products, 3)
+}
+
+// counterOf skips the case if b is not a ProductCounter.
+func counterOf(t *testing.T, b ProductBackend) ProductCounter {
+	c, ok := b.(ProductCounter)
+	if !ok {
+		t.Skip("Count is not implemented")
+	}
+	return c
+}
+
+func testCount(t *testing.T, b ProductBackend) {
+	c := counterOf(t, b)
+	n, err := c.Count(ctx, nil)
+	requireNoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	createFilteredProducts(t, b)
+	requireNoError(t, b.Delete(ctx, "002"))
+	cases := []struct {
+		filter   *model.Filter
+		expected int64
+	}{
+		{nil, 3},
+		{&model.Filter{}, 3},
+		{&model.Filter{Ingredients: []string{"cocoa"}}, 2},
+		{&model.Filter{NamePrefix: "Ch", SourcingValues: []string{"Non-GMO"},
+			DietaryCertifications: []string{"Kosher"}}, 1},
+		{&model.Filter{Ingredients: []string{"nuts"}}, 0},
+	}
+	for _, cs := range cases {
+		n, err := c.Count(ctx, cs.filter)
+		requireNoError(t, err)
+		assert.Equal(t, cs.expected, n, "%+v", cs.filter)
+	}
+}
+
+// searcherOf skips the case if b is not a ProductSearcher.
+func searcherOf(t *testing.T, b ProductBackend) ProductSearcher {
+	s, ok := b.(ProductSearcher)
+	if !ok {
+		t.Skip("Search is not implemented")
+	}
+	return s
+}
+
+// createSearchedProducts creates products for searches to find.
+func createSearchedProducts(t *testing.T, b ProductBackend) {
+	products := []struct {
+		productID, name, description, story string
+		ingredients                         []string
+	}{
+		{"001", "Cookie Dough", "Vanilla with gobs of cookie dough", "",
+			[]string{"cream", "cookie dough"}},
+		{"002", "Cheesecake Brownie", "Cheesecake with brownie swirls", "",
+			[]string{"cream cheese", "brownies"}},
+		{"003", "Salted Caramel", "Caramel swirls", "A cookie swirl story",
+			[]string{"cream", "caramel"}},
+		{"004", "Vanilla", "Plain vanilla", "", []string{"cream", "vanilla"}},
+	}
+	for _, p := range products {
+		product := createProduct(p.productID)
+		product.Name = p.name
+		product.Description = p.description
+		product.Story = p.story
+		product.Ingredients = p.ingredients
+		requireNoError(t, b.Create(ctx, product))
+	}
+}
+
+func hitIDs(results *model.SearchResults) []string {
+	var productIDs []string
+	for _, hit := range results.Hits {
+		productIDs = append(productIDs, hit.Product.ProductID)
+	}
+	return productIDs
+}
+
+func testSearch(t *testing.T, b ProductBackend) {
+	s := searcherOf(t, b)
+	createSearchedProducts(t, b)
+
+	results, err := s.Search(ctx, "cheesecake", "", 10)
+	requireNoError(t, err)
+	assert.Equal(t, []string{"002"}, hitIDs(results))
+	assert.Empty(t, results.Cursor)
+	assert.Equal(t, []string{"cream cheese", "brownies"},
+		results.Hits[0].Product.Ingredients)
+	assert.Equal(t, "<em>Cheesecake</em> Brownie",
+		results.Hits[0].Highlights["name"])
+
+	// Matching any word, the most relevant first.
+	results, err = s.Search(ctx, "Cookie swirls", "", 10)
+	requireNoError(t, err)
+	productIDs := hitIDs(results)
+	if assert.Len(t, productIDs, 3) {
+		assert.Equal(t, "001", productIDs[0])
+		assert.ElementsMatch(t, []string{"001", "002", "003"}, productIDs)
+	}
+	for i := 1; i < len(results.Hits); i++ {
+		assert.True(t, results.Hits[i-1].Score >= results.Hits[i].Score)
+	}
+
+	results, err = s.Search(ctx, "fudge", "", 10)
+	requireNoError(t, err)
+	assert.Empty(t, results.Hits)
+	assert.Empty(t, results.Cursor)
+}
+
+func testSearchInvalid(t *testing.T, b ProductBackend) {
+	s := searcherOf(t, b)
+	createSearchedProducts(t, b)
+	_, err := s.Search(ctx, "cookie", "", 0)
+	assertCause(t, backend.ErrInvalidArgument, err)
+	_, err = s.Search(ctx, "", "", 10)
+	assertCause(t, backend.ErrInvalidArgument, err)
+	// Only stop words and punctuation
+	_, err = s.Search(ctx, "the, and", "", 10)
+	assertCause(t, backend.ErrInvalidArgument, err)
+}
+
+func testSearchAfterWrites(t *testing.T, b ProductBackend) {
+	s := searcherOf(t, b)
+	createSearchedProducts(t, b)
+	requireNoError(t, b.Delete(ctx, "001"))
+	requireNoError(t, b.UpdatePartial(ctx, "004",
+		map[string]interface{}{"name": "Cookie Vanilla"}))
+	product := createProduct("005")
+	product.Name = "Cookie Fudge"
+	requireNoError(t, b.Upsert(ctx, product))
+
+	results, err := s.Search(ctx, "cookie", "", 10)
+	requireNoError(t, err)
+	assert.ElementsMatch(t, []string{"003", "004", "005"}, hitIDs(results))
+
+	requireNoError(t, b.RestoreTrashed(ctx, "001"))
+	results, err = s.Search(ctx, "dough", "", 10)
+	requireNoError(t, err)
+	assert.Equal(t, []string{"001"}, hitIDs(results))
+}
+
+func testSearchPages(t *testing.T, b ProductBackend) {
+	s := searcherOf(t, b)
+	createSearchedProducts(t, b)
+	results, err := s.Search(ctx, "cream", "", 3)
+	requireNoError(t, err)
+	productIDs := hitIDs(results)
+	assert.Len(t, productIDs, 3)
+	if assert.NotEmpty(t, results.Cursor) {
+		results, err = s.Search(ctx, "cream", results.Cursor, 3)
+		requireNoError(t, err)
+		assert.Len(t, results.Hits, 1)
+		assert.Empty(t, results.Cursor)
+		productIDs = append(productIDs, hitIDs(results)...)
+	}
+	assert.ElementsMatch(t, []string{"001", "002", "003", "004"}, productIDs)
+}
@@ -0,0 +1,6 @@
+/*
+Package cache is a read-through cache in front of any backend of Product.
+Results of Read and pages of ReadMany are kept in an LRU with a TTL. Every
+write through the cache invalidates what it might change.
+*/
+package cache
@@ -0,0 +1,377 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/inconshreveable/log15"
+	pe "github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+var log = log15.New("module", "backend.cache")
+
+// ProductBackend has the same method set as handler.ProductBackend.
+type ProductBackend interface {
+	Create(ctx context.Context, product *model.Product) error
+	Read(ctx context.Context, productID string) (*model.Product, error)
+	ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error)
+	Update(ctx context.Context, product *model.Product) error
+	UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error
+	Upsert(ctx context.Context, product *model.Product) error
+	Delete(ctx context.Context, productID string) error
+	UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error
+	UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error
+	DeleteIfMatch(ctx context.Context, productID string, version int64) error
+	ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error)
+	ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error)
+	ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error)
+	RestoreTrashed(ctx context.Context, productID string) error
+	PurgeTrashed(ctx context.Context, productID string) error
+	PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+// ProductSearcher is implemented by backends capable of Search.
+type ProductSearcher interface {
+	Search(ctx context.Context, query string, cursor string, limit int) (*model.SearchResults, error)
+}
+
+// ProductCounter is implemented by backends capable of Count.
+type ProductCounter interface {
+	Count(ctx context.Context, filter *model.Filter) (int64, error)
+}
+
+// Stats are counters of a CachedProductBackend.
+type Stats struct {
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+type entry struct {
+	key     string
+	expires time.Time
+	// either *model.Product or *model.Products
+	value interface{}
+}
+
+func productKey(productID string) string {
+	return "product:" + productID
+}
+
+func pageKey(cursor string, limit int, filter *model.Filter, order model.Sort) string {
+	// cursor is quoted as it may have any character.
+	if filter.IsEmpty() {
+		return fmt.Sprintf("page:%d:%s:%q", limit, order, cursor)
+	}
+	bs, _ := json.Marshal(filter)
+	return fmt.Sprintf("page:%d:%s:%q:%s", limit, order, cursor, bs)
+}
+
+func copyProduct(product *model.Product) *model.Product {
+	cp := *product
+	if product.SourcingValues != nil {
+		cp.SourcingValues = append([]string{}, product.SourcingValues...)
+	}
+	if product.Ingredients != nil {
+		cp.Ingredients = append([]string{}, product.Ingredients...)
+	}
+	if product.Allergens.Contains != nil {
+		cp.Allergens.Contains = append([]model.Allergen{},
+			product.Allergens.Contains...)
+	}
+	if product.Allergens.MayContain != nil {
+		cp.Allergens.MayContain = append([]model.Allergen{},
+			product.Allergens.MayContain...)
+	}
+	cp.StructuredIngredients = copyIngredients(product.StructuredIngredients)
+	return &cp
+}
+
+// copyIngredients deep-copies ingredients and their sub-ingredients.
+func copyIngredients(ingredients []model.Ingredient) []model.Ingredient {
+	if ingredients == nil {
+		return nil
+	}
+	cp := make([]model.Ingredient, len(ingredients))
+	for i, ingredient := range ingredients {
+		cp[i] = model.Ingredient{
+			Name:        ingredient.Name,
+			Ingredients: copyIngredients(ingredient.Ingredients),
+		}
+	}
+	return cp
+}
+
+func copyProducts(products *model.Products) *model.Products {
+	cp := &model.Products{
+		Cursor:   products.Cursor,
+		Products: make([]model.Product, 0, len(products.Products)),
+	}
+	for i := range products.Products {
+		cp.Products = append(cp.Products, *copyProduct(&products.Products[i]))
+	}
+	return cp
+}
+
+// CachedProductBackend implements ProductBackend by caching another one.
+type CachedProductBackend struct {
+	backend  ProductBackend
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu sync.Mutex
+	// lru is ordered from the most recently used to the least.
+	lru     *list.List
+	entries map[string]*list.Element
+	// pages are keys of entries of ReadMany.
+	pages map[string]struct{}
+	// generation increases on every write so that a result read from the
+	// backend before the write is not cached after it.
+	generation uint64
+	stats      Stats
+}
+
+// get returns the value of key if it's cached and not expired.
+func (h *CachedProductBackend) get(key string) (interface{}, uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if elem, ok := h.entries[key]; ok {
+		e := elem.Value.(*entry)
+		if h.now().Before(e.expires) {
+			h.lru.MoveToFront(elem)
+			h.stats.Hits++
+			return e.value, h.generation, true
+		}
+		h.remove(elem)
+	}
+	h.stats.Misses++
+	return nil, h.generation, false
+}
+
+// put caches value unless a write happened since generation.
+func (h *CachedProductBackend) put(key string, value interface{}, generation uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if generation != h.generation {
+		return
+	}
+	if elem, ok := h.entries[key]; ok {
+		h.remove(elem)
+	}
+	h.entries[key] = h.lru.PushFront(&entry{
+		key:     key,
+		expires: h.now().Add(h.ttl),
+		value:   value,
+	})
+	if _, ok := value.(*model.Products); ok {
+		h.pages[key] = struct{}{}
+	}
+	for h.lru.Len() > h.capacity {
+		h.remove(h.lru.Back())
+		h.stats.Evictions++
+	}
+}
+
+func (h *CachedProductBackend) remove(elem *list.Element) {
+	e := h.lru.Remove(elem).(*entry)
+	delete(h.entries, e.key)
+	delete(h.pages, e.key)
+}
+
+// invalidate removes the entry of productID and every page since any of them
+// may contain the product.
+func (h *CachedProductBackend) invalidate(productID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.generation++
+	if elem, ok := h.entries[productKey(productID)]; ok {
+		h.remove(elem)
+	}
+	for key := range h.pages {
+		h.remove(h.entries[key])
+	}
+}
+
+// Stats returns counters of the cache.
+func (h *CachedProductBackend) Stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := h.stats
+	stats.Size = h.lru.Len()
+	stats.Capacity = h.capacity
+	return stats
+}
+
+// Create exclusively creates product. Success only if no Product with the
+// same ProductId existed.
+func (h *CachedProductBackend) Create(ctx context.Context, product *model.Product) error {
+	defer h.invalidate(product.ProductID)
+	return h.backend.Create(ctx, product)
+}
+
+// Read finds the Product with the given productID. Return error if not found.
+func (h *CachedProductBackend) Read(ctx context.Context, productID string) (*model.Product, error) {
+	key := productKey(productID)
+	value, generation, ok := h.get(key)
+	if ok {
+		log.Debug("Read hit", "productId", productID)
+		return copyProduct(value.(*model.Product)), nil
+	}
+	product, err := h.backend.Read(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	h.put(key, copyProduct(product), generation)
+	return product, nil
+}
+
+// ReadMany reads a page of products. Cursor is from last ReadMany and
+// represents the end of the previous page. Limit is the number of Products that
+// will be returned in a page. If cursor is empty then ReadMany begins from the
+// first page. Limit must be larger than 0. Filter, if not nil, selects
+// products read. Order sorts them, it must be the same for every page. The page
+// is empty if no more products are read, and only the last page has no cursor.
+func (h *CachedProductBackend) ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error) {
+	key := pageKey(cursor, limit, filter, order)
+	value, generation, ok := h.get(key)
+	if ok {
+		log.Debug("ReadMany hit", "from", cursor, "limit", limit)
+		return copyProducts(value.(*model.Products)), nil
+	}
+	products, err := h.backend.ReadMany(ctx, cursor, limit, filter, order)
+	if err != nil {
+		return nil, err
+	}
+	h.put(key, copyProducts(products), generation)
+	return products, nil
+}
+
+// Update updates product. Success only if a Product with the same
+// ProductId existed. Return error if not existed.
+func (h *CachedProductBackend) Update(ctx context.Context, product *model.Product) error {
+	defer h.invalidate(product.ProductID)
+	return h.backend.Update(ctx, product)
+}
+
+// UpdatePartial updates product. Success only if a Product with the same
+// ProductId existed. Return error if not existed.
+func (h *CachedProductBackend) UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error {
+	defer h.invalidate(productID)
+	return h.backend.UpdatePartial(ctx, productID, kvs)
+}
+
+// Upsert inserts product. If a Product with the same productID existed already,
+// then a replacement is performed.
+func (h *CachedProductBackend) Upsert(ctx context.Context, product *model.Product) error {
+	defer h.invalidate(product.ProductID)
+	return h.backend.Upsert(ctx, product)
+}
+
+// Delete the Product with productID
+func (h *CachedProductBackend) Delete(ctx context.Context, productID string) error {
+	defer h.invalidate(productID)
+	return h.backend.Delete(ctx, productID)
+}
+
+// UpdateIfMatch updates product like Update but only if the version of the
+// stored Product is version.
+func (h *CachedProductBackend) UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error {
+	defer h.invalidate(product.ProductID)
+	return h.backend.UpdateIfMatch(ctx, product, version)
+}
+
+// UpdatePartialIfMatch updates product like UpdatePartial but only if the
+// version of the stored Product is version.
+func (h *CachedProductBackend) UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
+	defer h.invalidate(productID)
+	return h.backend.UpdatePartialIfMatch(ctx, productID, kvs, version)
+}
+
+// DeleteIfMatch deletes the Product with productID only if its version is
+// version.
+func (h *CachedProductBackend) DeleteIfMatch(ctx context.Context, productID string, version int64) error {
+	defer h.invalidate(productID)
+	return h.backend.DeleteIfMatch(ctx, productID, version)
+}
+
+// ReadRevisions reads revisions of the Product with productID. Revisions are
+// not cached.
+func (h *CachedProductBackend) ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error) {
+	return h.backend.ReadRevisions(ctx, productID)
+}
+
+// ReadRevision reads the revision of the Product with productID. Revisions
+// are not cached.
+func (h *CachedProductBackend) ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error) {
+	return h.backend.ReadRevision(ctx, productID, revision)
+}
+
+// ReadTrash reads a page of trashed products ordered by productId. The trash is
+// not cached.
+func (h *CachedProductBackend) ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error) {
+	return h.backend.ReadTrash(ctx, cursor, limit)
+}
+
+// Search searches Products if the backend is capable. Results are not cached.
+// Return backend.ErrNotImplemented otherwise.
+func (h *CachedProductBackend) Search(ctx context.Context, query string, cursor string, limit int) (*model.SearchResults, error) {
+	s, ok := h.backend.(ProductSearcher)
+	if !ok {
+		return nil, pe.WithStack(backend.ErrNotImplemented)
+	}
+	return s.Search(ctx, query, cursor, limit)
+}
+
+// Count counts Products matching filter if the backend is capable. Counts are
+// not cached. Return backend.ErrNotImplemented otherwise.
+func (h *CachedProductBackend) Count(ctx context.Context, filter *model.Filter) (int64, error) {
+	c, ok := h.backend.(ProductCounter)
+	if !ok {
+		return 0, pe.WithStack(backend.ErrNotImplemented)
+	}
+	return c.Count(ctx, filter)
+}
+
+// RestoreTrashed moves the trashed Product with productID back.
+func (h *CachedProductBackend) RestoreTrashed(ctx context.Context, productID string) error {
+	defer h.invalidate(productID)
+	return h.backend.RestoreTrashed(ctx, productID)
+}
+
+// PurgeTrashed permanently deletes the trashed Product with productID.
+func (h *CachedProductBackend) PurgeTrashed(ctx context.Context, productID string) error {
+	return h.backend.PurgeTrashed(ctx, productID)
+}
+
+// PurgeTrashedBefore permanently deletes Products trashed before t.
+func (h *CachedProductBackend) PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error) {
+	return h.backend.PurgeTrashedBefore(ctx, t)
+}
+
+// CreateCachedProductBackend creates CachedProductBackend in front of backend.
+// Capacity is the max number of entries, each of which is either a Product or
+// a page of Products. An entry expires after ttl.
+func CreateCachedProductBackend(backend ProductBackend, capacity int,
+	ttl time.Duration) (*CachedProductBackend, error) {
+	if capacity <= 0 || ttl <= 0 {
+		return nil, pe.New(fmt.Sprintf("invalid capacity:%d or ttl:%s",
+			capacity, ttl))
+	}
+	return &CachedProductBackend{
+		backend:  backend,
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		pages:    make(map[string]struct{}),
+	}, nil
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend/backendtest"
+	"github.com/cfchou/icecream/pkg/backend/memory"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var ctx = context.Background()
+
+func createBackends(t *testing.T, capacity int) (*memory.MemoryProductBackend,
+	*CachedProductBackend) {
+	mb, _ := memory.CreateMemoryProductBackend()
+	for _, id := range []string{"001", "002", "003"} {
+		mb.Create(ctx, &model.Product{ProductID: id, Name: id})
+	}
+	cb, err := CreateCachedProductBackend(mb, capacity, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mb, cb
+}
+
+func TestCachedProductBackend_Read(t *testing.T) {
+	mb, cb := createBackends(t, 10)
+
+	product, err := cb.Read(ctx, "001")
+	assert.NoError(t, err)
+	assert.Equal(t, "001", product.Name)
+
+	// Changed behind the cache, so the cached one is read.
+	mb.UpdatePartial(ctx, "001", map[string]interface{}{"name": "changed"})
+	product, _ = cb.Read(ctx, "001")
+	assert.Equal(t, "001", product.Name)
+
+	// Modifying what's returned doesn't affect the cache.
+	product.Name = "modified"
+	product, _ = cb.Read(ctx, "001")
+	assert.Equal(t, "001", product.Name)
+
+	// Errors are not cached
+	_, err = cb.Read(ctx, "004")
+	assert.Error(t, err)
+	_, err = cb.Read(ctx, "004")
+	assert.Error(t, err)
+
+	assert.Equal(t, Stats{Size: 1, Capacity: 10, Hits: 2, Misses: 3},
+		cb.Stats())
+}
+
+func TestCachedProductBackend_ReadCopies(t *testing.T) {
+	mb, cb := createBackends(t, 10)
+	mb.Create(ctx, &model.Product{ProductID: "004",
+		Ingredients: []string{"cream", "cookie (flour, sugar)"},
+		Allergens: model.Allergens{
+			Contains:   []model.Allergen{model.Milk, model.Wheat},
+			MayContain: []model.Allergen{model.Peanuts},
+		}})
+
+	// Modifying nested fields of what's returned doesn't affect the cache.
+	product, err := cb.Read(ctx, "004")
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected, _ := mb.Read(ctx, "004")
+	product.Ingredients[0] = "modified"
+	product.StructuredIngredients[1].Ingredients[0].Name = "modified"
+	product.Allergens.Contains[0] = model.Soy
+	product.Allergens.MayContain[0] = model.Soy
+	product, _ = cb.Read(ctx, "004")
+	assert.Equal(t, expected, product)
+
+	page, _ := cb.ReadMany(ctx, "003", 1, nil, "")
+	page.Products[0].StructuredIngredients[1].Name = "modified"
+	page.Products[0].Allergens.Contains[1] = model.Soy
+	page, _ = cb.ReadMany(ctx, "003", 1, nil, "")
+	assert.Equal(t, *expected, page.Products[0])
+}
+
+func TestCachedProductBackend_Invalidate(t *testing.T) {
+	_, cb := createBackends(t, 10)
+
+	cb.Read(ctx, "001")
+	cb.Read(ctx, "002")
+	page, _ := cb.ReadMany(ctx, "", 2, nil, "")
+	assert.Equal(t, "001", page.Products[0].Name)
+
+	assert.NoError(t, cb.UpdatePartial(ctx, "001",
+		map[string]interface{}{"name": "changed"}))
+	assert.Equal(t, 1, cb.Stats().Size)
+
+	product, _ := cb.Read(ctx, "001")
+	assert.Equal(t, "changed", product.Name)
+	page, _ = cb.ReadMany(ctx, "", 2, nil, "")
+	assert.Equal(t, "changed", page.Products[0].Name)
+
+	assert.NoError(t, cb.Delete(ctx, "001"))
+	_, err := cb.Read(ctx, "001")
+	assert.Error(t, err)
+	page, _ = cb.ReadMany(ctx, "", 2, nil, "")
+	assert.Equal(t, "002", page.Products[0].Name)
+
+	// Failed writes invalidate as well
+	assert.Error(t, cb.Create(ctx, &model.Product{ProductID: "002"}))
+	assert.Equal(t, 0, cb.Stats().Size)
+}
+
+func TestCachedProductBackend_TTL(t *testing.T) {
+	mb, cb := createBackends(t, 10)
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+
+	cb.Read(ctx, "001")
+	mb.UpdatePartial(ctx, "001", map[string]interface{}{"name": "changed"})
+
+	now = now.Add(time.Minute - time.Second)
+	product, _ := cb.Read(ctx, "001")
+	assert.Equal(t, "001", product.Name)
+
+	now = now.Add(time.Second)
+	product, _ = cb.Read(ctx, "001")
+	assert.Equal(t, "changed", product.Name)
+}
+
+func TestCachedProductBackend_LRU(t *testing.T) {
+	_, cb := createBackends(t, 2)
+
+	cb.Read(ctx, "001")
+	cb.Read(ctx, "002")
+	// 001 becomes the most recently used
+	cb.Read(ctx, "001")
+	// 002 is evicted
+	cb.Read(ctx, "003")
+	assert.Equal(t, Stats{Size: 2, Capacity: 2, Hits: 1, Misses: 3,
+		Evictions: 1}, cb.Stats())
+
+	cb.Read(ctx, "001")
+	cb.Read(ctx, "002")
+	assert.Equal(t, Stats{Size: 2, Capacity: 2, Hits: 2, Misses: 4,
+		Evictions: 2}, cb.Stats())
+}
+
+func TestCachedProductBackend_Conformance(t *testing.T) {
+	backendtest.TestProductBackend(t, func(t *testing.T) (backendtest.ProductBackend, func()) {
+		mb, _ := memory.CreateMemoryProductBackend()
+		cb, _ := CreateCachedProductBackend(mb, 3, time.Minute)
+		return cb, func() {}
+	})
+}
+
+func TestCreateCachedProductBackend_Invalid(t *testing.T) {
+	mb, _ := memory.CreateMemoryProductBackend()
+	_, err := CreateCachedProductBackend(mb, 0, time.Minute)
+	assert.Error(t, err)
+	_, err = CreateCachedProductBackend(mb, 1, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,21 @@
+package backend
+
+import (
+	"context"
+)
+
+type contextKey int
+
+const apiKeyContextKey contextKey = 0
+
+// WithAPIKey returns a copy of ctx carrying apiKey, which a request is
+// authenticated by. Backends record it as who made a write.
+func WithAPIKey(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, apiKey)
+}
+
+// APIKeyFrom returns the API key ctx carries, or "" if there is none.
+func APIKeyFrom(ctx context.Context) string {
+	apiKey, _ := ctx.Value(apiKeyContextKey).(string)
+	return apiKey
+}
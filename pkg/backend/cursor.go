@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A cursor of a page sorted by a field joins the value of the field of the
+// last Product, i.e. the sort key, and where it's stored in a backend, e.g.
+// its ObjectId, which breaks ties of the key.
+
+// JoinCursor joins key and id to a cursor.
+func JoinCursor(key, id string) string {
+	return key + "\x00" + id
+}
+
+// SplitCursor splits a cursor joined by JoinCursor. ok is false if it's not.
+func SplitCursor(cursor string) (key, id string, ok bool) {
+	i := strings.LastIndex(cursor, "\x00")
+	if i < 0 {
+		return "", "", false
+	}
+	return cursor[:i], cursor[i+1:], true
+}
+
+// SortKey is the value of field of product in a cursor. Timestamps are in
+// milliseconds since epoch, 0 if unknown.
+func SortKey(product *model.Product, field string) string {
+	switch field {
+	case "name":
+		return product.Name
+	case "productId":
+		return product.ProductID
+	case "updated_at":
+		if product.UpdatedAt.IsZero() {
+			return "0"
+		}
+		return strconv.FormatInt(
+			product.UpdatedAt.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return ""
+}
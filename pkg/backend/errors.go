@@ -0,0 +1,37 @@
+/*
+Package backend defines errors shared by backends in its subpackages. Backends
+return them, usually wrapped by github.com/pkg/errors, as the cause so that
+callers can tell what went wrong without knowing which backend is used.
+
+Besides them, a backend may return context.Canceled or
+context.DeadlineExceeded as the cause when the context of a call is done.
+*/
+package backend
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNotFound when data is not found in the backend.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists when creating data existed in the backend.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalidArgument when inputs are invalid.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrConflict when a write conflicts with a concurrent one. Retrying may
+	// succeed.
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed when a conditional write finds the version of
+	// data is not the expected one, i.e. it's modified by someone else.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnavailable when the backend can't be reached for the moment.
+	// Retrying may succeed.
+	ErrUnavailable = errors.New("unavailable")
+	// ErrInconsistent when data violates some constraints, e.g. duplicated
+	// ProductID.
+	ErrInconsistent = errors.New("inconsistent")
+	// ErrNotImplemented when the backend doesn't support an operation, e.g.
+	// searching.
+	ErrNotImplemented = errors.New("not implemented")
+)
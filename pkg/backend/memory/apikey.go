@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	pe "github.com/pkg/errors"
+	"io"
+	"sync"
+)
+
+// MemoryAPIKeyBackend stores APIKeys in memory.
+type MemoryAPIKeyBackend struct {
+	mu   sync.RWMutex
+	keys map[string]struct{}
+}
+
+// Authenticate checks if apiKey is stored.
+func (h *MemoryAPIKeyBackend) Authenticate(ctx context.Context, apiKey string) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, ok := h.keys[apiKey]; !ok {
+		return pe.WithStack(backend.ErrNotFound)
+	}
+	log.Debug("Find apikey")
+	return nil
+}
+
+// Add stores apiKey. Adding an existing apiKey is a no-op.
+func (h *MemoryAPIKeyBackend) Add(apiKey string) error {
+	if apiKey == "" {
+		log.Error("Invalid apikey", "err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.keys[apiKey] = struct{}{}
+	return nil
+}
+
+// Load reads APIKeys from r, which is a stream of JSON objects in the format
+// of apikey.json, and adds them.
+func (h *MemoryAPIKeyBackend) Load(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var key model.APIKey
+		if err := dec.Decode(&key); err == io.EOF {
+			return nil
+		} else if err != nil {
+			log.Error("Decode failed", "err", err)
+			return pe.WithStack(err)
+		}
+		if err := h.Add(key.APIKey); err != nil {
+			return err
+		}
+	}
+}
+
+// CreateMemoryAPIKeyBackend creates an empty MemoryAPIKeyBackend
+func CreateMemoryAPIKeyBackend() (*MemoryAPIKeyBackend, error) {
+	return &MemoryAPIKeyBackend{
+		keys: make(map[string]struct{}),
+	}, nil
+}
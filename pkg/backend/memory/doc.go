@@ -0,0 +1,7 @@
+/*
+Package memory is backend implementing various operations against Product and
+APIKey in the process memory. It is meant for local runs and tests where a
+database is not available. Products are searched by an inverted index of
+package search.
+*/
+package memory
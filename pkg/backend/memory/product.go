@@ -0,0 +1,763 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/cfchou/icecream/pkg/backend/search"
+	"github.com/inconshreveable/log15"
+	pe "github.com/pkg/errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = log15.New("module", "backend.memory")
+
+// mProduct is a stored Product. Seq is the order of insertion and acts like
+// the ObjectId in mongoDB, i.e. it's kept when the product is replaced.
+type mProduct struct {
+	seq     uint64
+	product model.Product
+}
+
+// copyProduct makes a deep copy so that callers can't modify what's stored.
+// StructuredIngredients is derived from Ingredients rather than copied.
+func copyProduct(product *model.Product) *model.Product {
+	cp := *product
+	if product.SourcingValues != nil {
+		cp.SourcingValues = append([]string{}, product.SourcingValues...)
+	}
+	if product.Ingredients != nil {
+		cp.Ingredients = append([]string{}, product.Ingredients...)
+	}
+	if product.Allergens.Contains != nil {
+		cp.Allergens.Contains = append([]model.Allergen{},
+			product.Allergens.Contains...)
+	}
+	if product.Allergens.MayContain != nil {
+		cp.Allergens.MayContain = append([]model.Allergen{},
+			product.Allergens.MayContain...)
+	}
+	cp.StructuredIngredients = model.ParseIngredients(cp.Ingredients)
+	return &cp
+}
+
+// trashed is a deleted product. Seq is kept so that a restored product is
+// where it was in pages.
+type trashed struct {
+	seq     uint64
+	product model.TrashedProduct
+}
+
+func formatCursor(seq uint64) string {
+	return fmt.Sprintf("%016x", seq)
+}
+
+// MemoryProductBackend stores Products in memory to support CRUD for Product.
+type MemoryProductBackend struct {
+	mu  sync.RWMutex
+	seq uint64
+	// products are sorted by seq.
+	products []*mProduct
+	index    map[string]*mProduct
+	// revisions are keyed by productId and sorted by Revision. They're kept
+	// after the product is deleted.
+	revisions map[string][]model.Revision
+	// trash is keyed by productId.
+	trash map[string]*trashed
+	// terms index products for Search.
+	terms *search.Index
+}
+
+func (h *MemoryProductBackend) insert(product *model.Product) {
+	h.seq++
+	mp := &mProduct{
+		seq:     h.seq,
+		product: *copyProduct(product),
+	}
+	mp.product.Version = 1
+	mp.product.CreatedAt = backend.Now()
+	mp.product.UpdatedAt = mp.product.CreatedAt
+	h.products = append(h.products, mp)
+	h.index[product.ProductID] = mp
+	h.terms.Add(&mp.product)
+}
+
+// replace replaces the stored product of mp with product, bumps the version
+// and updates the timestamp.
+func (mp *mProduct) replace(product *model.Product) {
+	version, createdAt := mp.product.Version, mp.product.CreatedAt
+	mp.product = *copyProduct(product)
+	mp.product.Version = version + 1
+	mp.product.CreatedAt = createdAt
+	mp.product.UpdatedAt = backend.Now()
+}
+
+// keep keeps the stored product of mp as a revision before it's superseded by
+// a write of ctx. Callers must hold the lock.
+func (h *MemoryProductBackend) keep(ctx context.Context, mp *mProduct, deleted bool) {
+	productID := mp.product.ProductID
+	revisions := h.revisions[productID]
+	h.revisions[productID] = append(revisions, model.Revision{
+		Revision:  int64(len(revisions)) + 1,
+		Version:   mp.product.Version,
+		Product:   *copyProduct(&mp.product),
+		Deleted:   deleted,
+		APIKey:    backend.APIKeyFrom(ctx),
+		RevisedAt: backend.Now(),
+	})
+}
+
+// find returns the stored product of productID. If version is not 0, it must
+// match the version of the stored product. Callers must hold the lock.
+func (h *MemoryProductBackend) find(productID string, version int64) (*mProduct, error) {
+	mp, ok := h.index[productID]
+	if !ok {
+		return nil, pe.WithStack(backend.ErrNotFound)
+	}
+	if version != 0 && mp.product.Version != version {
+		return nil, pe.Wrapf(backend.ErrPreconditionFailed,
+			"version %d, expected %d", mp.product.Version, version)
+	}
+	return mp, nil
+}
+
+// checkVersion checks version given to conditional writes.
+func checkVersion(productID string, version int64) error {
+	if version <= 0 {
+		log.Error(fmt.Sprintf("Invalid version:%d", version),
+			"productId", productID, "err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return nil
+}
+
+// Create exclusively creates product. Success only if no Product with the
+// same ProductId existed.
+func (h *MemoryProductBackend) Create(ctx context.Context, product *model.Product) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	if product.ProductID == "" {
+		log.Error("Invalid productId", "productId", product.ProductID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.index[product.ProductID]; ok {
+		log.Error("Create existed failed ", "productId", product.ProductID,
+			"err", backend.ErrAlreadyExists)
+		return pe.WithStack(backend.ErrAlreadyExists)
+	}
+	h.insert(product)
+	log.Debug(fmt.Sprintf("Create seq=%d", h.seq),
+		"productId", product.ProductID)
+	return nil
+}
+
+// Upsert inserts product. If a Product with the same productID existed already,
+// then a replacement is performed.
+func (h *MemoryProductBackend) Upsert(ctx context.Context, product *model.Product) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	if product.ProductID == "" {
+		log.Error("Invalid productId", "productId", product.ProductID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if mp, ok := h.index[product.ProductID]; ok {
+		h.keep(ctx, mp, false)
+		mp.replace(product)
+		h.terms.Add(&mp.product)
+		log.Debug("Upsert update succeeded", "productId", product.ProductID)
+		return nil
+	}
+	h.insert(product)
+	log.Debug(fmt.Sprintf("Upsert insert seq=%d", h.seq),
+		"productId", product.ProductID)
+	return nil
+}
+
+// Update updates product. Success only if a Product with the same
+// ProductId existed. Return error if not existed.
+func (h *MemoryProductBackend) Update(ctx context.Context, product *model.Product) error {
+	return h.update(ctx, product, 0)
+}
+
+// UpdateIfMatch updates product like Update but only if the version of the
+// stored Product is version.
+func (h *MemoryProductBackend) UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error {
+	if err := checkVersion(product.ProductID, version); err != nil {
+		return err
+	}
+	return h.update(ctx, product, version)
+}
+
+func (h *MemoryProductBackend) update(ctx context.Context, product *model.Product, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	if product.ProductID == "" {
+		log.Error("Invalid productId", "productId", product.ProductID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	mp, err := h.find(product.ProductID, version)
+	if err != nil {
+		log.Error("Update failed ", "productId", product.ProductID,
+			"err", err)
+		return err
+	}
+	h.keep(ctx, mp, false)
+	mp.replace(product)
+	h.terms.Add(&mp.product)
+	log.Debug("Update succeeded", "productId", product.ProductID)
+	return nil
+}
+
+// UpdatePartial updates product. Success only if a Product with the same
+// ProductId existed. Return error if not existed.
+func (h *MemoryProductBackend) UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error {
+	return h.updatePartial(ctx, productID, kvs, 0)
+}
+
+// UpdatePartialIfMatch updates product like UpdatePartial but only if the
+// version of the stored Product is version.
+func (h *MemoryProductBackend) UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
+	if err := checkVersion(productID, version); err != nil {
+		return err
+	}
+	return h.updatePartial(ctx, productID, kvs, version)
+}
+
+func (h *MemoryProductBackend) updatePartial(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+
+	if pid, ok := kvs["productId"]; ok && pid != productID {
+		log.Error("Different productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+
+	// Check if kvs has only fields of Product, of their types
+	var patch model.Product
+	if err := patch.Patch(kvs); err != nil {
+		log.Error("Patch failed ", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	mp, err := h.find(productID, version)
+	if err != nil {
+		log.Error("Update failed ", "productId", productID, "err", err)
+		return err
+	}
+	// Safe to update. Only fields presented in kvs are overwritten, and null
+	// resets them.
+	product := copyProduct(&mp.product)
+	product.Patch(kvs)
+	h.keep(ctx, mp, false)
+	mp.replace(product)
+	h.terms.Add(&mp.product)
+	log.Debug("Update succeeded", "productId", productID)
+	return nil
+}
+
+// Read finds the Product with the given productID. Return error if not found.
+func (h *MemoryProductBackend) Read(ctx context.Context, productID string) (*model.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, pe.WithStack(err)
+	}
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	mp, ok := h.index[productID]
+	if !ok {
+		return nil, pe.WithStack(backend.ErrNotFound)
+	}
+	log.Debug(fmt.Sprintf("Find seq=%d", mp.seq), "productId", productID)
+	return copyProduct(&mp.product), nil
+}
+
+// ReadMany reads a page of products. Cursor is from last ReadMany and
+// represents the end of the previous page. Limit is the number of Products that
+// will be returned in a page. If cursor is empty then ReadMany begins from the
+// first page. Limit must be larger than 0. Filter, if not nil, selects
+// products read. Order sorts them, it must be the same for every page. The page
+// is empty if no more products are read, and only the last page has no cursor.
+func (h *MemoryProductBackend) ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, pe.WithStack(err)
+	}
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	if !order.IsValid() {
+		log.Error("Invalid sort", "sort", order,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	field := order.Field()
+	key, seq, err := parseCursor(cursor, field)
+	if err != nil {
+		log.Error("Invalid cursor", "cursor", cursor, "err", err)
+		return nil, err
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var mps []*mProduct
+	if field == "" {
+		i := sort.Search(len(h.products), func(i int) bool {
+			return h.products[i].seq > seq
+		})
+		// One more than limit tells if there's a next page.
+		for _, mp := range h.products[i:] {
+			if len(mps) > limit {
+				break
+			}
+			if filter.Match(&mp.product) {
+				mps = append(mps, mp)
+			}
+		}
+	} else {
+		for _, mp := range h.products {
+			if filter.Match(&mp.product) {
+				mps = append(mps, mp)
+			}
+		}
+		sign := 1
+		if order.IsDescending() {
+			sign = -1
+		}
+		sort.Slice(mps, func(i, j int) bool {
+			return sign*mps[i].compare(field, backend.SortKey(
+				&mps[j].product, field), mps[j].seq) < 0
+		})
+		if cursor != "" {
+			i := sort.Search(len(mps), func(i int) bool {
+				return sign*mps[i].compare(field, key, seq) > 0
+			})
+			mps = mps[i:]
+		}
+	}
+
+	ret := &model.Products{
+		Products: make([]model.Product, 0),
+	}
+	if len(mps) > limit {
+		mps = mps[:limit]
+		last := mps[limit-1]
+		ret.Cursor = formatCursor(last.seq)
+		if field != "" {
+			ret.Cursor = backend.JoinCursor(
+				backend.SortKey(&last.product, field), ret.Cursor)
+		}
+	}
+	for _, mp := range mps {
+		ret.Products = append(ret.Products, *copyProduct(&mp.product))
+	}
+	log.Debug("ReadMany succeeded", "count", len(mps), "from", cursor,
+		"to", ret.Cursor)
+	return ret, nil
+}
+
+// parseCursor parses a cursor of ReadMany sorted by field to the sort key and
+// seq of the last product of the previous page.
+func parseCursor(cursor string, field string) (string, uint64, error) {
+	if cursor == "" {
+		return "", 0, nil
+	}
+	key, id := "", cursor
+	if field != "" {
+		var ok bool
+		if key, id, ok = backend.SplitCursor(cursor); !ok {
+			return "", 0, pe.WithStack(backend.ErrInvalidArgument)
+		}
+		if field == "updated_at" {
+			if _, err := strconv.ParseInt(key, 10, 64); err != nil {
+				return "", 0, pe.WithStack(backend.ErrInvalidArgument)
+			}
+		}
+	}
+	seq, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return "", 0, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return key, seq, nil
+}
+
+// compare compares the sort key of field and seq of mp with key and seq.
+func (mp *mProduct) compare(field string, key string, seq uint64) int {
+	mpKey := backend.SortKey(&mp.product, field)
+	if field == "updated_at" {
+		// Keys in cursors are checked by parseCursor.
+		a, _ := strconv.ParseInt(mpKey, 10, 64)
+		b, _ := strconv.ParseInt(key, 10, 64)
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	} else if c := strings.Compare(mpKey, key); c != 0 {
+		return c
+	}
+	if mp.seq != seq {
+		if mp.seq < seq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// Count counts Products matching filter.
+func (h *MemoryProductBackend) Count(ctx context.Context, filter *model.Filter) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, pe.WithStack(err)
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var n int64
+	for _, mp := range h.products {
+		if filter.Match(&mp.product) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Search finds Products matching any word of query in name, description, story
+// or ingredients, ordered by relevance. Cursor is from last Search of the same
+// query. Limit must be larger than 0. Return backend.ErrInvalidArgument if
+// query has no words to search. The page is empty if no more Products match.
+func (h *MemoryProductBackend) Search(ctx context.Context, query string, cursor string, limit int) (*model.SearchResults, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, pe.WithStack(err)
+	}
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		log.Error("Invalid query", "query", query,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	// The cursor is the number of hits read. An unrecognized one reads from
+	// the first page.
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	hits := h.terms.Search(terms)
+	ret := &model.SearchResults{
+		Hits: make([]model.SearchHit, 0),
+	}
+	if offset >= len(hits) {
+		return ret, nil
+	}
+	hits = hits[offset:]
+	if len(hits) > limit {
+		hits = hits[:limit]
+		ret.Cursor = strconv.Itoa(offset + limit)
+	}
+	for _, hit := range hits {
+		product := copyProduct(&h.index[hit.ProductID].product)
+		ret.Hits = append(ret.Hits, model.SearchHit{
+			Product:    *product,
+			Score:      hit.Score,
+			Highlights: search.Highlight(product, terms),
+		})
+	}
+	log.Debug("Search succeeded", "query", query, "count", len(hits),
+		"from", cursor, "to", ret.Cursor)
+	return ret, nil
+}
+
+// Delete moves the Product with productID to the trash, where it replaces one
+// trashed earlier with the same productID.
+func (h *MemoryProductBackend) Delete(ctx context.Context, productID string) error {
+	return h.delete(ctx, productID, 0)
+}
+
+// DeleteIfMatch deletes the Product with productID only if its version is
+// version.
+func (h *MemoryProductBackend) DeleteIfMatch(ctx context.Context, productID string, version int64) error {
+	if err := checkVersion(productID, version); err != nil {
+		return err
+	}
+	return h.delete(ctx, productID, version)
+}
+
+func (h *MemoryProductBackend) delete(ctx context.Context, productID string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	mp, err := h.find(productID, version)
+	if err != nil {
+		log.Error("Remove failed", "productId", productID, "err", err)
+		return err
+	}
+	h.keep(ctx, mp, true)
+	delete(h.index, productID)
+	h.terms.Remove(productID)
+	i := sort.Search(len(h.products), func(i int) bool {
+		return h.products[i].seq >= mp.seq
+	})
+	h.products = append(h.products[:i], h.products[i+1:]...)
+	h.trash[productID] = &trashed{
+		seq: mp.seq,
+		product: model.TrashedProduct{
+			Product:   mp.product,
+			DeletedAt: backend.Now(),
+		},
+	}
+	log.Debug("Remove succeeded", "productId", productID)
+	return nil
+}
+
+// ReadRevisions reads revisions of the Product with productID, which are empty
+// if the Product has never been superseded.
+func (h *MemoryProductBackend) ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, pe.WithStack(err)
+	}
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ret := &model.Revisions{
+		Revisions: make([]model.Revision, 0),
+	}
+	for _, r := range h.revisions[productID] {
+		r.Product = *copyProduct(&r.Product)
+		ret.Revisions = append(ret.Revisions, r)
+	}
+	log.Debug("ReadRevisions succeeded", "productId", productID,
+		"count", len(ret.Revisions))
+	return ret, nil
+}
+
+// ReadRevision reads the revision of the Product with productID. Return error
+// if not found.
+func (h *MemoryProductBackend) ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, pe.WithStack(err)
+	}
+	if productID == "" || revision <= 0 {
+		log.Error(fmt.Sprintf("Invalid revision:%d", revision),
+			"productId", productID, "err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	revisions := h.revisions[productID]
+	if revision > int64(len(revisions)) {
+		return nil, pe.WithStack(backend.ErrNotFound)
+	}
+	r := revisions[revision-1]
+	r.Product = *copyProduct(&r.Product)
+	return &r, nil
+}
+
+// ReadTrash reads a page of trashed products ordered by productId. Cursor is
+// from last ReadTrash. Limit must be larger than 0. The cursor of the last
+// page is empty.
+func (h *MemoryProductBackend) ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, pe.WithStack(err)
+	}
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	productIDs := make([]string, 0, len(h.trash))
+	for productID := range h.trash {
+		if productID > cursor {
+			productIDs = append(productIDs, productID)
+		}
+	}
+	sort.Strings(productIDs)
+	ret := &model.Trash{
+		Products: make([]model.TrashedProduct, 0),
+	}
+	if len(productIDs) > limit {
+		productIDs = productIDs[:limit]
+		ret.Cursor = productIDs[limit-1]
+	}
+	for _, productID := range productIDs {
+		tp := h.trash[productID].product
+		tp.Product = *copyProduct(&tp.Product)
+		ret.Products = append(ret.Products, tp)
+	}
+	log.Debug("ReadTrash succeeded", "count", len(productIDs),
+		"from", cursor, "to", ret.Cursor)
+	return ret, nil
+}
+
+// RestoreTrashed moves the trashed Product with productID back, as a new
+// version. Return error if it's not trashed or a Product with the same
+// productID has been created since.
+func (h *MemoryProductBackend) RestoreTrashed(ctx context.Context, productID string) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.trash[productID]
+	if !ok {
+		return pe.WithStack(backend.ErrNotFound)
+	}
+	if _, ok := h.index[productID]; ok {
+		log.Error("Restore existed failed", "productId", productID,
+			"err", backend.ErrAlreadyExists)
+		return pe.WithStack(backend.ErrAlreadyExists)
+	}
+	mp := &mProduct{
+		seq:     t.seq,
+		product: t.product.Product,
+	}
+	mp.product.Version++
+	mp.product.UpdatedAt = backend.Now()
+	i := sort.Search(len(h.products), func(i int) bool {
+		return h.products[i].seq >= mp.seq
+	})
+	h.products = append(h.products, nil)
+	copy(h.products[i+1:], h.products[i:])
+	h.products[i] = mp
+	h.index[productID] = mp
+	h.terms.Add(&mp.product)
+	delete(h.trash, productID)
+	log.Debug("Restore succeeded", "productId", productID)
+	return nil
+}
+
+// PurgeTrashed permanently deletes the trashed Product with productID. Return
+// error if it's not trashed.
+func (h *MemoryProductBackend) PurgeTrashed(ctx context.Context, productID string) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.trash[productID]; !ok {
+		return pe.WithStack(backend.ErrNotFound)
+	}
+	delete(h.trash, productID)
+	log.Debug("Purge succeeded", "productId", productID)
+	return nil
+}
+
+// PurgeTrashedBefore permanently deletes Products trashed before t and returns
+// the number of them.
+func (h *MemoryProductBackend) PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, pe.WithStack(err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for productID, tp := range h.trash {
+		if tp.product.DeletedAt.Before(t) {
+			delete(h.trash, productID)
+			n++
+		}
+	}
+	log.Debug("Purge succeeded", "before", t, "count", n)
+	return n, nil
+}
+
+// Load reads Products from r, which is a stream of JSON objects in the format
+// of icecream.json, and creates them. Ingredients are repaired and allergens
+// are parsed from allergy_info unless given. It fails with
+// backend.ErrInconsistent if a productId is duplicated.
+func (h *MemoryProductBackend) Load(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var product model.Product
+		if err := dec.Decode(&product); err == io.EOF {
+			return nil
+		} else if err != nil {
+			log.Error("Decode failed", "err", err)
+			return pe.WithStack(err)
+		}
+		product.Ingredients = model.NormalizeIngredients(product.Ingredients)
+		if product.Allergens.IsEmpty() {
+			product.Allergens = model.ParseAllergyInfo(product.AllergyInfo)
+		}
+		if err := h.Create(context.Background(), &product); err != nil {
+			if pe.Cause(err) == backend.ErrAlreadyExists {
+				log.Error("Load duplicated product", "productId",
+					product.ProductID, "err", backend.ErrInconsistent)
+				return pe.WithStack(backend.ErrInconsistent)
+			}
+			return err
+		}
+	}
+}
+
+// CreateMemoryProductBackend creates an empty MemoryProductBackend
+func CreateMemoryProductBackend() (*MemoryProductBackend, error) {
+	return &MemoryProductBackend{
+		products:  make([]*mProduct, 0),
+		index:     make(map[string]*mProduct),
+		revisions: make(map[string][]model.Revision),
+		trash:     make(map[string]*trashed),
+		terms:     search.CreateIndex(),
+	}, nil
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/backendtest"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var ctx = context.Background()
+
+const products = `
+{"productId": "001", "name": "One", "ingredients": ["cream"]}
+{"productId": "002", "name": "Two"}
+{"productId": "003", "name": "Three"}
+`
+
+func TestMemoryProductBackend_Load(t *testing.T) {
+	b, _ := CreateMemoryProductBackend()
+	assert.NoError(t, b.Load(strings.NewReader(products)))
+
+	product, err := b.Read(ctx, "001")
+	assert.NoError(t, err)
+	assert.Equal(t, "One", product.Name)
+	assert.Equal(t, []string{"cream"}, product.Ingredients)
+
+	err = b.Load(strings.NewReader(`{"productId": "001"}`))
+	assert.Equal(t, backend.ErrInconsistent, pe.Cause(err))
+}
+
+func TestMemoryProductBackend_Create(t *testing.T) {
+	b, _ := CreateMemoryProductBackend()
+	product := &model.Product{ProductID: "001"}
+	assert.NoError(t, b.Create(ctx, product))
+	assert.Equal(t, backend.ErrAlreadyExists, pe.Cause(b.Create(ctx, product)))
+	assert.Equal(t, backend.ErrInvalidArgument,
+		pe.Cause(b.Create(ctx, &model.Product{})))
+}
+
+func TestMemoryProductBackend_ReadMany(t *testing.T) {
+	b, _ := CreateMemoryProductBackend()
+	assert.NoError(t, b.Load(strings.NewReader(products)))
+
+	page, err := b.ReadMany(ctx, "", 2, nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Products, 2)
+	assert.Equal(t, "001", page.Products[0].ProductID)
+
+	// Replacement keeps the position
+	assert.NoError(t, b.Upsert(ctx, &model.Product{ProductID: "001", Name: "1"}))
+
+	page, err = b.ReadMany(ctx, page.Cursor, 2, nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Products, 1)
+	assert.Equal(t, "003", page.Products[0].ProductID)
+	assert.Empty(t, page.Cursor)
+}
+
+func TestMemoryProductBackend_UpdatePartial(t *testing.T) {
+	b, _ := CreateMemoryProductBackend()
+	assert.NoError(t, b.Load(strings.NewReader(products)))
+
+	assert.NoError(t, b.UpdatePartial(ctx, "001", map[string]interface{}{
+		"story": "once upon a time",
+	}))
+	product, _ := b.Read(ctx, "001")
+	assert.Equal(t, "One", product.Name)
+	assert.Equal(t, "once upon a time", product.Story)
+
+	err := b.UpdatePartial(ctx, "001", map[string]interface{}{"extra": 1})
+	assert.Equal(t, backend.ErrInvalidArgument, pe.Cause(err))
+	err = b.UpdatePartial(ctx, "001", map[string]interface{}{"productId": "002"})
+	assert.Equal(t, backend.ErrInvalidArgument, pe.Cause(err))
+	err = b.UpdatePartial(ctx, "004", map[string]interface{}{"name": "Four"})
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(err))
+}
+
+func TestMemoryProductBackend_Delete(t *testing.T) {
+	b, _ := CreateMemoryProductBackend()
+	assert.NoError(t, b.Load(strings.NewReader(products)))
+
+	assert.NoError(t, b.Delete(ctx, "002"))
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(b.Delete(ctx, "002")))
+	_, err := b.Read(ctx, "002")
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(err))
+
+	page, _ := b.ReadMany(ctx, "", 10, nil, "")
+	assert.Len(t, page.Products, 2)
+}
+
+func TestMemoryAPIKeyBackend_Authenticate(t *testing.T) {
+	b, _ := CreateMemoryAPIKeyBackend()
+	assert.NoError(t, b.Load(strings.NewReader(`{"apikey": "testkey"}`)))
+	assert.NoError(t, b.Authenticate(ctx, "testkey"))
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(b.Authenticate(ctx, "nokey")))
+}
+
+func TestMemoryProductBackend_Conformance(t *testing.T) {
+	backendtest.TestProductBackend(t, func(t *testing.T) (backendtest.ProductBackend, func()) {
+		b, _ := CreateMemoryProductBackend()
+		return b, func() {}
+	})
+}
+
+func TestMemoryAPIKeyBackend_Conformance(t *testing.T) {
+	backendtest.TestAPIKeyBackend(t, func(t *testing.T, apiKeys []string) (backendtest.APIKeyBackend, func()) {
+		b, _ := CreateMemoryAPIKeyBackend()
+		for _, k := range apiKeys {
+			b.Add(k)
+		}
+		return b, func() {}
+	})
+}
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Allergen is one of MajorAllergens.
+type Allergen string
+
+// Major allergens required to be declared on labels.
+const (
+	Milk      Allergen = "milk"
+	Eggs      Allergen = "eggs"
+	Fish      Allergen = "fish"
+	Shellfish Allergen = "shellfish"
+	TreeNuts  Allergen = "tree_nuts"
+	Peanuts   Allergen = "peanuts"
+	Wheat     Allergen = "wheat"
+	Soy       Allergen = "soy"
+	Sesame    Allergen = "sesame"
+)
+
+// MajorAllergens are every Allergen in the order they're listed.
+var MajorAllergens = []Allergen{Milk, Eggs, Fish, Shellfish, TreeNuts, Peanuts,
+	Wheat, Soy, Sesame}
+
+// allergenWords are prefixes of words naming an Allergen in allergy_info.
+var allergenWords = map[Allergen][]string{
+	Milk:      {"milk", "dairy"},
+	Eggs:      {"egg"},
+	Fish:      {"fish"},
+	Shellfish: {"shellfish", "crustacean", "shrimp", "crab", "lobster"},
+	TreeNuts: {"tree nut", "almond", "cashew", "pecan", "walnut", "hazelnut",
+		"pistachio", "macadamia"},
+	Peanuts: {"peanut"},
+	Wheat:   {"wheat"},
+	Soy:     {"soy"},
+	Sesame:  {"sesame"},
+}
+
+// IsValid is true if a is one of MajorAllergens.
+func (a Allergen) IsValid() bool {
+	_, ok := allergenWords[a]
+	return ok
+}
+
+// Allergens are allergens a Product contains, and those it may contain as
+// traces, e.g. because it's made on equipment that also processes them.
+type Allergens struct {
+	Contains   []Allergen `json:"contains"`
+	MayContain []Allergen `json:"may_contain"`
+}
+
+// IsValid is true if every Allergen is valid and appears only once.
+func (a *Allergens) IsValid() bool {
+	seen := make(map[Allergen]bool)
+	for _, list := range [][]Allergen{a.Contains, a.MayContain} {
+		for _, allergen := range list {
+			if !allergen.IsValid() || seen[allergen] {
+				return false
+			}
+			seen[allergen] = true
+		}
+	}
+	return true
+}
+
+// IsEmpty is true if a has no Allergen.
+func (a *Allergens) IsEmpty() bool {
+	return len(a.Contains) == 0 && len(a.MayContain) == 0
+}
+
+// Has is true if a contains or may contain allergen.
+func (a *Allergens) Has(allergen Allergen) bool {
+	for _, list := range [][]Allergen{a.Contains, a.MayContain} {
+		for _, v := range list {
+			if v == allergen {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sort orders both lists like MajorAllergens.
+func (a *Allergens) Sort() {
+	a.Contains = sortAllergens(a.Contains)
+	a.MayContain = sortAllergens(a.MayContain)
+}
+
+func sortAllergens(allergens []Allergen) []Allergen {
+	ret := make([]Allergen, 0, len(allergens))
+	for _, major := range MajorAllergens {
+		for _, allergen := range allergens {
+			if allergen == major {
+				ret = append(ret, major)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+var (
+	mayContainRE = regexp.MustCompile(`\bmay (also )?contain`)
+	nonLetterRE  = regexp.MustCompile(`[^a-z]+`)
+)
+
+// ParseAllergyInfo finds allergens in free text like "contains milk, eggs,
+// wheat and soy" or "may contain wheat, peanuts and other tree nuts".
+// Allergens after "may contain" in a sentence are traces. Explanations
+// following "because" are ignored.
+func ParseAllergyInfo(text string) Allergens {
+	contains := make(map[Allergen]bool)
+	mayContain := make(map[Allergen]bool)
+	for _, sentence := range strings.FieldsFunc(strings.ToLower(text),
+		func(r rune) bool { return r == '.' || r == ';' }) {
+		if i := strings.Index(sentence, "because"); i >= 0 {
+			sentence = sentence[:i]
+		}
+		if loc := mayContainRE.FindStringIndex(sentence); loc != nil {
+			findAllergens(sentence[:loc[0]], contains)
+			findAllergens(sentence[loc[1]:], mayContain)
+		} else {
+			findAllergens(sentence, contains)
+		}
+	}
+	ret := Allergens{Contains: []Allergen{}, MayContain: []Allergen{}}
+	for _, allergen := range MajorAllergens {
+		if contains[allergen] {
+			ret.Contains = append(ret.Contains, allergen)
+		} else if mayContain[allergen] {
+			ret.MayContain = append(ret.MayContain, allergen)
+		}
+	}
+	return ret
+}
+
+// findAllergens adds allergens named in text to found.
+func findAllergens(text string, found map[Allergen]bool) {
+	text = " " + strings.TrimSpace(nonLetterRE.ReplaceAllString(text, " "))
+	for allergen, words := range allergenWords {
+		for _, word := range words {
+			if strings.Contains(text, " "+word) {
+				found[allergen] = true
+				break
+			}
+		}
+	}
+}
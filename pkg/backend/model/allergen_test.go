@@ -0,0 +1,54 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAllergyInfo(t *testing.T) {
+	cases := []struct {
+		text                 string
+		contains, mayContain []Allergen
+	}{
+		{"", []Allergen{}, []Allergen{}},
+		{"contains milk, eggs, wheat and soy",
+			[]Allergen{Milk, Eggs, Wheat, Soy}, []Allergen{}},
+		{"may contain wheat, peanuts and other tree nuts",
+			[]Allergen{}, []Allergen{TreeNuts, Peanuts, Wheat}},
+		{"May contain other tree nuts", []Allergen{}, []Allergen{TreeNuts}},
+		// Explanations are ignored
+		{"may contain wheat and tree nuts because the peanut butter cups are " +
+			"made on equipment that also processes wheat and tree nuts",
+			[]Allergen{}, []Allergen{TreeNuts, Wheat}},
+		{"Contains milk and soybeans. May also contain almonds, sesame; " +
+			"and milk", []Allergen{Milk, Soy}, []Allergen{TreeNuts, Sesame}},
+		{"contains walnuts, may contain peanuts",
+			[]Allergen{TreeNuts}, []Allergen{Peanuts}},
+		{"produced in a nut-free facility", []Allergen{}, []Allergen{}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, Allergens{Contains: c.contains,
+			MayContain: c.mayContain}, ParseAllergyInfo(c.text), c.text)
+	}
+}
+
+func TestAllergens_IsValid(t *testing.T) {
+	valid := Allergens{Contains: []Allergen{Milk}, MayContain: []Allergen{Soy}}
+	assert.True(t, valid.IsValid())
+	assert.True(t, (&Allergens{}).IsValid())
+	for _, a := range []Allergens{
+		{Contains: []Allergen{"nuts"}},
+		{Contains: []Allergen{Milk, Milk}},
+		{Contains: []Allergen{Milk}, MayContain: []Allergen{Milk}},
+	} {
+		assert.False(t, a.IsValid(), "%+v", a)
+	}
+}
+
+func TestFilter_MatchFreeFrom(t *testing.T) {
+	product := &Product{Allergens: Allergens{Contains: []Allergen{Milk},
+		MayContain: []Allergen{Peanuts}}}
+	assert.True(t, (&Filter{FreeFrom: []Allergen{Wheat}}).Match(product))
+	assert.False(t, (&Filter{FreeFrom: []Allergen{Wheat, Milk}}).Match(product))
+	assert.False(t, (&Filter{FreeFrom: []Allergen{Peanuts}}).Match(product))
+}
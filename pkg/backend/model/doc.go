@@ -1,4 +1,5 @@
 /*
-Package model defines two data models Product and APIKey.
+Package model defines two data models Product and APIKey, Filter which selects
+Products, and SearchResults of searching Products.
 */
 package model
@@ -0,0 +1,80 @@
+package model
+
+// Filter selects Products read by ReadMany. Values are matched exactly and
+// case-sensitively. Empty fields select every Product.
+type Filter struct {
+	// Ingredients must all be among ingredients of a Product, while none of
+	// WithoutIngredients may be.
+	Ingredients        []string `json:"ingredients,omitempty"`
+	WithoutIngredients []string `json:"without_ingredients,omitempty"`
+	// SourcingValues must all be among sourcing_values of a Product, while
+	// none of WithoutSourcingValues may be.
+	SourcingValues        []string `json:"sourcing_values,omitempty"`
+	WithoutSourcingValues []string `json:"without_sourcing_values,omitempty"`
+	// DietaryCertifications has dietary_certifications of a Product if it's
+	// not empty, while WithoutDietaryCertifications must not.
+	DietaryCertifications        []string `json:"dietary_certifications,omitempty"`
+	WithoutDietaryCertifications []string `json:"without_dietary_certifications,omitempty"`
+	// NamePrefix is a prefix of name of a Product.
+	NamePrefix string `json:"name_prefix,omitempty"`
+	// FreeFrom are allergens a Product neither contains nor may contain.
+	FreeFrom []Allergen `json:"free_from,omitempty"`
+}
+
+// IsEmpty is true if f is nil or selects every Product.
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.Ingredients) == 0 &&
+		len(f.WithoutIngredients) == 0 && len(f.SourcingValues) == 0 &&
+		len(f.WithoutSourcingValues) == 0 &&
+		len(f.DietaryCertifications) == 0 &&
+		len(f.WithoutDietaryCertifications) == 0 && f.NamePrefix == "" &&
+		len(f.FreeFrom) == 0)
+}
+
+// Match is true if f selects product. A nil f selects every Product.
+func (f *Filter) Match(product *Product) bool {
+	if f == nil {
+		return true
+	}
+	if !containsAll(product.Ingredients, f.Ingredients) ||
+		containsAny(product.Ingredients, f.WithoutIngredients) ||
+		!containsAll(product.SourcingValues, f.SourcingValues) ||
+		containsAny(product.SourcingValues, f.WithoutSourcingValues) {
+		return false
+	}
+	certification := []string{product.DietaryCertifications}
+	if len(f.DietaryCertifications) != 0 &&
+		!containsAny(f.DietaryCertifications, certification) {
+		return false
+	}
+	if containsAny(f.WithoutDietaryCertifications, certification) {
+		return false
+	}
+	for _, allergen := range f.FreeFrom {
+		if product.Allergens.Has(allergen) {
+			return false
+		}
+	}
+	return len(product.Name) >= len(f.NamePrefix) &&
+		product.Name[:len(f.NamePrefix)] == f.NamePrefix
+}
+
+func containsAll(values []string, expected []string) bool {
+	for _, e := range expected {
+		if !containsAny(values, []string{e}) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(values []string, expected []string) bool {
+	for _, v := range values {
+		for _, e := range expected {
+			if v == e {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"strings"
+)
+
+// Ingredient is an ingredient of a Product, which may be made of
+// sub-ingredients, e.g. "liquid sugar (sugar, water)".
+type Ingredient struct {
+	Name        string       `json:"name"`
+	Ingredients []Ingredient `json:"ingredients,omitempty"`
+}
+
+// ingredientDelimiters can't be in names as they delimit ingredients.
+const ingredientDelimiters = ",()[]"
+
+// IsValid is true if names of i and its sub-ingredients are neither empty
+// nor have delimiters, so that i is formatted and parsed back as it is.
+func (i *Ingredient) IsValid() bool {
+	if strings.TrimSpace(i.Name) != i.Name || i.Name == "" ||
+		strings.ContainsAny(i.Name, ingredientDelimiters) {
+		return false
+	}
+	for j := range i.Ingredients {
+		if !i.Ingredients[j].IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats i like "liquid sugar (sugar, water)".
+func (i *Ingredient) String() string {
+	if len(i.Ingredients) == 0 {
+		return i.Name
+	}
+	return i.Name + " (" + strings.Join(FormatIngredients(i.Ingredients),
+		", ") + ")"
+}
+
+// FormatIngredients formats every Ingredient like Ingredient.String.
+func FormatIngredients(ingredients []Ingredient) []string {
+	ret := make([]string, 0, len(ingredients))
+	for i := range ingredients {
+		ret = append(ret, ingredients[i].String())
+	}
+	return ret
+}
+
+// ParseIngredients rebuilds ingredients, which may have been split on every
+// comma like ["liquid sugar (sugar", "water)"], into a tree. Sub-ingredients
+// are enclosed by parentheses or brackets. It never fails: unmatched closing
+// ones are dropped, unclosed ones are closed at the end.
+func ParseIngredients(ingredients []string) []Ingredient {
+	p := ingredientParser{s: strings.Join(ingredients, ", ")}
+	return p.list(0)
+}
+
+// NormalizeIngredients repairs ingredients by parsing and formatting them.
+func NormalizeIngredients(ingredients []string) []string {
+	return FormatIngredients(ParseIngredients(ingredients))
+}
+
+type ingredientParser struct {
+	s string
+	i int
+}
+
+// list parses ingredients until closer or the end. A closer of 0 means the
+// top level.
+func (p *ingredientParser) list(closer byte) []Ingredient {
+	ret := make([]Ingredient, 0)
+	var name []string
+	var subs []Ingredient
+	flush := func() {
+		n := strings.Join(strings.Fields(strings.Join(name, " ")), " ")
+		if n != "" {
+			ret = append(ret, Ingredient{Name: n, Ingredients: subs})
+		} else {
+			// Sub-ingredients without a name belong to the list.
+			ret = append(ret, subs...)
+		}
+		name, subs = nil, nil
+	}
+	for p.i < len(p.s) {
+		j := p.i + strings.IndexAny(p.s[p.i:], ingredientDelimiters)
+		if j < p.i {
+			j = len(p.s)
+		}
+		name = append(name, p.s[p.i:j])
+		p.i = j
+		if j == len(p.s) {
+			break
+		}
+		c := p.s[j]
+		p.i++
+		switch c {
+		case ',':
+			flush()
+		case '(':
+			subs = append(subs, p.list(')')...)
+		case '[':
+			subs = append(subs, p.list(']')...)
+		default:
+			if c == closer {
+				flush()
+				return ret
+			}
+			if closer == 0 {
+				// Unmatched at the top level
+				continue
+			}
+			// Closes an outer list, e.g. "(a [b)".
+			p.i--
+			flush()
+			return ret
+		}
+	}
+	flush()
+	return ret
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseIngredients(t *testing.T) {
+	cases := []struct {
+		ingredients []string
+		expected    []Ingredient
+	}{
+		{nil, []Ingredient{}},
+		{[]string{"cream", " skim milk "}, []Ingredient{
+			{Name: "cream"}, {Name: "skim milk"}}},
+		// Split on every comma
+		{[]string{"cream", "liquid sugar (sugar", "water)", "eggs"},
+			[]Ingredient{{Name: "cream"}, {Name: "liquid sugar",
+				Ingredients: []Ingredient{{Name: "sugar"}, {Name: "water"}}},
+				{Name: "eggs"}}},
+		{[]string{"cream cheese (pasteurized milk", "cream", "cheese cultures",
+			"salt", "carob bean gum)"}, []Ingredient{{Name: "cream cheese",
+			Ingredients: []Ingredient{{Name: "pasteurized milk"},
+				{Name: "cream"}, {Name: "cheese cultures"}, {Name: "salt"},
+				{Name: "carob bean gum"}}}}},
+		// Nested and bracketed
+		{[]string{"fudge (sugar, butter [cream, salt])", "nuts"},
+			[]Ingredient{{Name: "fudge", Ingredients: []Ingredient{
+				{Name: "sugar"}, {Name: "butter", Ingredients: []Ingredient{
+					{Name: "cream"}, {Name: "salt"}}}}}, {Name: "nuts"}}},
+		// Unbalanced and empty
+		{[]string{"butter (cream", "salt"}, []Ingredient{{Name: "butter",
+			Ingredients: []Ingredient{{Name: "cream"}, {Name: "salt"}}}}},
+		{[]string{"cream)", "", "salt"}, []Ingredient{{Name: "cream"},
+			{Name: "salt"}}},
+		{[]string{"(sugar", "water)"}, []Ingredient{{Name: "sugar"},
+			{Name: "water"}}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, ParseIngredients(c.ingredients),
+			"%q", c.ingredients)
+	}
+}
+
+func TestNormalizeIngredients(t *testing.T) {
+	ingredients := []string{"cream", "liquid sugar (sugar", "water)",
+		"cocoa (processed with alkali)", "butter [cream", "salt]"}
+	normalized := NormalizeIngredients(ingredients)
+	assert.Equal(t, []string{"cream", "liquid sugar (sugar, water)",
+		"cocoa (processed with alkali)", "butter (cream, salt)"}, normalized)
+	// Idempotent
+	assert.Equal(t, normalized, NormalizeIngredients(normalized))
+	assert.Equal(t, []string{}, NormalizeIngredients(nil))
+}
+
+func TestIngredient_IsValid(t *testing.T) {
+	valid := Ingredient{Name: "liquid sugar",
+		Ingredients: []Ingredient{{Name: "sugar"}, {Name: "water"}}}
+	assert.True(t, valid.IsValid())
+	for _, i := range []Ingredient{
+		{},
+		{Name: " sugar"},
+		{Name: "sugar, water"},
+		{Name: "sugar (cane)"},
+		{Name: "liquid sugar", Ingredients: []Ingredient{{Name: ""}}},
+	} {
+		assert.False(t, i.IsValid(), "%+v", i)
+	}
+}
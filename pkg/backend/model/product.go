@@ -1,5 +1,12 @@
 package model
 
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"time"
+)
+
 // Product is everything about an ice cream product.
 type Product struct {
 	// ProductId is mandatory and acts as the primary key.
@@ -14,6 +21,72 @@ type Product struct {
 	Ingredients           []string `json:"ingredients"`
 	AllergyInfo           string   `json:"allergy_info"`
 	DietaryCertifications string   `json:"dietary_certifications"`
+
+	// Allergens are stored alongside AllergyInfo, which they're parsed from
+	// by ParseAllergyInfo unless they're given.
+	Allergens Allergens `json:"allergens"`
+
+	// StructuredIngredients is Ingredients parsed by ParseIngredients.
+	// Backends store Ingredients and derive it when Products are read.
+	StructuredIngredients []Ingredient `json:"structured_ingredients"`
+
+	// CreatedAt and UpdatedAt are maintained by backends. UpdatedAt changes
+	// on every write. Values given by clients are ignored.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Version is maintained by backends. It's 1 when the Product is created
+	// and increases by 1 on every write. Clients see it as the ETag.
+	Version int64 `json:"-"`
+}
+
+// Patch sets fields of p to values of kvs keyed by their names in json, as
+// UpdatePartial of backends does. A nil value resets the field to its zero
+// value, where lists are empty rather than nil. Return error if kvs has an
+// unknown field or a value of the wrong type, and p is not changed.
+func (p *Product) Patch(kvs map[string]interface{}) error {
+	bs, err := json.Marshal(kvs)
+	if err != nil {
+		return err
+	}
+	var patch Product
+	if err := json.Unmarshal(bs, &patch); err != nil {
+		return err
+	}
+	ret := *p
+	for k := range kvs {
+		switch k {
+		case "productId":
+			ret.ProductID = patch.ProductID
+		case "name":
+			ret.Name = patch.Name
+		case "image_closed":
+			ret.ImageClosed = patch.ImageClosed
+		case "image_open":
+			ret.ImageOpen = patch.ImageOpen
+		case "description":
+			ret.Description = patch.Description
+		case "story":
+			ret.Story = patch.Story
+		case "sourcing_values":
+			ret.SourcingValues = append([]string{}, patch.SourcingValues...)
+		case "ingredients":
+			ret.Ingredients = append([]string{}, patch.Ingredients...)
+		case "allergy_info":
+			ret.AllergyInfo = patch.AllergyInfo
+		case "dietary_certifications":
+			ret.DietaryCertifications = patch.DietaryCertifications
+		case "allergens":
+			ret.Allergens = Allergens{
+				Contains:   append([]Allergen{}, patch.Allergens.Contains...),
+				MayContain: append([]Allergen{}, patch.Allergens.MayContain...),
+			}
+		default:
+			return errors.New(fmt.Sprintf("unknown field:%s", k))
+		}
+	}
+	*p = ret
+	return nil
 }
 
 // Products is a page of Products.
@@ -22,5 +95,53 @@ type Products struct {
 	// be used to query the next page.
 	Cursor string `json:"cursor,omitempty"`
 
+	// Total is the number of Products on all pages. It's presented only if
+	// asked for.
+	Total *int64 `json:"total,omitempty"`
+
+	// First and Next are URLs of the first and the next page. Next is absent
+	// on the last page.
+	First string `json:"first,omitempty"`
+	Next  string `json:"next,omitempty"`
+
 	Products []Product `json:"products"`
 }
+
+// Revision is a prior version of a Product, kept when the Product is replaced,
+// updated or deleted.
+type Revision struct {
+	// Revision numbers revisions of a productId from 1 in the order they're
+	// kept. Numbers are not reused even if the Product is deleted and created
+	// again.
+	Revision int64 `json:"revision"`
+	// Version is the version Product was at.
+	Version int64   `json:"version"`
+	Product Product `json:"product"`
+	// Deleted is true if Product was deleted rather than replaced or updated.
+	Deleted bool `json:"deleted"`
+	// APIKey authenticated the write superseding Product, if it's known.
+	APIKey string `json:"apikey,omitempty"`
+	// RevisedAt is when Product was superseded.
+	RevisedAt time.Time `json:"revised_at"`
+}
+
+// Revisions are revisions of a Product ordered by Revision.
+type Revisions struct {
+	Revisions []Revision `json:"revisions"`
+}
+
+// TrashedProduct is a deleted Product in the trash, where it can be restored
+// until it's purged.
+type TrashedProduct struct {
+	Product
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Trash is a page of TrashedProducts ordered by ProductID.
+type Trash struct {
+	// When Cursor is presented, it marks the last row of this page and could
+	// be used to query the next page.
+	Cursor string `json:"cursor,omitempty"`
+
+	Products []TrashedProduct `json:"products"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProduct_Patch(t *testing.T) {
+	product := Product{
+		ProductID:      "001",
+		Name:           "One",
+		Story:          "story",
+		SourcingValues: []string{"Fairtrade"},
+		Allergens:      Allergens{Contains: []Allergen{Milk}},
+	}
+
+	assert.NoError(t, product.Patch(map[string]interface{}{
+		"name":            "Two",
+		"story":           nil,
+		"sourcing_values": nil,
+		"ingredients":     []interface{}{"cream"},
+		"allergens":       nil,
+	}))
+	assert.Equal(t, Product{
+		ProductID:      "001",
+		Name:           "Two",
+		SourcingValues: []string{},
+		Ingredients:    []string{"cream"},
+		Allergens:      Allergens{Contains: []Allergen{}, MayContain: []Allergen{}},
+	}, product)
+
+	// Nothing is changed if any field is invalid
+	for _, kvs := range []map[string]interface{}{
+		{"name": "Three", "unknown": "value"},
+		{"name": "Three", "story": 1},
+		{"name": "Three", "structured_ingredients": nil},
+	} {
+		assert.Error(t, product.Patch(kvs), "%v", kvs)
+		assert.Equal(t, "Two", product.Name)
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+// SearchHit is a Product matching a search and how relevant it is.
+type SearchHit struct {
+	Product Product `json:"product"`
+	// Score is larger for a more relevant Product. Scores are comparable only
+	// among hits of the same search.
+	Score float64 `json:"score"`
+	// Highlights are snippets of matched fields keyed by their names in json.
+	// Matched words are enclosed by <em> and </em> while the rest is escaped
+	// as HTML.
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchResults is a page of SearchHits ordered by relevance.
+type SearchResults struct {
+	// When Cursor is presented, it marks the last row of this page and could
+	// be used to query the next page.
+	Cursor string `json:"cursor,omitempty"`
+
+	Hits []SearchHit `json:"hits"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+)
+
+// Sort orders Products read by ReadMany. It's the name in json of one of
+// SortFields, prefixed by "-" for the descending order. Ties are broken by the
+// order of insertion, which is reversed as well for the descending order. The
+// empty Sort is the order of insertion.
+type Sort string
+
+// SortFields are fields Products can be sorted by.
+var SortFields = []string{"name", "productId", "updated_at"}
+
+// IsValid is true if s is empty or sorts by one of SortFields.
+func (s Sort) IsValid() bool {
+	if s == "" {
+		return true
+	}
+	for _, field := range SortFields {
+		if s.Field() == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Field is the field s sorts by, or empty for the order of insertion.
+func (s Sort) Field() string {
+	return strings.TrimPrefix(string(s), "-")
+}
+
+// IsDescending is true if s sorts in the descending order.
+func (s Sort) IsDescending() bool {
+	return strings.HasPrefix(string(s), "-")
+}
@@ -1,7 +1,9 @@
 package mongodb
 
 import (
+	"context"
 	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	pe "github.com/pkg/errors"
@@ -21,22 +23,22 @@ type MongoAPIKeyBackend struct {
 }
 
 // Authenticate checks if apiKey is stored in the db.
-func (h *MongoAPIKeyBackend) Authenticate(apiKey string) error {
-	q := h.session.DB("").C(apiKeysCollection).Find(
-		&bson.M{"apikey": apiKey})
-
+func (h *MongoAPIKeyBackend) Authenticate(ctx context.Context, apiKey string) error {
 	var keys []mAPIKey
-	if err := q.All(&keys); err != nil {
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		q := s.DB("").C(apiKeysCollection).Find(&bson.M{"apikey": apiKey})
+		return withMaxTime(ctx, q).All(&keys)
+	}); err != nil {
 		log.Error("Query.All failed", "err", err)
 		return pe.WithStack(err)
 	}
 	if len(keys) == 0 {
-		return pe.WithStack(mgo.ErrNotFound)
+		return pe.WithStack(backend.ErrNotFound)
 	} else if len(keys) > 1 {
 		// By design this should not happen. Most likely a duplicated product is
 		// added in an out-of-band fashion.
-		log.Error("Find gets more than 1", "err", ErrInconsistent)
-		return pe.WithStack(ErrInconsistent)
+		log.Error("Find gets more than 1", "err", backend.ErrInconsistent)
+		return pe.WithStack(backend.ErrInconsistent)
 	}
 	log.Debug(fmt.Sprintf("Find _id=%s", keys[0].ID.Hex()))
 	return nil
@@ -0,0 +1,77 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/globalsign/mgo"
+	pe "github.com/pkg/errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// Codes of errors reported by mongoDB.
+const (
+	codeExceededTimeLimit     = 50
+	codeShutdownInProgress    = 91
+	codeHostUnreachable       = 6
+	codeHostNotFound          = 7
+	codeNetworkTimeout        = 89
+	codeNotMaster             = 10107
+	codeNotMasterNoSlaveOk    = 13435
+	codeInterruptedAtShutdown = 11600
+	codePrimarySteppedDown    = 189
+)
+
+// codeOf returns the code of err reported by mongoDB, 0 if there's none.
+func codeOf(err error) int {
+	switch e := err.(type) {
+	case *mgo.QueryError:
+		return e.Code
+	case *mgo.LastError:
+		return e.Code
+	}
+	return 0
+}
+
+// isUnavailable is true if err is caused by losing the connection to mongoDB
+// or by a server that can't serve for the moment.
+func isUnavailable(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	switch codeOf(err) {
+	case codeShutdownInProgress, codeHostUnreachable, codeHostNotFound,
+		codeNetworkTimeout, codeNotMaster, codeNotMasterNoSlaveOk,
+		codeInterruptedAtShutdown, codePrimarySteppedDown:
+		return true
+	}
+	msg := err.Error()
+	return strings.HasPrefix(msg, "no reachable servers") ||
+		msg == "Closed explicitly"
+}
+
+// translate converts errors of mgo to the ones in package backend. Other
+// errors are returned as they are.
+func translate(err error) error {
+	cause := pe.Cause(err)
+	switch {
+	case cause == nil:
+		return nil
+	case cause == context.Canceled || cause == context.DeadlineExceeded:
+		return err
+	case cause == mgo.ErrNotFound:
+		return pe.WithStack(backend.ErrNotFound)
+	case mgo.IsDup(cause):
+		return pe.Wrap(backend.ErrAlreadyExists, cause.Error())
+	case codeOf(cause) == codeExceededTimeLimit:
+		// The server aborted the query which ran past maxTimeMS.
+		return pe.Wrap(context.DeadlineExceeded, cause.Error())
+	case isUnavailable(cause):
+		return pe.Wrap(backend.ErrUnavailable, cause.Error())
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/globalsign/mgo"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	anyErr := fmt.Errorf("any error")
+	cases := []struct {
+		err   error
+		cause error
+	}{
+		{nil, nil},
+		{mgo.ErrNotFound, backend.ErrNotFound},
+		{&mgo.LastError{Code: 11000, Err: "E11000 duplicate key"},
+			backend.ErrAlreadyExists},
+		{&mgo.QueryError{Code: 50, Message: "operation exceeded time limit"},
+			context.DeadlineExceeded},
+		{&mgo.QueryError{Code: 10107, Message: "not master"},
+			backend.ErrUnavailable},
+		{io.EOF, backend.ErrUnavailable},
+		{fmt.Errorf("no reachable servers"), backend.ErrUnavailable},
+		{context.Canceled, context.Canceled},
+		{anyErr, anyErr},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.cause, pe.Cause(translate(c.err)), "err:%v", c.err)
+		if c.err != nil {
+			assert.Equal(t, c.cause, pe.Cause(translate(pe.WithStack(c.err))),
+				"err:%v", c.err)
+		}
+	}
+}
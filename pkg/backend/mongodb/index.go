@@ -0,0 +1,280 @@
+package mongodb
+
+import (
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/search"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	pe "github.com/pkg/errors"
+	"strings"
+)
+
+// maxDuplicatesReported is the max number of duplicated values reported when
+// a unique index can't be created.
+const maxDuplicatesReported = 10
+
+type collectionIndex struct {
+	collection string
+	index      mgo.Index
+}
+
+// indexes are what backends rely on. Pagination relies on _id, which is always
+// indexed by mongoDB.
+var indexes = []collectionIndex{
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name:   "productId_unique",
+			Key:    []string{"productId"},
+			Unique: true,
+		},
+	},
+	{
+		collection: apiKeysCollection,
+		index: mgo.Index{
+			Name:   "apikey_unique",
+			Key:    []string{"apikey"},
+			Unique: true,
+		},
+	},
+}
+
+// revisionIndexes are added to indexes later by a migration.
+var revisionIndexes = []collectionIndex{
+	{
+		collection: revisionsCollection,
+		index: mgo.Index{
+			Name:   "productId_revision_unique",
+			Key:    []string{"productId", "revision"},
+			Unique: true,
+		},
+	},
+}
+
+// filterIndexes serve filters of ReadMany. They're added to indexes later by a
+// migration.
+var filterIndexes = []collectionIndex{
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "ingredients",
+			Key:  []string{"ingredients"},
+		},
+	},
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "sourcing_values",
+			Key:  []string{"sourcing_values"},
+		},
+	},
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "dietary_certifications",
+			Key:  []string{"dietary_certifications"},
+		},
+	},
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "name",
+			Key:  []string{"name"},
+		},
+	},
+}
+
+// sortIndexes serve ReadMany sorted by fields, where ties are broken by _id.
+// They're added to indexes later by a migration.
+var sortIndexes = []collectionIndex{
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "name_id",
+			Key:  []string{"name", "_id"},
+		},
+	},
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "productId_id",
+			Key:  []string{"productId", "_id"},
+		},
+	},
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "updated_at_id",
+			Key:  []string{"updated_at", "_id"},
+		},
+	},
+}
+
+// textIndexes serve Search. Fields are weighted like package search does. Keys
+// are sorted as mongoDB lists them. They're added to indexes later by a
+// migration.
+var textIndexes = []collectionIndex{
+	{
+		collection: productsCollection,
+		index: mgo.Index{
+			Name: "text",
+			Key: []string{"$text:description", "$text:ingredients",
+				"$text:name", "$text:story"},
+			Weights:         search.Weights,
+			DefaultLanguage: "english",
+		},
+	},
+}
+
+// EnsureIndexes creates indexes of products, apikeys and revisions if they
+// don't exist and verifies them. If existing documents violate a unique index,
+// the error has backend.ErrInconsistent as the cause and lists some of the
+// duplicated values.
+func EnsureIndexes(session *mgo.Session) error {
+	db := session.DB("")
+	for _, ensure := range []func(*mgo.Database) error{
+		ensureIndexes, ensureRevisionIndexes, ensureFilterIndexes,
+		ensureTextIndexes, ensureSortIndexes,
+	} {
+		if err := ensure(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureIndexes(db *mgo.Database) error {
+	return ensureIndexesOf(db, indexes)
+}
+
+func ensureRevisionIndexes(db *mgo.Database) error {
+	return ensureIndexesOf(db, revisionIndexes)
+}
+
+func ensureFilterIndexes(db *mgo.Database) error {
+	return ensureIndexesOf(db, filterIndexes)
+}
+
+func ensureTextIndexes(db *mgo.Database) error {
+	return ensureIndexesOf(db, textIndexes)
+}
+
+func ensureSortIndexes(db *mgo.Database) error {
+	return ensureIndexesOf(db, sortIndexes)
+}
+
+func ensureIndexesOf(db *mgo.Database, cis []collectionIndex) error {
+	for _, ci := range cis {
+		c := db.C(ci.collection)
+		if err := c.EnsureIndex(ci.index); err != nil {
+			if mgo.IsDup(err) {
+				return reportDuplicates(c, ci.index)
+			}
+			log.Error("EnsureIndex failed", "collection", ci.collection,
+				"index", ci.index.Name, "err", err)
+			return pe.Wrapf(err, "EnsureIndex %s.%s failed", ci.collection,
+				ci.index.Name)
+		}
+		if err := verifyIndex(c, ci.index); err != nil {
+			return err
+		}
+		log.Debug("EnsureIndex succeeded", "collection", ci.collection,
+			"index", ci.index.Name)
+	}
+	return nil
+}
+
+// dropIndexes drops indexes created by ensureIndexes.
+func dropIndexes(db *mgo.Database) error {
+	return dropIndexesOf(db, indexes)
+}
+
+// dropRevisionIndexes drops indexes created by ensureRevisionIndexes.
+func dropRevisionIndexes(db *mgo.Database) error {
+	return dropIndexesOf(db, revisionIndexes)
+}
+
+// dropFilterIndexes drops indexes created by ensureFilterIndexes.
+func dropFilterIndexes(db *mgo.Database) error {
+	return dropIndexesOf(db, filterIndexes)
+}
+
+// dropTextIndexes drops indexes created by ensureTextIndexes.
+func dropTextIndexes(db *mgo.Database) error {
+	return dropIndexesOf(db, textIndexes)
+}
+
+// dropSortIndexes drops indexes created by ensureSortIndexes.
+func dropSortIndexes(db *mgo.Database) error {
+	return dropIndexesOf(db, sortIndexes)
+}
+
+func dropIndexesOf(db *mgo.Database, cis []collectionIndex) error {
+	for _, ci := range cis {
+		err := db.C(ci.collection).DropIndexName(ci.index.Name)
+		if err != nil && !isIndexNotFound(err) {
+			log.Error("DropIndexName failed", "collection", ci.collection,
+				"index", ci.index.Name, "err", err)
+			return pe.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// isIndexNotFound is true if err is caused by dropping an index not existed.
+func isIndexNotFound(err error) bool {
+	if qe, ok := err.(*mgo.QueryError); ok && qe.Code == 27 {
+		return true
+	}
+	return strings.Contains(err.Error(), "index not found")
+}
+
+// verifyIndex checks an index of the same name, keys and uniqueness exists.
+func verifyIndex(c *mgo.Collection, index mgo.Index) error {
+	existing, err := c.Indexes()
+	if err != nil {
+		log.Error("Indexes failed", "collection", c.Name, "err", err)
+		return pe.WithStack(err)
+	}
+	for _, idx := range existing {
+		if idx.Name != index.Name {
+			continue
+		}
+		if strings.Join(idx.Key, ",") != strings.Join(index.Key, ",") ||
+			idx.Unique != index.Unique {
+			break
+		}
+		return nil
+	}
+	log.Error("Index not verified", "collection", c.Name,
+		"index", index.Name, "err", backend.ErrInconsistent)
+	return pe.Wrapf(backend.ErrInconsistent, "index %s.%s not verified", c.Name,
+		index.Name)
+}
+
+// reportDuplicates finds values of the key of index that are duplicated.
+func reportDuplicates(c *mgo.Collection, index mgo.Index) error {
+	key := index.Key[0]
+	var dups []struct {
+		Value interface{} `bson:"_id"`
+		Count int         `bson:"count"`
+	}
+	if err := c.Pipe([]bson.M{
+		{"$group": bson.M{"_id": "$" + key, "count": bson.M{"$sum": 1}}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+		{"$limit": maxDuplicatesReported},
+	}).All(&dups); err != nil {
+		log.Error("Pipe failed", "collection", c.Name, "err", err)
+		return pe.WithStack(err)
+	}
+	values := make([]string, 0, len(dups))
+	for _, dup := range dups {
+		log.Error("Duplicated", "collection", c.Name, key, dup.Value,
+			"count", dup.Count)
+		values = append(values, fmt.Sprintf("%v(%d)", dup.Value, dup.Count))
+	}
+	return pe.Wrapf(backend.ErrInconsistent,
+		"duplicated %s in %s blocks index %s: %s", key, c.Name, index.Name,
+		strings.Join(values, ", "))
+}
@@ -0,0 +1,39 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var ctx = context.Background()
+
+func TestEnsureIndexes(t *testing.T) {
+	session, drop := dialTestDB(t)
+	defer drop()
+
+	assert.NoError(t, EnsureIndexes(session))
+	// Idempotent
+	assert.NoError(t, EnsureIndexes(session))
+
+	b, _ := CreateMongoProductBackend(session)
+	assert.NoError(t, b.Create(ctx, (&mProduct{ProductID: "001"}).ToProduct()))
+	// Duplicates can't be inserted behind the backend
+	err := session.DB("").C(productsCollection).Insert(&mProduct{ProductID: "001"})
+	assert.Error(t, err)
+}
+
+func TestEnsureIndexes_Duplicated(t *testing.T) {
+	session, drop := dialTestDB(t)
+	defer drop()
+
+	c := session.DB("").C(productsCollection)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, c.Insert(&mProduct{ProductID: "001"}))
+	}
+	err := EnsureIndexes(session)
+	assert.Equal(t, backend.ErrInconsistent, pe.Cause(err))
+	assert.Contains(t, err.Error(), "001(2)")
+}
@@ -0,0 +1,10 @@
+/*
+Package migration evolves documents stored in mongoDB by ordered, versioned
+migrations. Versions of applied migrations are recorded in the collection
+schema_migrations of the same database, so a Migrator knows what's pending.
+
+A Migration should be idempotent since mongoDB doesn't apply it and the record
+of it atomically. If a step fails, the record is not changed and it's safe to
+run again after fixing the cause.
+*/
+package migration
@@ -0,0 +1,214 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"github.com/globalsign/mgo"
+	"github.com/inconshreveable/log15"
+	pe "github.com/pkg/errors"
+	"time"
+)
+
+const migrationsCollection = "schema_migrations"
+
+var (
+	log = log15.New("module", "backend.mongodb.migration")
+	// ErrParameters when inputs are invalid.
+	ErrParameters = errors.New("bad parameters")
+	// ErrUnknownVersion when the recorded version is not a known migration,
+	// e.g. the db was migrated by a newer apiserver.
+	ErrUnknownVersion = errors.New("unknown version")
+)
+
+// Migration changes stored data from Version-1 to Version by Up, and back by
+// Down.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db *mgo.Database) error
+	Down        func(db *mgo.Database) error
+}
+
+// Status is a Migration and whether it's applied.
+type Status struct {
+	Version     int
+	Description string
+	Applied     bool
+	// AppliedAt is zero if not applied.
+	AppliedAt time.Time
+}
+
+type record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *mgo.Database
+	migrations []Migration
+}
+
+func (m *Migrator) records() (map[int]record, error) {
+	var rs []record
+	if err := m.db.C(migrationsCollection).Find(nil).All(&rs); err != nil {
+		log.Error("Query.All failed", "err", err)
+		return nil, pe.WithStack(err)
+	}
+	ret := make(map[int]record, len(rs))
+	for _, r := range rs {
+		ret[r.Version] = r
+	}
+	return ret, nil
+}
+
+// Version returns the version of the latest applied migration, or 0 if none.
+func (m *Migrator) Version() (int, error) {
+	rs, err := m.records()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range rs {
+		if v > len(m.migrations) {
+			log.Error("Unknown version", "version", v,
+				"err", ErrUnknownVersion)
+			return 0, pe.Wrapf(ErrUnknownVersion, "version %d", v)
+		}
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Latest returns the version of the last migration.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Status returns every migration and whether it's applied.
+func (m *Migrator) Status() ([]Status, error) {
+	if _, err := m.Version(); err != nil {
+		return nil, err
+	}
+	rs, err := m.records()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		r, ok := rs[mg.Version]
+		ret = append(ret, Status{
+			Version:     mg.Version,
+			Description: mg.Description,
+			Applied:     ok,
+			AppliedAt:   r.AppliedAt,
+		})
+	}
+	return ret, nil
+}
+
+// Up applies pending migrations until target, or the latest if target is 0.
+// If dryRun, nothing is applied. It returns the migrations applied, or to be
+// applied if dryRun.
+func (m *Migrator) Up(target int, dryRun bool) ([]Migration, error) {
+	if target == 0 {
+		target = m.Latest()
+	}
+	current, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if target < current || target > m.Latest() {
+		log.Error(fmt.Sprintf("Invalid target:%d", target),
+			"version", current, "err", ErrParameters)
+		return nil, pe.Wrapf(ErrParameters, "target %d, version %d",
+			target, current)
+	}
+	steps := m.migrations[current:target]
+	if dryRun {
+		return steps, nil
+	}
+	for i, mg := range steps {
+		log.Info("Up", "version", mg.Version, "description", mg.Description)
+		if err := mg.Up(m.db); err != nil {
+			log.Error("Up failed", "version", mg.Version, "err", err)
+			return steps[:i], pe.Wrapf(err, "up %d failed", mg.Version)
+		}
+		if err := m.db.C(migrationsCollection).Insert(&record{
+			Version:     mg.Version,
+			Description: mg.Description,
+			AppliedAt:   time.Now().UTC(),
+		}); err != nil {
+			log.Error("Insert failed", "version", mg.Version, "err", err)
+			return steps[:i], pe.WithStack(err)
+		}
+	}
+	return steps, nil
+}
+
+// Down reverts applied migrations until the version becomes target. If dryRun,
+// nothing is reverted. It returns the migrations reverted, or to be reverted
+// if dryRun, in the order of reverting.
+func (m *Migrator) Down(target int, dryRun bool) ([]Migration, error) {
+	current, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if target < 0 || target > current {
+		log.Error(fmt.Sprintf("Invalid target:%d", target),
+			"version", current, "err", ErrParameters)
+		return nil, pe.Wrapf(ErrParameters, "target %d, version %d",
+			target, current)
+	}
+	steps := make([]Migration, 0, current-target)
+	for v := current; v > target; v-- {
+		steps = append(steps, m.migrations[v-1])
+	}
+	if dryRun {
+		return steps, nil
+	}
+	for i, mg := range steps {
+		log.Info("Down", "version", mg.Version,
+			"description", mg.Description)
+		if err := mg.Down(m.db); err != nil {
+			log.Error("Down failed", "version", mg.Version, "err", err)
+			return steps[:i], pe.Wrapf(err, "down %d failed", mg.Version)
+		}
+		if err := m.db.C(migrationsCollection).RemoveId(
+			mg.Version); err != nil && err != mgo.ErrNotFound {
+			log.Error("RemoveId failed", "version", mg.Version, "err", err)
+			return steps[:i], pe.WithStack(err)
+		}
+	}
+	return steps, nil
+}
+
+// validate checks versions of migrations are 1, 2, 3... in order and both Up
+// and Down are given.
+func validate(migrations []Migration) error {
+	for i, mg := range migrations {
+		if mg.Version != i+1 {
+			return pe.Wrapf(ErrParameters, "version %d at %d", mg.Version, i)
+		}
+		if mg.Up == nil || mg.Down == nil {
+			return pe.Wrapf(ErrParameters, "version %d lacks up or down",
+				mg.Version)
+		}
+	}
+	return nil
+}
+
+// CreateMigrator creates Migrator of migrations for the database of session.
+func CreateMigrator(session *mgo.Session, migrations []Migration) (*Migrator, error) {
+	if err := validate(migrations); err != nil {
+		log.Error("Invalid migrations", "err", err)
+		return nil, err
+	}
+	return &Migrator{
+		db:         session.DB(""),
+		migrations: migrations,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package migration
+
+import (
+	"fmt"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+// testMongoEnv is the host:port of a mongoDB for tests. Tests are skipped if
+// it's not set.
+const testMongoEnv = "ICECREAM_TEST_MONGO"
+
+func dialTestDB(t *testing.T) (*mgo.Session, func()) {
+	addr := os.Getenv(testMongoEnv)
+	if addr == "" {
+		t.Skip(testMongoEnv + " is not set")
+	}
+	database := fmt.Sprintf("icecream_test_%d", time.Now().UnixNano())
+	session, err := mgo.Dial(fmt.Sprintf("mongodb://%s/%s", addr, database))
+	if err != nil {
+		t.Fatalf("mgo.Dial failed, %s", err)
+	}
+	return session, func() {
+		session.DB("").DropDatabase()
+		session.Close()
+	}
+}
+
+// counter creates a migration that sets "n" of the doc "counter" to version.
+func counter(version int) Migration {
+	set := func(n int) func(db *mgo.Database) error {
+		return func(db *mgo.Database) error {
+			_, err := db.C("counter").UpsertId("counter",
+				bson.M{"$set": bson.M{"n": n}})
+			return err
+		}
+	}
+	return Migration{
+		Version:     version,
+		Description: fmt.Sprintf("counter %d", version),
+		Up:          set(version),
+		Down:        set(version - 1),
+	}
+}
+
+func readCounter(t *testing.T, session *mgo.Session) int {
+	var doc struct {
+		N int `bson:"n"`
+	}
+	if err := session.DB("").C("counter").FindId("counter").One(&doc); err != nil {
+		return 0
+	}
+	return doc.N
+}
+
+func TestCreateMigrator_Invalid(t *testing.T) {
+	for _, ms := range [][]Migration{
+		{counter(2)},
+		{counter(1), counter(1)},
+		{counter(1), counter(3)},
+		{{Version: 1, Up: counter(1).Up}},
+	} {
+		_, err := CreateMigrator(nil, ms)
+		assert.Equal(t, ErrParameters, pe.Cause(err))
+	}
+	assert.NoError(t, validate([]Migration{counter(1), counter(2)}))
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	session, drop := dialTestDB(t)
+	defer drop()
+
+	m, err := CreateMigrator(session, []Migration{counter(1), counter(2),
+		counter(3)})
+	assert.NoError(t, err)
+
+	// dry-run doesn't apply
+	steps, err := m.Up(0, true)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 3)
+	v, _ := m.Version()
+	assert.Equal(t, 0, v)
+
+	steps, err = m.Up(2, false)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 2)
+	assert.Equal(t, 2, readCounter(t, session))
+
+	statuses, err := m.Status()
+	assert.NoError(t, err)
+	assert.True(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+
+	steps, err = m.Up(0, false)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 1)
+	assert.Equal(t, 3, readCounter(t, session))
+
+	_, err = m.Up(1, false)
+	assert.Equal(t, ErrParameters, pe.Cause(err))
+
+	steps, err = m.Down(1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, steps[0].Version)
+	assert.Equal(t, 2, steps[1].Version)
+	assert.Equal(t, 1, readCounter(t, session))
+	v, _ = m.Version()
+	assert.Equal(t, 1, v)
+
+	// An older migrator doesn't know version 3
+	m.Up(0, false)
+	older, _ := CreateMigrator(session, []Migration{counter(1)})
+	_, err = older.Version()
+	assert.Equal(t, ErrUnknownVersion, pe.Cause(err))
+}
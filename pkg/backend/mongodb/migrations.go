@@ -0,0 +1,221 @@
+package mongodb
+
+import (
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/cfchou/icecream/pkg/backend/mongodb/migration"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	pe "github.com/pkg/errors"
+	"reflect"
+)
+
+// Migrations evolve products and apikeys stored by this package. Released
+// migrations must not be modified or reordered, append new ones instead.
+var Migrations = []migration.Migration{
+	{
+		Version:     1,
+		Description: "unique indexes on products.productId and apikeys.apikey",
+		Up:          ensureIndexes,
+		Down:        dropIndexes,
+	},
+	{
+		Version:     2,
+		Description: "products.version for optimistic concurrency",
+		Up:          addVersion,
+		Down:        removeVersion,
+	},
+	{
+		Version:     3,
+		Description: "products.created_at and products.updated_at",
+		Up:          addTimestamps,
+		Down:        removeTimestamps,
+	},
+	{
+		Version:     4,
+		Description: "unique index on product_revisions.productId and revision",
+		Up:          ensureRevisionIndexes,
+		Down:        dropRevisionIndexes,
+	},
+	{
+		Version:     5,
+		Description: "indexes on products fields filtered by ReadMany",
+		Up:          ensureFilterIndexes,
+		Down:        dropFilterIndexes,
+	},
+	{
+		Version:     6,
+		Description: "text index on products fields searched by Search",
+		Up:          ensureTextIndexes,
+		Down:        dropTextIndexes,
+	},
+	{
+		Version:     7,
+		Description: "indexes on products fields sorted by ReadMany",
+		Up:          ensureSortIndexes,
+		Down:        dropSortIndexes,
+	},
+	{
+		Version:     8,
+		Description: "products.ingredients repaired where split on every comma",
+		Up:          repairIngredients,
+		Down:        keepRepairedIngredients,
+	},
+	{
+		Version:     9,
+		Description: "products.allergens parsed from allergy_info",
+		Up:          addAllergens,
+		Down:        removeAllergens,
+	},
+}
+
+// addVersion sets version 1 to products stored before versions are
+// maintained.
+func addVersion(db *mgo.Database) error {
+	info, err := db.C(productsCollection).UpdateAll(
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 1}})
+	if err != nil {
+		log.Error("UpdateAll failed", "err", err)
+		return pe.WithStack(err)
+	}
+	log.Info("Add version", "updated", info.Updated)
+	return nil
+}
+
+func removeVersion(db *mgo.Database) error {
+	if _, err := db.C(productsCollection).UpdateAll(nil,
+		bson.M{"$unset": bson.M{"version": ""}}); err != nil {
+		log.Error("UpdateAll failed", "err", err)
+		return pe.WithStack(err)
+	}
+	return nil
+}
+
+// addTimestamps sets created_at and updated_at of products stored before
+// timestamps are maintained to the time their _id was generated.
+func addTimestamps(db *mgo.Database) error {
+	c := db.C(productsCollection)
+	iter := c.Find(bson.M{"created_at": bson.M{"$exists": false}}).
+		Select(bson.M{"_id": 1}).Iter()
+	var doc struct {
+		ID bson.ObjectId `bson:"_id"`
+	}
+	n := 0
+	for iter.Next(&doc) {
+		t := doc.ID.Time().UTC()
+		if err := c.UpdateId(doc.ID, bson.M{"$set": bson.M{
+			"created_at": t,
+			"updated_at": t,
+		}}); err != nil {
+			iter.Close()
+			log.Error("UpdateId failed", "_id", doc.ID.Hex(), "err", err)
+			return pe.WithStack(err)
+		}
+		n++
+	}
+	if err := iter.Close(); err != nil {
+		log.Error("Iter failed", "err", err)
+		return pe.WithStack(err)
+	}
+	log.Info("Add timestamps", "updated", n)
+	return nil
+}
+
+func removeTimestamps(db *mgo.Database) error {
+	if _, err := db.C(productsCollection).UpdateAll(nil,
+		bson.M{"$unset": bson.M{"created_at": "", "updated_at": ""}}); err != nil {
+		log.Error("UpdateAll failed", "err", err)
+		return pe.WithStack(err)
+	}
+	return nil
+}
+
+// repairIngredients normalizes ingredients of products that were split on every
+// comma, e.g. "liquid sugar (sugar" and "water)". Products repaired get a new
+// version and updated_at so that cached ones are not matched.
+func repairIngredients(db *mgo.Database) error {
+	c := db.C(productsCollection)
+	iter := c.Find(nil).Select(bson.M{"_id": 1, "ingredients": 1}).Iter()
+	var doc struct {
+		ID          bson.ObjectId `bson:"_id"`
+		Ingredients []string      `bson:"ingredients"`
+	}
+	n := 0
+	for iter.Next(&doc) {
+		normalized := model.NormalizeIngredients(doc.Ingredients)
+		if len(doc.Ingredients) == 0 ||
+			reflect.DeepEqual(normalized, doc.Ingredients) {
+			continue
+		}
+		if err := c.UpdateId(doc.ID, bson.M{
+			"$set": bson.M{
+				"ingredients": normalized,
+				"updated_at":  backend.Now(),
+			},
+			"$inc": bson.M{"version": 1},
+		}); err != nil {
+			iter.Close()
+			log.Error("UpdateId failed", "_id", doc.ID.Hex(), "err", err)
+			return pe.WithStack(err)
+		}
+		n++
+	}
+	if err := iter.Close(); err != nil {
+		log.Error("Iter failed", "err", err)
+		return pe.WithStack(err)
+	}
+	log.Info("Repair ingredients", "updated", n)
+	return nil
+}
+
+// keepRepairedIngredients does nothing. How ingredients were split can't be
+// recovered, and repaired ones are still valid.
+func keepRepairedIngredients(db *mgo.Database) error {
+	return nil
+}
+
+// addAllergens parses allergy_info of products stored before allergens are.
+func addAllergens(db *mgo.Database) error {
+	c := db.C(productsCollection)
+	iter := c.Find(bson.M{"allergens": bson.M{"$exists": false}}).
+		Select(bson.M{"_id": 1, "allergy_info": 1}).Iter()
+	var doc struct {
+		ID          bson.ObjectId `bson:"_id"`
+		AllergyInfo string        `bson:"allergy_info"`
+	}
+	n := 0
+	for iter.Next(&doc) {
+		allergens := model.ParseAllergyInfo(doc.AllergyInfo)
+		if err := c.UpdateId(doc.ID, bson.M{"$set": bson.M{
+			"allergens": createMProduct(&model.Product{
+				Allergens: allergens,
+			}).Allergens,
+		}}); err != nil {
+			iter.Close()
+			log.Error("UpdateId failed", "_id", doc.ID.Hex(), "err", err)
+			return pe.WithStack(err)
+		}
+		n++
+	}
+	if err := iter.Close(); err != nil {
+		log.Error("Iter failed", "err", err)
+		return pe.WithStack(err)
+	}
+	log.Info("Add allergens", "updated", n)
+	return nil
+}
+
+func removeAllergens(db *mgo.Database) error {
+	if _, err := db.C(productsCollection).UpdateAll(nil,
+		bson.M{"$unset": bson.M{"allergens": ""}}); err != nil {
+		log.Error("UpdateAll failed", "err", err)
+		return pe.WithStack(err)
+	}
+	return nil
+}
+
+// CreateMigrator creates a Migrator of Migrations for the database of session.
+func CreateMigrator(session *mgo.Session) (*migration.Migrator, error) {
+	return migration.CreateMigrator(session, Migrations)
+}
@@ -1,29 +1,33 @@
 package mongodb
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
 	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/cfchou/icecream/pkg/backend/search"
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/inconshreveable/log15"
 	pe "github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
-const productsCollection = "products"
-
-var (
-	log = log15.New("module", "backend.mongodb")
-	// ErrExisted when data existed in the db.
-	ErrExisted = errors.New("existed")
-	// ErrInconsistent when data violates some constraints, e.g. duplicated
-	// ProductID
-	ErrInconsistent = errors.New("inconsistent")
-	// ErrParameters when inputs are invalid.
-	ErrParameters = errors.New("bad parameters")
+const (
+	productsCollection = "products"
+	// revisionsCollection keeps revisions of products, numbered by sequences
+	// per productId in revisionSeqsCollection.
+	revisionsCollection    = "product_revisions"
+	revisionSeqsCollection = "product_revision_seqs"
+	// trashedCollection keeps deleted products keyed by productId.
+	trashedCollection = "trashed_products"
 )
 
+var log = log15.New("module", "backend.mongodb")
+
 type mProduct struct {
 	ID bson.ObjectId `bson:"_id,omitempty" json:"_id,omitempty"`
 	// ProductId is mandatory.
@@ -31,14 +35,26 @@ type mProduct struct {
 	// Name is mandatory.
 	Name string `bson:"name" json:"name"`
 
-	ImageClosed           string   `bson:"image_closed" json:"image_closed"`
-	ImageOpen             string   `bson:"image_open" json:"image_open"`
-	Description           string   `bson:"description" json:"description"`
-	Story                 string   `bson:"story" json:"story"`
-	SourcingValues        []string `bson:"sourcing_values" json:"sourcing_values"`
-	Ingredients           []string `bson:"ingredients" json:"ingredients"`
-	AllergyInfo           string   `bson:"allergy_info" json:"allergy_info"`
-	DietaryCertifications string   `bson:"dietary_certifications" json:"dietary_certifications"`
+	ImageClosed           string     `bson:"image_closed" json:"image_closed"`
+	ImageOpen             string     `bson:"image_open" json:"image_open"`
+	Description           string     `bson:"description" json:"description"`
+	Story                 string     `bson:"story" json:"story"`
+	SourcingValues        []string   `bson:"sourcing_values" json:"sourcing_values"`
+	Ingredients           []string   `bson:"ingredients" json:"ingredients"`
+	AllergyInfo           string     `bson:"allergy_info" json:"allergy_info"`
+	DietaryCertifications string     `bson:"dietary_certifications" json:"dietary_certifications"`
+	Allergens             mAllergens `bson:"allergens" json:"allergens"`
+	// Timestamps and Version are omitted when they're zero so that mProduct
+	// can be used in $set while created_at is set by $setOnInsert and the
+	// version is increased by $inc.
+	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	Version   int64     `bson:"version,omitempty" json:"version,omitempty"`
+}
+
+type mAllergens struct {
+	Contains   []model.Allergen `bson:"contains" json:"contains"`
+	MayContain []model.Allergen `bson:"may_contain" json:"may_contain"`
 }
 
 func createMProduct(product *model.Product) *mProduct {
@@ -53,6 +69,10 @@ func createMProduct(product *model.Product) *mProduct {
 		Ingredients:           product.Ingredients,
 		AllergyInfo:           product.AllergyInfo,
 		DietaryCertifications: product.DietaryCertifications,
+		Allergens: mAllergens{
+			Contains:   product.Allergens.Contains,
+			MayContain: product.Allergens.MayContain,
+		},
 	}
 }
 
@@ -68,10 +88,73 @@ func (h *mProduct) ToProduct() *model.Product {
 		Ingredients:           h.Ingredients,
 		AllergyInfo:           h.AllergyInfo,
 		DietaryCertifications: h.DietaryCertifications,
+		Allergens: model.Allergens{
+			Contains:   h.Allergens.Contains,
+			MayContain: h.Allergens.MayContain,
+		},
+		StructuredIngredients: model.ParseIngredients(h.Ingredients),
+		CreatedAt:             h.CreatedAt.UTC(),
+		UpdatedAt:             h.UpdatedAt.UTC(),
+		Version:               h.Version,
+	}
+}
+
+type mRevision struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	ProductID string        `bson:"productId"`
+	Revision  int64         `bson:"revision"`
+	Product   mProduct      `bson:"product"`
+	Deleted   bool          `bson:"deleted"`
+	APIKey    string        `bson:"apikey,omitempty"`
+	RevisedAt time.Time     `bson:"revised_at"`
+}
+
+func (h *mRevision) ToRevision() *model.Revision {
+	return &model.Revision{
+		Revision:  h.Revision,
+		Version:   h.Product.Version,
+		Product:   *h.Product.ToProduct(),
+		Deleted:   h.Deleted,
+		APIKey:    h.APIKey,
+		RevisedAt: h.RevisedAt.UTC(),
+	}
+}
+
+type mTrashed struct {
+	ProductID string    `bson:"_id"`
+	Product   mProduct  `bson:"product"`
+	DeletedAt time.Time `bson:"deleted_at"`
+}
+
+func (h *mTrashed) ToTrashedProduct() *model.TrashedProduct {
+	return &model.TrashedProduct{
+		Product:   *h.Product.ToProduct(),
+		DeletedAt: h.DeletedAt.UTC(),
+	}
+}
+
+// selectorOf selects the document of productID. If version is not 0, the
+// version of the document must match as well.
+func selectorOf(productID string, version int64) bson.M {
+	selector := bson.M{"productId": productID}
+	if version != 0 {
+		selector["version"] = version
 	}
+	return selector
+}
+
+// checkVersion checks version given to conditional writes.
+func checkVersion(productID string, version int64) error {
+	if version <= 0 {
+		log.Error(fmt.Sprintf("Invalid version:%d", version),
+			"productId", productID, "err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return nil
 }
 
 // MongoProductBackend stores a mongoDB session to support CRUD for Product.
+// Every call runs on a copy of the session.
 type MongoProductBackend struct {
 	session *mgo.Session
 }
@@ -83,16 +166,24 @@ func (h *MongoProductBackend) Close() {
 
 // Create exclusively creates product. Success only if no Product with the
 // same ProductId existed.
-func (h *MongoProductBackend) Create(product *model.Product) error {
+func (h *MongoProductBackend) Create(ctx context.Context, product *model.Product) error {
 	if product.ProductID == "" {
 		log.Error("Invalid productId", "productId", product.ProductID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
 	}
 	mp := createMProduct(product)
+	mp.Version = 1
+	mp.CreatedAt = backend.Now()
+	mp.UpdatedAt = mp.CreatedAt
 
-	info, err := h.session.DB("").C(productsCollection).Upsert(
-		&bson.M{"productId": mp.ProductID}, &bson.M{"$setOnInsert": mp})
+	var info *mgo.ChangeInfo
+	err := run(ctx, h.session, func(s *mgo.Session) error {
+		var err error
+		info, err = s.DB("").C(productsCollection).Upsert(
+			&bson.M{"productId": mp.ProductID}, &bson.M{"$setOnInsert": mp})
+		return err
+	})
 	if err != nil {
 		log.Error("Create failed", "productId", mp.ProductID, "err", err)
 		return pe.WithStack(err)
@@ -100,8 +191,8 @@ func (h *MongoProductBackend) Create(product *model.Product) error {
 	// One matched but no-op since $set is not given.
 	if info.Matched != 0 {
 		log.Error("Create existed failed ", "productId", mp.ProductID,
-			"err", ErrExisted)
-		return pe.WithStack(ErrExisted)
+			"err", backend.ErrAlreadyExists)
+		return pe.WithStack(backend.ErrAlreadyExists)
 	}
 	// No one matched, trigger insert with $setOnInsert
 	oid := info.UpsertedId.(bson.ObjectId)
@@ -112,22 +203,41 @@ func (h *MongoProductBackend) Create(product *model.Product) error {
 
 // Upsert inserts product. If a Product with the same productID existed already,
 // then a replacement is performed.
-func (h *MongoProductBackend) Upsert(product *model.Product) error {
+func (h *MongoProductBackend) Upsert(ctx context.Context, product *model.Product) error {
 	if product.ProductID == "" {
 		log.Error("Invalid productId", "productId", product.ProductID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
 	}
 	mp := createMProduct(product)
+	mp.UpdatedAt = backend.Now()
 
-	info, err := h.session.DB("").C(productsCollection).Upsert(
-		&bson.M{"productId": mp.ProductID}, mp)
-	if err != nil {
+	var info *mgo.ChangeInfo
+	var old mProduct
+	err := run(ctx, h.session, func(s *mgo.Session) error {
+		var err error
+		info, err = s.DB("").C(productsCollection).Find(
+			&bson.M{"productId": mp.ProductID}).Apply(mgo.Change{
+			Update: &bson.M{
+				"$set":         mp,
+				"$setOnInsert": bson.M{"created_at": mp.UpdatedAt},
+				"$inc":         bson.M{"version": 1},
+			},
+			Upsert: true,
+		}, &old)
+		return err
+	})
+	if pe.Cause(err) == backend.ErrAlreadyExists {
+		// A concurrent upsert inserted the same productId first.
+		log.Error("Upsert conflicted", "productId", mp.ProductID, "err", err)
+		return pe.Wrap(backend.ErrConflict, err.Error())
+	} else if err != nil {
 		log.Error("Upsert failed", "productId", mp.ProductID, "err", err)
 		return pe.WithStack(err)
 	}
 	// One matched, trigger update
 	if info.Updated != 0 {
+		h.keep(detached(ctx), &old, false)
 		log.Debug("Upsert update succeeded", "productId", mp.ProductID)
 		return nil
 	}
@@ -140,103 +250,216 @@ func (h *MongoProductBackend) Upsert(product *model.Product) error {
 
 // Update updates product. Success only if a Product with the same
 // ProductId existed. Return error if not existed.
-func (h *MongoProductBackend) Update(product *model.Product) error {
+func (h *MongoProductBackend) Update(ctx context.Context, product *model.Product) error {
+	return h.update(ctx, product, 0)
+}
+
+// UpdateIfMatch updates product like Update but only if the version of the
+// stored Product is version.
+func (h *MongoProductBackend) UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error {
+	if err := checkVersion(product.ProductID, version); err != nil {
+		return err
+	}
+	return h.update(ctx, product, version)
+}
+
+func (h *MongoProductBackend) update(ctx context.Context, product *model.Product, version int64) error {
 	if product.ProductID == "" {
 		log.Error("Invalid productId", "productId", product.ProductID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
 	}
 	mp := createMProduct(product)
+	mp.UpdatedAt = backend.Now()
 
-	if err := h.session.DB("").C(productsCollection).Update(
-		&bson.M{"productId": mp.ProductID}, mp); err != nil {
+	var old mProduct
+	if err := h.write(ctx, mp.ProductID, version, func(c *mgo.Collection) error {
+		_, err := c.Find(selectorOf(mp.ProductID, version)).Apply(mgo.Change{
+			Update: &bson.M{
+				"$set": mp,
+				"$inc": bson.M{"version": 1},
+			},
+		}, &old)
+		return err
+	}); err != nil {
 		log.Error("Update failed ", "productId", mp.ProductID,
 			"err", err)
-		return pe.WithStack(err)
+		return err
 	}
+	h.keep(detached(ctx), &old, false)
 	log.Debug("Update succeeded", "productId", mp.ProductID)
 	return nil
 }
 
+// write runs f, which writes the document of productID selected by
+// selectorOf(productID, version). If nothing is selected and version is not
+// 0, the error tells whether the document doesn't exist or its version
+// doesn't match.
+func (h *MongoProductBackend) write(ctx context.Context, productID string, version int64, f func(c *mgo.Collection) error) error {
+	err := run(ctx, h.session, func(s *mgo.Session) error {
+		return f(s.DB("").C(productsCollection))
+	})
+	if pe.Cause(err) != backend.ErrNotFound || version == 0 {
+		return err
+	}
+	var n int
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		var err error
+		n, err = s.DB("").C(productsCollection).Find(
+			&bson.M{"productId": productID}).Count()
+		return err
+	}); err != nil {
+		return err
+	}
+	if n == 0 {
+		return err
+	}
+	return pe.Wrapf(backend.ErrPreconditionFailed, "version %d not matched",
+		version)
+}
+
 // UpdatePartial updates product. Success only if a Product with the same
 // ProductId existed. Return error if not existed.
-func (h *MongoProductBackend) UpdatePartial(productID string, kvs map[string]interface{}) error {
+func (h *MongoProductBackend) UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error {
+	return h.updatePartial(ctx, productID, kvs, 0)
+}
+
+// UpdatePartialIfMatch updates product like UpdatePartial but only if the
+// version of the stored Product is version.
+func (h *MongoProductBackend) UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
+	if err := checkVersion(productID, version); err != nil {
+		return err
+	}
+	return h.updatePartial(ctx, productID, kvs, version)
+}
+
+func (h *MongoProductBackend) updatePartial(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
 	if productID == "" {
 		log.Error("Invalid productId", "productId", productID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
 	}
 
 	if pid, ok := kvs["productId"]; ok && pid != productID {
 		log.Error("Different productId", "productId", productID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
-	}
-	// Sanity check(inefficient)
-	// TODO: reflect to check kvs matching names and types of product fields.
-
-	// Check if input has only keys in keyMap
-	keyMap := map[string]int8{
-		"productId": 1, "name": 1, "image_closed": 1, "image_open": 1,
-		"description": 1, "story": 1, "sourcing_values": 1, "ingredients": 1,
-		"allergy_info": 1, "dietary_certifications": 1,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
 	}
-	for k := range kvs {
-		if _, ok := keyMap[k]; !ok {
-			log.Error("Unknown extra field", "productId", productID,
-				"err", ErrParameters)
-			return pe.WithStack(ErrParameters)
-		}
+	// Check if kvs has only fields of Product, of their types
+	var product model.Product
+	if err := product.Patch(kvs); err != nil {
+		log.Error("Patch failed ", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
 	}
 
-	// Check if values of kvs fits types of fields of Product
-	bs, err := json.Marshal(kvs)
+	// Safe to update. Values are typed as they're stored, so that null is
+	// stored as the zero value rather than BSON null.
+	bs, err := bson.Marshal(createMProduct(&product))
 	if err != nil {
-		log.Error("json.Marshal failed ", "productId", productID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
+		log.Error("bson.Marshal failed ", "productId", productID,
+			"err", err)
+		return pe.WithStack(err)
 	}
-
-	var product model.Product
-	if err := json.Unmarshal(bs, &product); err != nil {
-		log.Error("json.Unmarshal failed ", "productId", productID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
+	var typed bson.M
+	if err := bson.Unmarshal(bs, &typed); err != nil {
+		log.Error("bson.Unmarshal failed ", "productId", productID,
+			"err", err)
+		return pe.WithStack(err)
 	}
-
-	// Safe to update
-	if err := h.session.DB("").C(productsCollection).Update(
-		&bson.M{"productId": productID}, bson.M{"$set": kvs}); err != nil {
+	sets := bson.M{"updated_at": backend.Now()}
+	for k := range kvs {
+		sets[k] = typed[k]
+	}
+	var old mProduct
+	if err := h.write(ctx, productID, version, func(c *mgo.Collection) error {
+		_, err := c.Find(selectorOf(productID, version)).Apply(mgo.Change{
+			Update: bson.M{
+				"$set": sets,
+				"$inc": bson.M{"version": 1},
+			},
+		}, &old)
+		return err
+	}); err != nil {
 		log.Error("Update failed ", "productId", productID,
 			"err", err)
-		return pe.WithStack(err)
+		return err
 	}
+	h.keep(detached(ctx), &old, false)
 	log.Debug("Update succeeded", "productId", productID)
 	return nil
 }
 
+// filterSelector translates filter to a selector of products, which is served
+// by indexes on the fields.
+func filterSelector(filter *model.Filter) bson.M {
+	selector := bson.M{}
+	if filter == nil {
+		return selector
+	}
+	fields := []struct {
+		name          string
+		with, without []string
+		// all is true for arrays, which must have every value of with.
+		all bool
+	}{
+		{"ingredients", filter.Ingredients, filter.WithoutIngredients, true},
+		{"sourcing_values", filter.SourcingValues,
+			filter.WithoutSourcingValues, true},
+		{"dietary_certifications", filter.DietaryCertifications,
+			filter.WithoutDietaryCertifications, false},
+	}
+	for _, f := range fields {
+		cond := bson.M{}
+		if len(f.with) != 0 && f.all {
+			cond["$all"] = f.with
+		} else if len(f.with) != 0 {
+			cond["$in"] = f.with
+		}
+		if len(f.without) != 0 {
+			cond["$nin"] = f.without
+		}
+		if len(cond) != 0 {
+			selector[f.name] = cond
+		}
+	}
+	if len(filter.FreeFrom) != 0 {
+		selector["allergens.contains"] = bson.M{"$nin": filter.FreeFrom}
+		selector["allergens.may_contain"] = bson.M{"$nin": filter.FreeFrom}
+	}
+	if filter.NamePrefix != "" {
+		// An anchored, case-sensitive regex is a range of the index.
+		selector["name"] = bson.RegEx{
+			Pattern: "^" + regexp.QuoteMeta(filter.NamePrefix),
+		}
+	}
+	return selector
+}
+
 // Read finds the Product with the given productID. Return error if not found.
-func (h *MongoProductBackend) Read(productID string) (*model.Product, error) {
+func (h *MongoProductBackend) Read(ctx context.Context, productID string) (*model.Product, error) {
 	if productID == "" {
 		log.Error("Invalid productId", "productId", productID,
-			"err", ErrParameters)
-		return nil, pe.WithStack(ErrParameters)
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
 	}
 	var mps = make([]mProduct, 0)
-	q := h.session.DB("").C(productsCollection).Find(
-		&bson.M{"productId": productID})
-	if err := q.All(&mps); err != nil {
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		q := s.DB("").C(productsCollection).Find(
+			&bson.M{"productId": productID})
+		return withMaxTime(ctx, q).All(&mps)
+	}); err != nil {
 		log.Error("Query.All failed", "productId", productID, "err", err)
 		return nil, pe.WithStack(err)
 	}
 	if len(mps) == 0 {
-		return nil, pe.WithStack(mgo.ErrNotFound)
+		return nil, pe.WithStack(backend.ErrNotFound)
 	} else if len(mps) > 1 {
 		// By design this should not happen. Most likely a duplicated product is
 		// added in an out-of-band fashion.
 		log.Error("Find gets more than 1", "productId", productID,
-			"err", ErrInconsistent)
-		return nil, pe.WithStack(ErrInconsistent)
+			"err", backend.ErrInconsistent)
+		return nil, pe.WithStack(backend.ErrInconsistent)
 	}
 	log.Debug(fmt.Sprintf("Find _id=%s", mps[0].ID.Hex()),
 		"productId", mps[0].ProductID)
@@ -246,32 +469,66 @@ func (h *MongoProductBackend) Read(productID string) (*model.Product, error) {
 // ReadMany reads a page of products. Cursor is from last ReadMany and
 // represents the end of the previous page. Limit is the number of Products that
 // will be returned in a page. If cursor is empty then ReadMany begins from the
-// first page. Limit must be larger than 0. Return error if no product read.
-func (h *MongoProductBackend) ReadMany(cursor string, limit int) (*model.Products, error) {
+// first page. Limit must be larger than 0. Filter, if not nil, selects
+// products read. Order sorts them, it must be the same for every page. The page
+// is empty if no more products are read, and only the last page has no cursor.
+func (h *MongoProductBackend) ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error) {
 	if limit <= 0 {
-		log.Error(fmt.Sprintf("Invalid limit:%d", limit), "err", ErrParameters)
-		return nil, pe.WithStack(ErrParameters)
-	}
-	selector := &bson.M{}
-	if cursor != "" && bson.IsObjectIdHex(cursor) {
-		objectID := bson.ObjectIdHex(cursor)
-		selector = &bson.M{
-			"_id": &bson.M{"$gt": objectID},
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	if !order.IsValid() {
+		log.Error("Invalid sort", "sort", order,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	field := order.Field()
+	op, sortBy := "$gt", []string{"_id"}
+	if field != "" {
+		sortBy = []string{field, "_id"}
+	}
+	if order.IsDescending() {
+		op, sortBy = "$lt", []string{"-" + field, "-_id"}
+	}
+	selector := filterSelector(filter)
+	if cursor != "" {
+		key, objectID, err := parseCursor(cursor, field)
+		if err != nil {
+			log.Error("Invalid cursor", "cursor", cursor, "err", err)
+			return nil, err
+		}
+		if field == "" {
+			selector["_id"] = &bson.M{op: objectID}
+		} else {
+			selector["$or"] = []bson.M{
+				{field: bson.M{op: key}},
+				{field: key, "_id": bson.M{op: objectID}},
+			}
 		}
 	}
+	// One more than limit tells if there's a next page.
 	var mps []mProduct
-	q := h.session.DB("").C(productsCollection).Find(selector).Limit(limit)
-	if err := q.All(&mps); err != nil {
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		q := s.DB("").C(productsCollection).Find(selector).Sort(sortBy...).
+			Limit(limit + 1)
+		return withMaxTime(ctx, q).All(&mps)
+	}); err != nil {
 		log.Error("Query.All failed", "from", cursor, "err", err)
 		return nil, pe.WithStack(err)
 	}
-	if len(mps) == 0 {
-		return nil, pe.WithStack(mgo.ErrNotFound)
-	}
 	ret := &model.Products{
-		Cursor:   mps[len(mps)-1].ID.Hex(),
 		Products: make([]model.Product, 0),
 	}
+	if len(mps) > limit {
+		mps = mps[:limit]
+		last := &mps[limit-1]
+		ret.Cursor = last.ID.Hex()
+		if field != "" {
+			ret.Cursor = backend.JoinCursor(
+				backend.SortKey(last.ToProduct(), field), ret.Cursor)
+		}
+	}
 	for _, mp := range mps {
 		ret.Products = append(ret.Products, *mp.ToProduct())
 	}
@@ -280,23 +537,350 @@ func (h *MongoProductBackend) ReadMany(cursor string, limit int) (*model.Product
 	return ret, nil
 }
 
-// Delete the Product with productID
-func (h *MongoProductBackend) Delete(productID string) error {
+// parseCursor parses a cursor of ReadMany sorted by field to the sort key and
+// ObjectId of the last product of the previous page. Timestamps as keys are
+// parsed as they're stored.
+func parseCursor(cursor string, field string) (interface{}, bson.ObjectId, error) {
+	var key interface{}
+	id := cursor
+	if field != "" {
+		k, i, ok := backend.SplitCursor(cursor)
+		if !ok {
+			return nil, "", pe.WithStack(backend.ErrInvalidArgument)
+		}
+		key, id = k, i
+		if field == "updated_at" {
+			ms, err := strconv.ParseInt(k, 10, 64)
+			if err != nil {
+				return nil, "", pe.WithStack(backend.ErrInvalidArgument)
+			}
+			key = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+		}
+	}
+	if !bson.IsObjectIdHex(id) {
+		return nil, "", pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return key, bson.ObjectIdHex(id), nil
+}
+
+// mHit is a product found by Search and its textScore.
+type mHit struct {
+	mProduct `bson:",inline"`
+	Score    float64 `bson:"score"`
+}
+
+// Count counts Products matching filter.
+func (h *MongoProductBackend) Count(ctx context.Context, filter *model.Filter) (int64, error) {
+	var n int
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		var err error
+		q := s.DB("").C(productsCollection).Find(filterSelector(filter))
+		n, err = withMaxTime(ctx, q).Count()
+		return err
+	}); err != nil {
+		log.Error("Query.Count failed", "err", err)
+		return 0, pe.WithStack(err)
+	}
+	return int64(n), nil
+}
+
+// Search finds Products matching any word of query in name, description, story
+// or ingredients, ordered by relevance. Cursor is from last Search of the same
+// query. Limit must be larger than 0. Return backend.ErrInvalidArgument if
+// query has no words to search. The page is empty if no more Products match.
+func (h *MongoProductBackend) Search(ctx context.Context, query string, cursor string, limit int) (*model.SearchResults, error) {
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	words := search.Words(query)
+	if len(words) == 0 {
+		log.Error("Invalid query", "query", query,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	// The cursor is the number of hits read. An unrecognized one reads from
+	// the first page.
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	// Only words are given to $search so that none is taken as a phrase or a
+	// negation. One more than limit tells if there's a next page.
+	var mhs []mHit
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		q := s.DB("").C(productsCollection).Find(bson.M{
+			"$text": bson.M{"$search": strings.Join(words, " ")},
+		}).Select(bson.M{"score": bson.M{"$meta": "textScore"}}).
+			Sort("$textScore:score", "productId").Skip(offset).
+			Limit(limit + 1)
+		return withMaxTime(ctx, q).All(&mhs)
+	}); err != nil {
+		log.Error("Query.All failed", "query", query, "err", err)
+		return nil, pe.WithStack(err)
+	}
+	ret := &model.SearchResults{
+		Hits: make([]model.SearchHit, 0),
+	}
+	if len(mhs) > limit {
+		mhs = mhs[:limit]
+		ret.Cursor = strconv.Itoa(offset + limit)
+	}
+	terms := search.Terms(query)
+	for _, mh := range mhs {
+		product := mh.ToProduct()
+		ret.Hits = append(ret.Hits, model.SearchHit{
+			Product:    *product,
+			Score:      mh.Score,
+			Highlights: search.Highlight(product, terms),
+		})
+	}
+	log.Debug("Search succeeded", "query", query, "count", len(mhs),
+		"from", cursor, "to", ret.Cursor)
+	return ret, nil
+}
+
+// Delete moves the Product with productID to the trash, where it replaces one
+// trashed earlier with the same productID.
+func (h *MongoProductBackend) Delete(ctx context.Context, productID string) error {
+	return h.delete(ctx, productID, 0)
+}
+
+// DeleteIfMatch deletes the Product with productID only if its version is
+// version.
+func (h *MongoProductBackend) DeleteIfMatch(ctx context.Context, productID string, version int64) error {
+	if err := checkVersion(productID, version); err != nil {
+		return err
+	}
+	return h.delete(ctx, productID, version)
+}
+
+func (h *MongoProductBackend) delete(ctx context.Context, productID string, version int64) error {
 	if productID == "" {
 		log.Error("Invalid productId", "productId", productID,
-			"err", ErrParameters)
-		return pe.WithStack(ErrParameters)
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
 	}
-	if err := h.session.DB("").C(productsCollection).Remove(&bson.M{
-		"productId": productID,
+	var old mProduct
+	if err := h.write(ctx, productID, version, func(c *mgo.Collection) error {
+		_, err := c.Find(selectorOf(productID, version)).Apply(mgo.Change{
+			Remove: true,
+		}, &old)
+		return err
 	}); err != nil {
 		log.Error("Remove failed", "productId", productID, "err", err)
+		return err
+	}
+	// Trashed before anything else, and put back if it can't be, so that the
+	// removed product is not lost.
+	if err := run(detached(ctx), h.session, func(s *mgo.Session) error {
+		_, err := s.DB("").C(trashedCollection).UpsertId(productID,
+			&mTrashed{
+				ProductID: productID,
+				Product:   old,
+				DeletedAt: backend.Now(),
+			})
+		return err
+	}); err != nil {
+		log.Error("Trash failed", "productId", productID, "err", err)
+		if err := run(detached(ctx), h.session, func(s *mgo.Session) error {
+			return s.DB("").C(productsCollection).Insert(&old)
+		}); err != nil {
+			log.Error("Put back failed", "productId", productID,
+				"product", old, "err", err)
+		}
 		return pe.WithStack(err)
 	}
+	h.keep(detached(ctx), &old, true)
 	log.Debug("Remove succeeded", "productId", productID)
 	return nil
 }
 
+// keep keeps old, the document superseded by a write of ctx, as a revision.
+// The write has succeeded even if keeping fails, in which case the revision is
+// missed and only logged.
+func (h *MongoProductBackend) keep(ctx context.Context, old *mProduct, deleted bool) {
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		var seq struct {
+			Seq int64 `bson:"seq"`
+		}
+		if _, err := s.DB("").C(revisionSeqsCollection).FindId(
+			old.ProductID).Apply(mgo.Change{
+			Update:    bson.M{"$inc": bson.M{"seq": 1}},
+			Upsert:    true,
+			ReturnNew: true,
+		}, &seq); err != nil {
+			return err
+		}
+		return s.DB("").C(revisionsCollection).Insert(&mRevision{
+			ProductID: old.ProductID,
+			Revision:  seq.Seq,
+			Product:   *old,
+			Deleted:   deleted,
+			APIKey:    backend.APIKeyFrom(ctx),
+			RevisedAt: backend.Now(),
+		})
+	}); err != nil {
+		log.Error("Keep revision failed", "productId", old.ProductID,
+			"version", old.Version, "err", err)
+	}
+}
+
+// ReadRevisions reads revisions of the Product with productID, which are empty
+// if the Product has never been superseded.
+func (h *MongoProductBackend) ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error) {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	var mrs []mRevision
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		q := s.DB("").C(revisionsCollection).Find(
+			&bson.M{"productId": productID}).Sort("revision")
+		return withMaxTime(ctx, q).All(&mrs)
+	}); err != nil {
+		log.Error("Query.All failed", "productId", productID, "err", err)
+		return nil, pe.WithStack(err)
+	}
+	ret := &model.Revisions{
+		Revisions: make([]model.Revision, 0),
+	}
+	for _, mr := range mrs {
+		ret.Revisions = append(ret.Revisions, *mr.ToRevision())
+	}
+	log.Debug("ReadRevisions succeeded", "productId", productID,
+		"count", len(mrs))
+	return ret, nil
+}
+
+// ReadRevision reads the revision of the Product with productID. Return error
+// if not found.
+func (h *MongoProductBackend) ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error) {
+	if productID == "" || revision <= 0 {
+		log.Error(fmt.Sprintf("Invalid revision:%d", revision),
+			"productId", productID, "err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	var mr mRevision
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		q := s.DB("").C(revisionsCollection).Find(&bson.M{
+			"productId": productID,
+			"revision":  revision,
+		})
+		return withMaxTime(ctx, q).One(&mr)
+	}); err != nil {
+		log.Error("Query.One failed", "productId", productID,
+			"revision", revision, "err", err)
+		return nil, pe.WithStack(err)
+	}
+	return mr.ToRevision(), nil
+}
+
+// ReadTrash reads a page of trashed products ordered by productId. Cursor is
+// from last ReadTrash. Limit must be larger than 0. The cursor of the last
+// page is empty.
+func (h *MongoProductBackend) ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error) {
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	var mts []mTrashed
+	// One more than limit tells if there's a next page.
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		q := s.DB("").C(trashedCollection).Find(
+			&bson.M{"_id": &bson.M{"$gt": cursor}}).Sort("_id").
+			Limit(limit + 1)
+		return withMaxTime(ctx, q).All(&mts)
+	}); err != nil {
+		log.Error("Query.All failed", "from", cursor, "err", err)
+		return nil, pe.WithStack(err)
+	}
+	ret := &model.Trash{
+		Products: make([]model.TrashedProduct, 0),
+	}
+	if len(mts) > limit {
+		mts = mts[:limit]
+		ret.Cursor = mts[limit-1].ProductID
+	}
+	for _, mt := range mts {
+		ret.Products = append(ret.Products, *mt.ToTrashedProduct())
+	}
+	log.Debug("ReadTrash succeeded", "count", len(mts), "from", cursor,
+		"to", ret.Cursor)
+	return ret, nil
+}
+
+// RestoreTrashed moves the trashed Product with productID back, as a new
+// version. Return error if it's not trashed or a Product with the same
+// productID has been created since.
+func (h *MongoProductBackend) RestoreTrashed(ctx context.Context, productID string) error {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	err := run(ctx, h.session, func(s *mgo.Session) error {
+		var mt mTrashed
+		if err := s.DB("").C(trashedCollection).FindId(productID).One(
+			&mt); err != nil {
+			return err
+		}
+		// _id is kept so that the product is where it was in pages. The
+		// unique index of productId fails it if the productId is taken.
+		mp := mt.Product
+		mp.Version++
+		mp.UpdatedAt = backend.Now()
+		if err := s.DB("").C(productsCollection).Insert(&mp); err != nil {
+			return err
+		}
+		return s.DB("").C(trashedCollection).RemoveId(productID)
+	})
+	if err != nil {
+		log.Error("Restore failed", "productId", productID, "err", err)
+		return pe.WithStack(err)
+	}
+	log.Debug("Restore succeeded", "productId", productID)
+	return nil
+}
+
+// PurgeTrashed permanently deletes the trashed Product with productID. Return
+// error if it's not trashed.
+func (h *MongoProductBackend) PurgeTrashed(ctx context.Context, productID string) error {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		return s.DB("").C(trashedCollection).RemoveId(productID)
+	}); err != nil {
+		log.Error("Purge failed", "productId", productID, "err", err)
+		return pe.WithStack(err)
+	}
+	log.Debug("Purge succeeded", "productId", productID)
+	return nil
+}
+
+// PurgeTrashedBefore permanently deletes Products trashed before t and returns
+// the number of them.
+func (h *MongoProductBackend) PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error) {
+	var info *mgo.ChangeInfo
+	if err := run(ctx, h.session, func(s *mgo.Session) error {
+		var err error
+		info, err = s.DB("").C(trashedCollection).RemoveAll(
+			&bson.M{"deleted_at": &bson.M{"$lt": t}})
+		return err
+	}); err != nil {
+		log.Error("Purge failed", "before", t, "err", err)
+		return 0, pe.WithStack(err)
+	}
+	log.Debug("Purge succeeded", "before", t, "count", info.Removed)
+	return info.Removed, nil
+}
+
 // CreateMongoProductBackend creates MongoProductBackend
 func CreateMongoProductBackend(session *mgo.Session) (*MongoProductBackend, error) {
 	return &MongoProductBackend{
@@ -0,0 +1,60 @@
+package mongodb
+
+import (
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend/backendtest"
+	"github.com/globalsign/mgo"
+	"os"
+	"testing"
+	"time"
+)
+
+// testMongoEnv is the host:port of a mongoDB for tests. Tests are skipped if
+// it's not set, e.g.
+// ICECREAM_TEST_MONGO=127.0.0.1:27017 go test ./pkg/backend/mongodb/
+const testMongoEnv = "ICECREAM_TEST_MONGO"
+
+// dialTestDB dials a database used only by the calling test and returns a
+// function to drop it.
+func dialTestDB(t *testing.T) (*mgo.Session, func()) {
+	addr := os.Getenv(testMongoEnv)
+	if addr == "" {
+		t.Skip(testMongoEnv + " is not set")
+	}
+	database := fmt.Sprintf("icecream_test_%d", time.Now().UnixNano())
+	session, err := mgo.Dial(fmt.Sprintf("mongodb://%s/%s", addr, database))
+	if err != nil {
+		t.Fatalf("mgo.Dial failed, %s", err)
+	}
+	return session, func() {
+		session.DB("").DropDatabase()
+		session.Close()
+	}
+}
+
+func TestMongoProductBackend_Conformance(t *testing.T) {
+	backendtest.TestProductBackend(t, func(t *testing.T) (backendtest.ProductBackend, func()) {
+		session, drop := dialTestDB(t)
+		if err := EnsureIndexes(session); err != nil {
+			drop()
+			t.Fatalf("EnsureIndexes failed, %s", err)
+		}
+		b, _ := CreateMongoProductBackend(session)
+		return b, drop
+	})
+}
+
+func TestMongoAPIKeyBackend_Conformance(t *testing.T) {
+	backendtest.TestAPIKeyBackend(t, func(t *testing.T, apiKeys []string) (backendtest.APIKeyBackend, func()) {
+		session, drop := dialTestDB(t)
+		for _, k := range apiKeys {
+			if err := session.DB("").C(apiKeysCollection).Insert(
+				&mAPIKey{APIKey: k}); err != nil {
+				drop()
+				t.Fatalf("Insert failed, %s", err)
+			}
+		}
+		b, _ := CreateMongoAPIKeyBackend(session)
+		return b, drop
+	})
+}
@@ -0,0 +1,75 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/globalsign/mgo"
+	pe "github.com/pkg/errors"
+	"time"
+)
+
+// run calls f with a copy of session so that every call gets its own socket.
+// The deadline of ctx bounds the socket and f is abandoned once ctx is done.
+// Errors of f are translated to the ones in package backend.
+//
+// Abandoning doesn't stop f. A write f has issued may still succeed after run
+// returns ctx.Err(). Steps following the first one of a write therefore run
+// with detached(ctx), so that they're not skipped halfway.
+func run(ctx context.Context, session *mgo.Session, f func(s *mgo.Session) error) error {
+	if err := ctx.Err(); err != nil {
+		return pe.WithStack(err)
+	}
+	s := session.Copy()
+	if timeout, ok := timeoutOf(ctx); ok {
+		if timeout <= 0 {
+			s.Close()
+			return pe.WithStack(context.DeadlineExceeded)
+		}
+		s.SetSyncTimeout(timeout)
+		s.SetSocketTimeout(timeout)
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer s.Close()
+		done <- f(s)
+	}()
+	select {
+	case err := <-done:
+		return translate(err)
+	case <-ctx.Done():
+		log.Warn("Abandon the call", "err", ctx.Err())
+		return pe.WithStack(ctx.Err())
+	}
+}
+
+// timeoutOf returns the time left until the deadline of ctx if it has one.
+func timeoutOf(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// withMaxTime lets the server abort q if it runs past the deadline of ctx.
+func withMaxTime(ctx context.Context, q *mgo.Query) *mgo.Query {
+	if timeout, ok := timeoutOf(ctx); ok && timeout > 0 {
+		return q.SetMaxTime(timeout)
+	}
+	return q
+}
+
+// detachedContext has the values of Context but is never done.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// detached returns a context of the values of ctx, e.g. the API key, which is
+// not done when ctx is. Calls run with it are bounded by the socket timeout of
+// session instead.
+func detached(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
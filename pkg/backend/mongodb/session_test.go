@@ -0,0 +1,22 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetached(t *testing.T) {
+	ctx, cancel := context.WithTimeout(
+		backend.WithAPIKey(context.Background(), "testkey"), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	d := detached(ctx)
+	assert.NoError(t, d.Err())
+	assert.Nil(t, d.Done())
+	_, ok := d.Deadline()
+	assert.False(t, ok)
+	assert.Equal(t, "testkey", backend.APIKeyFrom(d))
+}
@@ -0,0 +1,8 @@
+/*
+Package search analyzes text of Products for backends to search them. Text is
+split into words which are lowercased and stemmed into terms, and fields are
+weighted so that a term in name counts more than one in story. Index is an
+inverted index of terms for backends without one of their own, and Highlight
+makes snippets of matched fields.
+*/
+package search
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"bytes"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"html"
+)
+
+// snippetWords is the max number of words in a snippet.
+const snippetWords = 24
+
+// Highlight makes snippets of fields of product having any of terms, keyed
+// by names of fields in json. Matched words are enclosed by <em> and </em>,
+// while the rest is escaped as HTML. A snippet is a few words before the
+// first matched one and some after. Ellipses mark where text is cut.
+func Highlight(product *model.Product, terms []string) map[string]string {
+	set := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		set[term] = struct{}{}
+	}
+	highlights := make(map[string]string)
+	for field, text := range Fields(product) {
+		if s, ok := snippet(text, set); ok {
+			highlights[field] = s
+		}
+	}
+	return highlights
+}
+
+func snippet(text string, terms map[string]struct{}) (string, bool) {
+	spans := words(text)
+	first := -1
+	matched := make([]bool, len(spans))
+	for i, s := range spans {
+		if _, ok := terms[Term(text[s.start:s.end])]; ok {
+			matched[i] = true
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+	start := first - snippetWords/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(spans) {
+		end = len(spans)
+	}
+
+	var buf bytes.Buffer
+	pos := spans[start].start
+	if start > 0 {
+		buf.WriteString("…")
+	} else {
+		pos = 0
+	}
+	for i := start; i < end; i++ {
+		s := spans[i]
+		buf.WriteString(html.EscapeString(text[pos:s.start]))
+		word := html.EscapeString(text[s.start:s.end])
+		if matched[i] {
+			word = "<em>" + word + "</em>"
+		}
+		buf.WriteString(word)
+		pos = s.end
+	}
+	if end < len(spans) {
+		buf.WriteString("…")
+	} else {
+		buf.WriteString(html.EscapeString(text[pos:]))
+	}
+	return buf.String(), true
+}
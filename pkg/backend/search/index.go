@@ -0,0 +1,80 @@
+package search
+
+import (
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"sort"
+)
+
+// Hit is a productId matching a search and its score.
+type Hit struct {
+	ProductID string
+	Score     float64
+}
+
+// Index is an inverted index of terms of Products. It's not safe for
+// concurrent use.
+type Index struct {
+	// postings are weights of terms keyed by term and productId.
+	postings map[string]map[string]float64
+	// terms are what productIds are indexed by, for removing them.
+	terms map[string][]string
+}
+
+// Add indexes product, replacing what's indexed by its productId.
+func (ix *Index) Add(product *model.Product) {
+	ix.Remove(product.ProductID)
+	weights := Weigh(product)
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		postings, ok := ix.postings[term]
+		if !ok {
+			postings = make(map[string]float64)
+			ix.postings[term] = postings
+		}
+		postings[product.ProductID] = weight
+		terms = append(terms, term)
+	}
+	ix.terms[product.ProductID] = terms
+}
+
+// Remove removes what's indexed by productID.
+func (ix *Index) Remove(productID string) {
+	for _, term := range ix.terms[productID] {
+		postings := ix.postings[term]
+		delete(postings, productID)
+		if len(postings) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.terms, productID)
+}
+
+// Search finds productIds indexed by any of terms. They're ordered by score
+// from the highest, then by productId.
+func (ix *Index) Search(terms []string) []Hit {
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		for productID, weight := range ix.postings[term] {
+			scores[productID] += weight
+		}
+	}
+	hits := make([]Hit, 0, len(scores))
+	for productID, score := range scores {
+		hits = append(hits, Hit{ProductID: productID, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ProductID < hits[j].ProductID
+	})
+	return hits
+}
+
+// CreateIndex creates an empty Index
+func CreateIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]float64),
+		terms:    make(map[string][]string),
+	}
+}
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"strings"
+	"unicode"
+)
+
+// Weights of fields searched, keyed by their names in json.
+var Weights = map[string]int{
+	"name":        10,
+	"ingredients": 5,
+	"description": 2,
+	"story":       1,
+}
+
+// stopWords are too common to be terms.
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "in": {}, "of": {}, "on": {}, "or": {},
+	"the": {}, "to": {}, "with": {},
+}
+
+// Fields returns text of fields searched, keyed by their names in json.
+// Ingredients are joined by commas.
+func Fields(product *model.Product) map[string]string {
+	return map[string]string{
+		"name":        product.Name,
+		"ingredients": strings.Join(product.Ingredients, ", "),
+		"description": product.Description,
+		"story":       product.Story,
+	}
+}
+
+// span is where a word is in text.
+type span struct {
+	start, end int
+}
+
+// words finds words of letters and digits in text.
+func words(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+// Term returns the term of word, or "" if it's a stop word.
+func Term(word string) string {
+	w := strings.ToLower(word)
+	if _, ok := stopWords[w]; ok {
+		return ""
+	}
+	return stem(w)
+}
+
+// stem strips a few English suffixes so that e.g. "cookies" and "cookie", or
+// "cherries" and "cherry", are the same term. It's deliberately light.
+func stem(w string) string {
+	if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+		w = w[:len(w)-1]
+	}
+	if len(w) > 3 && strings.HasSuffix(w, "e") {
+		w = w[:len(w)-1]
+	}
+	if len(w) > 3 && strings.HasSuffix(w, "y") {
+		w = w[:len(w)-1] + "i"
+	}
+	return w
+}
+
+// Words returns words of text except stop words, for backends analyzing text
+// by themselves.
+func Words(text string) []string {
+	var ws []string
+	for _, s := range words(text) {
+		if Term(text[s.start:s.end]) != "" {
+			ws = append(ws, text[s.start:s.end])
+		}
+	}
+	return ws
+}
+
+// Terms returns distinct terms of text in the order they appear.
+func Terms(text string) []string {
+	var terms []string
+	seen := make(map[string]struct{})
+	for _, s := range words(text) {
+		term := Term(text[s.start:s.end])
+		if term == "" {
+			continue
+		}
+		if _, ok := seen[term]; !ok {
+			seen[term] = struct{}{}
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Weigh returns terms of product and their weights. A term weighs the sum of
+// Weights of fields where it appears, once for every appearance. The score of
+// product for a search is the sum of weights of terms searched.
+func Weigh(product *model.Product) map[string]float64 {
+	weights := make(map[string]float64)
+	for field, text := range Fields(product) {
+		for _, s := range words(text) {
+			if term := Term(text[s.start:s.end]); term != "" {
+				weights[term] += float64(Weights[field])
+			}
+		}
+	}
+	return weights
+}
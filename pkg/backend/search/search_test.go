@@ -0,0 +1,56 @@
+package search
+
+import (
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"cooki", "swirl"}, Terms("Cookie Swirls"))
+	assert.Equal(t, []string{"cooki", "swirl"}, Terms("cookies and swirl"))
+	assert.Equal(t, []string{"cherri", "garcia"}, Terms("Cherry Garcia cherries"))
+	assert.Equal(t, []string{"glass"}, Terms("the glass"))
+	assert.Empty(t, Terms("and, the!"))
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := CreateIndex()
+	ix.Add(&model.Product{ProductID: "001", Name: "Cookie Dough",
+		Story: "swirls of cookies"})
+	ix.Add(&model.Product{ProductID: "002", Name: "Cheesecake",
+		Ingredients: []string{"cookie pieces"}})
+	ix.Add(&model.Product{ProductID: "003", Name: "Cookie Swirl"})
+
+	assert.Equal(t, []Hit{
+		{"003", 20}, {"001", 12}, {"002", 5},
+	}, ix.Search(Terms("cookie swirl")))
+	assert.Empty(t, ix.Search(Terms("fudge")))
+
+	// Replaced
+	ix.Add(&model.Product{ProductID: "003", Name: "Fudge"})
+	assert.Equal(t, []Hit{{"003", 10}}, ix.Search(Terms("fudge")))
+	assert.Equal(t, []Hit{{"001", 1}}, ix.Search(Terms("swirl")))
+
+	ix.Remove("003")
+	assert.Empty(t, ix.Search(Terms("fudge")))
+	assert.Empty(t, ix.postings["fudg"])
+}
+
+func TestHighlight(t *testing.T) {
+	product := &model.Product{
+		Name:        "Cookie <Swirl>",
+		Ingredients: []string{"cream", "cookies"},
+		Story: strings.Repeat("word ", 10) + "a cookie" +
+			strings.Repeat(" word", 30) + ".",
+	}
+	highlights := Highlight(product, Terms("cookie"))
+	assert.Equal(t, map[string]string{
+		"name":        "<em>Cookie</em> &lt;Swirl&gt;",
+		"ingredients": "cream, <em>cookies</em>",
+		"story": "…word word word word word a <em>cookie</em>" +
+			strings.Repeat(" word", 17) + "…",
+	}, highlights)
+	assert.Empty(t, Highlight(product, Terms("fudge")))
+}
@@ -0,0 +1,49 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/cfchou/icecream/pkg/backend"
+	pe "github.com/pkg/errors"
+)
+
+// SQLAPIKeyBackend stores a *sql.DB for retrieving APIKey.
+type SQLAPIKeyBackend struct {
+	db *sql.DB
+}
+
+// Authenticate checks if apiKey is stored in the db.
+func (h *SQLAPIKeyBackend) Authenticate(ctx context.Context, apiKey string) error {
+	var key string
+	err := h.db.QueryRowContext(ctx, `SELECT apikey FROM apikeys WHERE apikey = ?`,
+		apiKey).Scan(&key)
+	if err == sql.ErrNoRows {
+		return pe.WithStack(backend.ErrNotFound)
+	} else if err != nil {
+		log.Error("QueryRow failed", "err", err)
+		return translate(pe.WithStack(err))
+	}
+	log.Debug("Find apikey")
+	return nil
+}
+
+// Add stores apiKey. Adding an existing apiKey is a no-op.
+func (h *SQLAPIKeyBackend) Add(apiKey string) error {
+	if apiKey == "" {
+		log.Error("Invalid apikey", "err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	if _, err := h.db.Exec(`INSERT OR IGNORE INTO apikeys (apikey) VALUES (?)`,
+		apiKey); err != nil {
+		log.Error("Add failed", "err", err)
+		return translate(pe.WithStack(err))
+	}
+	return nil
+}
+
+// CreateSQLAPIKeyBackend creates SQLAPIKeyBackend
+func CreateSQLAPIKeyBackend(db *sql.DB) (*SQLAPIKeyBackend, error) {
+	return &SQLAPIKeyBackend{
+		db: db,
+	}, nil
+}
@@ -0,0 +1,11 @@
+/*
+Package sql is backend implementing various operations against Product and
+APIKey on top of database/sql. A Product is stored in the table products while
+its sourcing_values and ingredients are stored in child tables, keeping their
+order by position. Terms of a Product weighed by package search are stored in
+product_terms for Search.
+
+The statements are written for SQLite. The caller opens *sql.DB with a driver
+of choice and calls CreateSchema before creating backends.
+*/
+package sql
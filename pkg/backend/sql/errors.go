@@ -0,0 +1,41 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/cfchou/icecream/pkg/backend"
+	pe "github.com/pkg/errors"
+	"strings"
+)
+
+// isUnavailable is true if err is caused by a lost connection or a database
+// locked by another writer.
+func isUnavailable(err error) bool {
+	if err == driver.ErrBadConn || err == sql.ErrConnDone {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked")
+}
+
+// translate converts errors of database/sql and the driver to the ones in
+// package backend. Other errors are returned as they are. Errors of the driver
+// are told by messages since the driver is of the caller's choice.
+func translate(err error) error {
+	cause := pe.Cause(err)
+	switch {
+	case cause == nil:
+		return nil
+	case cause == context.Canceled || cause == context.DeadlineExceeded:
+		return err
+	case cause == sql.ErrNoRows:
+		return pe.WithStack(backend.ErrNotFound)
+	case strings.Contains(cause.Error(), "UNIQUE constraint failed"):
+		return pe.Wrap(backend.ErrAlreadyExists, cause.Error())
+	case isUnavailable(cause):
+		return pe.Wrap(backend.ErrUnavailable, cause.Error())
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	anyErr := fmt.Errorf("any error")
+	cases := []struct {
+		err   error
+		cause error
+	}{
+		{nil, nil},
+		{sql.ErrNoRows, backend.ErrNotFound},
+		{fmt.Errorf("UNIQUE constraint failed: products.product_id"),
+			backend.ErrAlreadyExists},
+		{driver.ErrBadConn, backend.ErrUnavailable},
+		{fmt.Errorf("database is locked"), backend.ErrUnavailable},
+		{context.DeadlineExceeded, context.DeadlineExceeded},
+		{anyErr, anyErr},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.cause, pe.Cause(translate(c.err)), "err:%v", c.err)
+		if c.err != nil {
+			assert.Equal(t, c.cause, pe.Cause(translate(pe.WithStack(c.err))),
+				"err:%v", c.err)
+		}
+	}
+}
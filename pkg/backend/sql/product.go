@@ -0,0 +1,1254 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/cfchou/icecream/pkg/backend/search"
+	"github.com/inconshreveable/log15"
+	pe "github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var log = log15.New("module", "backend.sql")
+
+const productColumns = `id, product_id, name, image_closed, image_open,
+	description, story, allergy_info, dietary_certifications, version,
+	created_at, updated_at`
+
+// columns maps fields of Product in json to columns of the table products.
+// Fields stored in child tables are mapped to the tables.
+var (
+	columns = map[string]string{
+		"productId": "product_id", "name": "name",
+		"image_closed": "image_closed", "image_open": "image_open",
+		"description": "description", "story": "story",
+		"allergy_info":           "allergy_info",
+		"dietary_certifications": "dietary_certifications",
+	}
+	childTables = map[string]string{
+		"sourcing_values": "product_sourcing_values",
+		"ingredients":     "product_ingredients",
+	}
+)
+
+// sortColumns maps fields Products can be sorted by to columns.
+var sortColumns = map[string]string{
+	"name":       "name",
+	"productId":  "product_id",
+	"updated_at": "updated_at",
+}
+
+// row is either *sql.Row or *sql.Rows
+type row interface {
+	Scan(dest ...interface{}) error
+}
+
+// toMillis converts t to milliseconds since epoch as timestamps are stored.
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// fromMillis converts a stored timestamp. 0 is the zero time.
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+func scanProduct(r row) (int64, *model.Product, error) {
+	var id, createdAt, updatedAt int64
+	var p model.Product
+	err := r.Scan(&id, &p.ProductID, &p.Name, &p.ImageClosed, &p.ImageOpen,
+		&p.Description, &p.Story, &p.AllergyInfo, &p.DietaryCertifications,
+		&p.Version, &createdAt, &updatedAt)
+	if err != nil {
+		return 0, nil, err
+	}
+	p.CreatedAt = fromMillis(createdAt)
+	p.UpdatedAt = fromMillis(updatedAt)
+	p.SourcingValues = make([]string, 0)
+	p.Ingredients = make([]string, 0)
+	p.Allergens.Contains = make([]model.Allergen, 0)
+	p.Allergens.MayContain = make([]model.Allergen, 0)
+	return id, &p, nil
+}
+
+const revisionColumns = `revision, version, product, deleted, apikey,
+	revised_at`
+
+func scanRevision(r row) (*model.Revision, error) {
+	var product string
+	var revisedAt int64
+	var rev model.Revision
+	if err := r.Scan(&rev.Revision, &rev.Version, &product, &rev.Deleted,
+		&rev.APIKey, &revisedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(product), &rev.Product); err != nil {
+		return nil, err
+	}
+	rev.Product.Version = rev.Version
+	// Revisions kept before it's derived don't have it.
+	rev.Product.StructuredIngredients = model.ParseIngredients(
+		rev.Product.Ingredients)
+	rev.RevisedAt = fromMillis(revisedAt)
+	return &rev, nil
+}
+
+// SQLProductBackend stores a *sql.DB to support CRUD for Product.
+type SQLProductBackend struct {
+	db *sql.DB
+}
+
+// Close closes the internal *sql.DB.
+func (h *SQLProductBackend) Close() {
+	h.db.Close()
+}
+
+// withTx runs f in a transaction. The transaction is committed if f succeeds,
+// otherwise it's rolled back.
+func (h *SQLProductBackend) withTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Begin failed", "err", err)
+		return translate(pe.WithStack(err))
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return translate(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error("Commit failed", "err", err)
+		return translate(pe.WithStack(err))
+	}
+	return nil
+}
+
+func findID(ctx context.Context, tx *sql.Tx, productID string) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM products WHERE product_id = ?`,
+		productID).Scan(&id)
+	return id, err
+}
+
+// checkVersion checks version given to conditional writes.
+func checkVersion(productID string, version int64) error {
+	if version <= 0 {
+		log.Error(fmt.Sprintf("Invalid version:%d", version),
+			"productId", productID, "err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return nil
+}
+
+// findMatched finds the id of productID like findID. If version is not 0, it
+// must match the version of the product.
+func findMatched(ctx context.Context, tx *sql.Tx, productID string, version int64) (int64, error) {
+	var id, current int64
+	err := tx.QueryRowContext(ctx,
+		`SELECT id, version FROM products WHERE product_id = ?`,
+		productID).Scan(&id, &current)
+	if err != nil {
+		return 0, err
+	}
+	if version != 0 && current != version {
+		return 0, pe.Wrapf(backend.ErrPreconditionFailed,
+			"version %d, expected %d", current, version)
+	}
+	return id, nil
+}
+
+func insertProduct(ctx context.Context, tx *sql.Tx, product *model.Product) (int64, error) {
+	now := toMillis(backend.Now())
+	res, err := tx.ExecContext(ctx, `INSERT INTO products (product_id, name, image_closed,
+		image_open, description, story, allergy_info, dietary_certifications,
+		version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)`,
+		product.ProductID, product.Name, product.ImageClosed,
+		product.ImageOpen, product.Description, product.Story,
+		product.AllergyInfo, product.DietaryCertifications, now, now)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := replaceChildren(ctx, tx, id, product); err != nil {
+		return 0, err
+	}
+	if err := indexTerms(ctx, tx, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func replaceProduct(ctx context.Context, tx *sql.Tx, id int64, product *model.Product) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE products SET name = ?, image_closed = ?,
+		image_open = ?, description = ?, story = ?, allergy_info = ?,
+		dietary_certifications = ?, version = version + 1, updated_at = ?
+		WHERE id = ?`,
+		product.Name, product.ImageClosed, product.ImageOpen,
+		product.Description, product.Story, product.AllergyInfo,
+		product.DietaryCertifications, toMillis(backend.Now()),
+		id); err != nil {
+		return err
+	}
+	if err := replaceChildren(ctx, tx, id, product); err != nil {
+		return err
+	}
+	return indexTerms(ctx, tx, id)
+}
+
+func replaceChildren(ctx context.Context, tx *sql.Tx, id int64, product *model.Product) error {
+	if err := replaceChild(ctx, tx, childTables["sourcing_values"], id,
+		product.SourcingValues); err != nil {
+		return err
+	}
+	if err := replaceChild(ctx, tx, childTables["ingredients"], id,
+		product.Ingredients); err != nil {
+		return err
+	}
+	return replaceAllergens(ctx, tx, id, &product.Allergens)
+}
+
+func replaceAllergens(ctx context.Context, tx *sql.Tx, id int64, allergens *model.Allergens) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM product_allergens WHERE product_id = ?`, id); err != nil {
+		return err
+	}
+	// may_contain is the index of the list.
+	for i, list := range [][]model.Allergen{allergens.Contains,
+		allergens.MayContain} {
+		for _, allergen := range list {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO product_allergens
+				(product_id, allergen, may_contain) VALUES (?, ?, ?)`,
+				id, string(allergen), i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func replaceChild(ctx context.Context, tx *sql.Tx, table string, id int64, values []string) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE product_id = ?`,
+		table), id); err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf(`INSERT INTO %s (product_id, position, value)
+		VALUES (?, ?, ?)`, table)
+	for i, v := range values {
+		if _, err := tx.ExecContext(ctx, stmt, id, i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexTerms replaces terms of the product of id, which are searched by
+// Search.
+func indexTerms(ctx context.Context, tx *sql.Tx, id int64) error {
+	product, err := readProduct(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM product_terms WHERE product_id = ?`, id); err != nil {
+		return err
+	}
+	for term, weight := range search.Weigh(product) {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO product_terms
+			(product_id, term, weight) VALUES (?, ?, ?)`,
+			id, term, weight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readProduct(ctx context.Context, tx *sql.Tx, id int64) (*model.Product, error) {
+	_, product, err := scanProduct(tx.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT %s FROM products WHERE id = ?`, productColumns), id))
+	if err != nil {
+		return nil, err
+	}
+	if err := loadChildren(ctx, tx, map[int64]*model.Product{
+		id: product,
+	}); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// keepRevision keeps the product of id as a revision before it's superseded
+// by a write of ctx.
+func keepRevision(ctx context.Context, tx *sql.Tx, id int64, deleted bool) error {
+	product, err := readProduct(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	bs, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO product_revisions (product_id,
+		revision, version, product, deleted, apikey, revised_at)
+		SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ?, ?
+		FROM product_revisions WHERE product_id = ?`,
+		product.ProductID, product.Version, string(bs), deleted,
+		backend.APIKeyFrom(ctx), toMillis(backend.Now()), product.ProductID)
+	return err
+}
+
+// trashProduct copies the product of id to the trash, replacing one trashed
+// earlier with the same productId.
+func trashProduct(ctx context.Context, tx *sql.Tx, id int64) error {
+	product, err := readProduct(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	bs, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO trashed_products
+		(product_id, id, version, product, deleted_at) VALUES (?, ?, ?, ?, ?)`,
+		product.ProductID, id, product.Version, string(bs),
+		toMillis(backend.Now()))
+	return err
+}
+
+func deleteProduct(ctx context.Context, tx *sql.Tx, id int64) error {
+	for _, table := range []string{"product_terms", "product_allergens"} {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`DELETE FROM %s WHERE product_id = ?`, table), id); err != nil {
+			return err
+		}
+	}
+	for _, table := range childTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`DELETE FROM %s WHERE product_id = ?`, table), id); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = ?`, id)
+	return err
+}
+
+// loadChildren fills sourcing_values, ingredients and allergens of products
+// keyed by id.
+func loadChildren(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}, products map[int64]*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]interface{}, 0, len(products))
+	for id := range products {
+		ids = append(ids, id)
+	}
+	in := placeholders(len(ids))
+	for field, table := range childTables {
+		rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT product_id, value FROM %s
+			WHERE product_id IN (%s) ORDER BY product_id, position`,
+			table, in), ids...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			var v string
+			if err := rows.Scan(&id, &v); err != nil {
+				rows.Close()
+				return err
+			}
+			p := products[id]
+			if field == "sourcing_values" {
+				p.SourcingValues = append(p.SourcingValues, v)
+			} else {
+				p.Ingredients = append(p.Ingredients, v)
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT product_id, allergen,
+		may_contain FROM product_allergens WHERE product_id IN (%s)`, in),
+		ids...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		var allergen string
+		var mayContain bool
+		if err := rows.Scan(&id, &allergen, &mayContain); err != nil {
+			rows.Close()
+			return err
+		}
+		a := &products[id].Allergens
+		if mayContain {
+			a.MayContain = append(a.MayContain, model.Allergen(allergen))
+		} else {
+			a.Contains = append(a.Contains, model.Allergen(allergen))
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, p := range products {
+		p.Allergens.Sort()
+		p.StructuredIngredients = model.ParseIngredients(p.Ingredients)
+	}
+	return nil
+}
+
+// filterClauses translates filter to conditions on the table products and
+// their arguments. Values of child tables are matched by their indexes.
+func filterClauses(filter *model.Filter) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if filter == nil {
+		return where, args
+	}
+	children := []struct {
+		table         string
+		with, without []string
+	}{
+		{childTables["ingredients"], filter.Ingredients,
+			filter.WithoutIngredients},
+		{childTables["sourcing_values"], filter.SourcingValues,
+			filter.WithoutSourcingValues},
+	}
+	for _, c := range children {
+		for _, v := range c.with {
+			where = append(where, fmt.Sprintf(`EXISTS (SELECT 1 FROM %s
+				WHERE product_id = products.id AND value = ?)`, c.table))
+			args = append(args, v)
+		}
+		if len(c.without) != 0 {
+			where = append(where, fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM %s
+				WHERE product_id = products.id AND value IN (%s))`, c.table,
+				placeholders(len(c.without))))
+			args = append(args, strArgs(c.without)...)
+		}
+	}
+	if len(filter.DietaryCertifications) != 0 {
+		where = append(where, fmt.Sprintf("dietary_certifications IN (%s)",
+			placeholders(len(filter.DietaryCertifications))))
+		args = append(args, strArgs(filter.DietaryCertifications)...)
+	}
+	if len(filter.WithoutDietaryCertifications) != 0 {
+		where = append(where, fmt.Sprintf(
+			"dietary_certifications NOT IN (%s)",
+			placeholders(len(filter.WithoutDietaryCertifications))))
+		args = append(args, strArgs(filter.WithoutDietaryCertifications)...)
+	}
+	if len(filter.FreeFrom) != 0 {
+		where = append(where, fmt.Sprintf(`NOT EXISTS (SELECT 1
+			FROM product_allergens WHERE product_id = products.id
+			AND allergen IN (%s))`, placeholders(len(filter.FreeFrom))))
+		for _, allergen := range filter.FreeFrom {
+			args = append(args, string(allergen))
+		}
+	}
+	if filter.NamePrefix != "" {
+		// A range of the index on name. 0xff never appears in UTF-8 so every
+		// name of the prefix is less than the upper bound.
+		where = append(where, "name >= ? AND name < ?")
+		args = append(args, filter.NamePrefix, filter.NamePrefix+"\xff")
+	}
+	return where, args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func strArgs(values []string) []interface{} {
+	args := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return args
+}
+
+// Create exclusively creates product. Success only if no Product with the
+// same ProductId existed.
+func (h *SQLProductBackend) Create(ctx context.Context, product *model.Product) error {
+	if product.ProductID == "" {
+		log.Error("Invalid productId", "productId", product.ProductID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return h.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := findID(ctx, tx, product.ProductID); err == nil {
+			log.Error("Create existed failed ", "productId",
+				product.ProductID, "err", backend.ErrAlreadyExists)
+			return pe.WithStack(backend.ErrAlreadyExists)
+		} else if err != sql.ErrNoRows {
+			log.Error("Create failed", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		id, err := insertProduct(ctx, tx, product)
+		if err != nil {
+			log.Error("Create failed", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		log.Debug(fmt.Sprintf("Create id=%d", id),
+			"productId", product.ProductID)
+		return nil
+	})
+}
+
+// Upsert inserts product. If a Product with the same productID existed already,
+// then a replacement is performed.
+func (h *SQLProductBackend) Upsert(ctx context.Context, product *model.Product) error {
+	if product.ProductID == "" {
+		log.Error("Invalid productId", "productId", product.ProductID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return h.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := findID(ctx, tx, product.ProductID)
+		if err == sql.ErrNoRows {
+			id, err := insertProduct(ctx, tx, product)
+			if err != nil {
+				log.Error("Upsert failed", "productId", product.ProductID,
+					"err", err)
+				return pe.WithStack(err)
+			}
+			log.Debug(fmt.Sprintf("Upsert insert id=%d", id),
+				"productId", product.ProductID)
+			return nil
+		} else if err != nil {
+			log.Error("Upsert failed", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		if err := keepRevision(ctx, tx, id, false); err != nil {
+			log.Error("Upsert failed", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		if err := replaceProduct(ctx, tx, id, product); err != nil {
+			log.Error("Upsert failed", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		log.Debug("Upsert update succeeded", "productId", product.ProductID)
+		return nil
+	})
+}
+
+// Update updates product. Success only if a Product with the same
+// ProductId existed. Return error if not existed.
+func (h *SQLProductBackend) Update(ctx context.Context, product *model.Product) error {
+	return h.update(ctx, product, 0)
+}
+
+// UpdateIfMatch updates product like Update but only if the version of the
+// stored Product is version.
+func (h *SQLProductBackend) UpdateIfMatch(ctx context.Context, product *model.Product, version int64) error {
+	if err := checkVersion(product.ProductID, version); err != nil {
+		return err
+	}
+	return h.update(ctx, product, version)
+}
+
+func (h *SQLProductBackend) update(ctx context.Context, product *model.Product, version int64) error {
+	if product.ProductID == "" {
+		log.Error("Invalid productId", "productId", product.ProductID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return h.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := findMatched(ctx, tx, product.ProductID, version)
+		if err != nil {
+			log.Error("Update failed ", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		if err := keepRevision(ctx, tx, id, false); err != nil {
+			log.Error("Update failed ", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		if err := replaceProduct(ctx, tx, id, product); err != nil {
+			log.Error("Update failed ", "productId", product.ProductID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		log.Debug("Update succeeded", "productId", product.ProductID)
+		return nil
+	})
+}
+
+// UpdatePartial updates product. Success only if a Product with the same
+// ProductId existed. Return error if not existed.
+func (h *SQLProductBackend) UpdatePartial(ctx context.Context, productID string, kvs map[string]interface{}) error {
+	return h.updatePartial(ctx, productID, kvs, 0)
+}
+
+// UpdatePartialIfMatch updates product like UpdatePartial but only if the
+// version of the stored Product is version.
+func (h *SQLProductBackend) UpdatePartialIfMatch(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
+	if err := checkVersion(productID, version); err != nil {
+		return err
+	}
+	return h.updatePartial(ctx, productID, kvs, version)
+}
+
+func (h *SQLProductBackend) updatePartial(ctx context.Context, productID string, kvs map[string]interface{}, version int64) error {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+
+	if pid, ok := kvs["productId"]; ok && pid != productID {
+		log.Error("Different productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+
+	// Check if input has only keys of columns or child tables
+	for k := range kvs {
+		_, isColumn := columns[k]
+		_, isChild := childTables[k]
+		if !isColumn && !isChild && k != "allergens" {
+			log.Error("Unknown extra field", "productId", productID,
+				"err", backend.ErrInvalidArgument)
+			return pe.WithStack(backend.ErrInvalidArgument)
+		}
+	}
+
+	// Check if values of kvs fits types of fields of Product
+	var patch model.Product
+	if err := patch.Patch(kvs); err != nil {
+		log.Error("Patch failed ", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	// Typed values of kvs, where null is the zero value
+	var values map[string]interface{}
+	bs, _ := json.Marshal(&patch)
+	json.Unmarshal(bs, &values)
+
+	return h.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := findMatched(ctx, tx, productID, version)
+		if err != nil {
+			log.Error("Update failed ", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if err := keepRevision(ctx, tx, id, false); err != nil {
+			log.Error("Update failed ", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		sets := []string{"version = version + 1", "updated_at = ?"}
+		args := []interface{}{toMillis(backend.Now())}
+		for k := range kvs {
+			if column, ok := columns[k]; ok && k != "productId" {
+				sets = append(sets, column+" = ?")
+				args = append(args, values[k])
+			}
+		}
+		args = append(args, id)
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`UPDATE products SET %s WHERE id = ?`,
+			strings.Join(sets, ", ")), args...); err != nil {
+			log.Error("Update failed ", "productId", productID,
+				"err", err)
+			return pe.WithStack(err)
+		}
+		for k, table := range childTables {
+			if _, ok := kvs[k]; !ok {
+				continue
+			}
+			vs := patch.SourcingValues
+			if k == "ingredients" {
+				vs = patch.Ingredients
+			}
+			if err := replaceChild(ctx, tx, table, id, vs); err != nil {
+				log.Error("Update failed ", "productId", productID,
+					"err", err)
+				return pe.WithStack(err)
+			}
+		}
+		if _, ok := kvs["allergens"]; ok {
+			if err := replaceAllergens(ctx, tx, id,
+				&patch.Allergens); err != nil {
+				log.Error("Update failed ", "productId", productID,
+					"err", err)
+				return pe.WithStack(err)
+			}
+		}
+		if err := indexTerms(ctx, tx, id); err != nil {
+			log.Error("Update failed ", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		log.Debug("Update succeeded", "productId", productID)
+		return nil
+	})
+}
+
+// Read finds the Product with the given productID. Return error if not found.
+func (h *SQLProductBackend) Read(ctx context.Context, productID string) (*model.Product, error) {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	id, product, err := scanProduct(h.db.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT %s FROM products WHERE product_id = ?`, productColumns),
+		productID))
+	if err == sql.ErrNoRows {
+		return nil, pe.WithStack(backend.ErrNotFound)
+	} else if err != nil {
+		log.Error("QueryRow failed", "productId", productID, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	if err := loadChildren(ctx, h.db, map[int64]*model.Product{
+		id: product,
+	}); err != nil {
+		log.Error("loadChildren failed", "productId", productID, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	log.Debug(fmt.Sprintf("Find id=%d", id), "productId", productID)
+	return product, nil
+}
+
+// ReadMany reads a page of products. Cursor is from last ReadMany and
+// represents the end of the previous page. Limit is the number of Products that
+// will be returned in a page. If cursor is empty then ReadMany begins from the
+// first page. Limit must be larger than 0. Filter, if not nil, selects
+// products read. Order sorts them, it must be the same for every page. The page
+// is empty if no more products are read, and only the last page has no cursor.
+func (h *SQLProductBackend) ReadMany(ctx context.Context, cursor string, limit int, filter *model.Filter, order model.Sort) (*model.Products, error) {
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	if !order.IsValid() {
+		log.Error("Invalid sort", "sort", order,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	field := order.Field()
+	column, op, direction := "id", ">", ""
+	if field != "" {
+		column = sortColumns[field]
+	}
+	if order.IsDescending() {
+		op, direction = "<", " DESC"
+	}
+	where, args := filterClauses(filter)
+	if cursor != "" {
+		key, id, err := parseCursor(cursor, field)
+		if err != nil {
+			log.Error("Invalid cursor", "cursor", cursor, "err", err)
+			return nil, err
+		}
+		if field == "" {
+			where = append(where, "id > ?")
+			args = append(args, id)
+		} else {
+			where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))",
+				column, op, column, op))
+			args = append(args, key, key, id)
+		}
+	}
+	var whereClause string
+	if len(where) != 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+	orderBy := column + direction
+	if field != "" {
+		orderBy += ", id" + direction
+	}
+	// One more than limit tells if there's a next page.
+	rows, err := h.db.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM products
+		%s ORDER BY %s LIMIT ?`, productColumns, whereClause, orderBy),
+		append(args, limit+1)...)
+	if err != nil {
+		log.Error("Query failed", "from", cursor, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	var ids []int64
+	products := make(map[int64]*model.Product)
+	for rows.Next() {
+		id, product, err := scanProduct(rows)
+		if err != nil {
+			rows.Close()
+			log.Error("Scan failed", "from", cursor, "err", err)
+			return nil, translate(pe.WithStack(err))
+		}
+		ids = append(ids, id)
+		products[id] = product
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Error("Query failed", "from", cursor, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	ret := &model.Products{
+		Products: make([]model.Product, 0),
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		last := ids[limit-1]
+		ret.Cursor = strconv.FormatInt(last, 10)
+		if field != "" {
+			ret.Cursor = backend.JoinCursor(
+				backend.SortKey(products[last], field), ret.Cursor)
+		}
+	}
+	if err := loadChildren(ctx, h.db, products); err != nil {
+		log.Error("loadChildren failed", "from", cursor, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	for _, id := range ids {
+		ret.Products = append(ret.Products, *products[id])
+	}
+	log.Debug("ReadMany succeeded", "count", len(ids), "from", cursor,
+		"to", ret.Cursor)
+	return ret, nil
+}
+
+// parseCursor parses a cursor of ReadMany sorted by field to the sort key and
+// id of the last product of the previous page. Timestamps as keys are parsed
+// as they're stored.
+func parseCursor(cursor string, field string) (interface{}, int64, error) {
+	var key interface{}
+	id := cursor
+	if field != "" {
+		k, i, ok := backend.SplitCursor(cursor)
+		if !ok {
+			return nil, 0, pe.WithStack(backend.ErrInvalidArgument)
+		}
+		key, id = k, i
+		if field == "updated_at" {
+			ms, err := strconv.ParseInt(k, 10, 64)
+			if err != nil {
+				return nil, 0, pe.WithStack(backend.ErrInvalidArgument)
+			}
+			key = ms
+		}
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, 0, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return key, n, nil
+}
+
+// Count counts Products matching filter.
+func (h *SQLProductBackend) Count(ctx context.Context, filter *model.Filter) (int64, error) {
+	where, args := filterClauses(filter)
+	var whereClause string
+	if len(where) != 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+	var n int64
+	if err := h.db.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT COUNT(*) FROM products %s`, whereClause), args...).
+		Scan(&n); err != nil {
+		log.Error("Query failed", "err", err)
+		return 0, translate(pe.WithStack(err))
+	}
+	return n, nil
+}
+
+// Search finds Products matching any word of query in name, description, story
+// or ingredients, ordered by relevance. Cursor is from last Search of the same
+// query. Limit must be larger than 0. Return backend.ErrInvalidArgument if
+// query has no words to search. The page is empty if no more Products match.
+func (h *SQLProductBackend) Search(ctx context.Context, query string, cursor string, limit int) (*model.SearchResults, error) {
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		log.Error("Invalid query", "query", query,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	// The cursor is the number of hits read. An unrecognized one reads from
+	// the first page.
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	// One more than limit tells if there's a next page.
+	rows, err := h.db.QueryContext(ctx, fmt.Sprintf(`SELECT p.id,
+		SUM(t.weight) AS score FROM product_terms t
+		JOIN products p ON p.id = t.product_id WHERE t.term IN (%s)
+		GROUP BY p.id, p.product_id ORDER BY score DESC, p.product_id
+		LIMIT ? OFFSET ?`, placeholders(len(terms))),
+		append(strArgs(terms), limit+1, offset)...)
+	if err != nil {
+		log.Error("Query failed", "query", query, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	var ids []interface{}
+	scores := make(map[int64]float64)
+	for rows.Next() {
+		var id int64
+		var score float64
+		if err := rows.Scan(&id, &score); err != nil {
+			rows.Close()
+			log.Error("Scan failed", "query", query, "err", err)
+			return nil, translate(pe.WithStack(err))
+		}
+		ids = append(ids, id)
+		scores[id] = score
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Error("Query failed", "query", query, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	ret := &model.SearchResults{
+		Hits: make([]model.SearchHit, 0),
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		ret.Cursor = strconv.Itoa(offset + limit)
+	}
+	if len(ids) == 0 {
+		return ret, nil
+	}
+
+	rows, err = h.db.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM products
+		WHERE id IN (%s)`, productColumns, placeholders(len(ids))), ids...)
+	if err != nil {
+		log.Error("Query failed", "query", query, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	products := make(map[int64]*model.Product)
+	for rows.Next() {
+		id, product, err := scanProduct(rows)
+		if err != nil {
+			rows.Close()
+			log.Error("Scan failed", "query", query, "err", err)
+			return nil, translate(pe.WithStack(err))
+		}
+		products[id] = product
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Error("Query failed", "query", query, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	if err := loadChildren(ctx, h.db, products); err != nil {
+		log.Error("loadChildren failed", "query", query, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	for _, id := range ids {
+		product, ok := products[id.(int64)]
+		if !ok {
+			// Deleted since it's found
+			continue
+		}
+		ret.Hits = append(ret.Hits, model.SearchHit{
+			Product:    *product,
+			Score:      scores[id.(int64)],
+			Highlights: search.Highlight(product, terms),
+		})
+	}
+	log.Debug("Search succeeded", "query", query, "count", len(ids),
+		"from", cursor, "to", ret.Cursor)
+	return ret, nil
+}
+
+// Delete moves the Product with productID to the trash, where it replaces one
+// trashed earlier with the same productID.
+func (h *SQLProductBackend) Delete(ctx context.Context, productID string) error {
+	return h.delete(ctx, productID, 0)
+}
+
+// DeleteIfMatch deletes the Product with productID only if its version is
+// version.
+func (h *SQLProductBackend) DeleteIfMatch(ctx context.Context, productID string, version int64) error {
+	if err := checkVersion(productID, version); err != nil {
+		return err
+	}
+	return h.delete(ctx, productID, version)
+}
+
+func (h *SQLProductBackend) delete(ctx context.Context, productID string, version int64) error {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return h.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := findMatched(ctx, tx, productID, version)
+		if err != nil {
+			log.Error("Remove failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if err := keepRevision(ctx, tx, id, true); err != nil {
+			log.Error("Remove failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if err := trashProduct(ctx, tx, id); err != nil {
+			log.Error("Remove failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if err := deleteProduct(ctx, tx, id); err != nil {
+			log.Error("Remove failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		log.Debug("Remove succeeded", "productId", productID)
+		return nil
+	})
+}
+
+// ReadRevisions reads revisions of the Product with productID, which are empty
+// if the Product has never been superseded.
+func (h *SQLProductBackend) ReadRevisions(ctx context.Context, productID string) (*model.Revisions, error) {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	rows, err := h.db.QueryContext(ctx, fmt.Sprintf(`SELECT %s
+		FROM product_revisions WHERE product_id = ? ORDER BY revision`,
+		revisionColumns), productID)
+	if err != nil {
+		log.Error("Query failed", "productId", productID, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	defer rows.Close()
+	ret := &model.Revisions{
+		Revisions: make([]model.Revision, 0),
+	}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			log.Error("Scan failed", "productId", productID, "err", err)
+			return nil, translate(pe.WithStack(err))
+		}
+		ret.Revisions = append(ret.Revisions, *rev)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("Query failed", "productId", productID, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	log.Debug("ReadRevisions succeeded", "productId", productID,
+		"count", len(ret.Revisions))
+	return ret, nil
+}
+
+// ReadRevision reads the revision of the Product with productID. Return error
+// if not found.
+func (h *SQLProductBackend) ReadRevision(ctx context.Context, productID string, revision int64) (*model.Revision, error) {
+	if productID == "" || revision <= 0 {
+		log.Error(fmt.Sprintf("Invalid revision:%d", revision),
+			"productId", productID, "err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	rev, err := scanRevision(h.db.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT %s FROM product_revisions
+		WHERE product_id = ? AND revision = ?`, revisionColumns),
+		productID, revision))
+	if err == sql.ErrNoRows {
+		return nil, pe.WithStack(backend.ErrNotFound)
+	} else if err != nil {
+		log.Error("QueryRow failed", "productId", productID, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	return rev, nil
+}
+
+func scanTrashed(r row) (*model.TrashedProduct, error) {
+	var product string
+	var deletedAt int64
+	var tp model.TrashedProduct
+	if err := r.Scan(&tp.Version, &product, &deletedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(product), &tp.Product); err != nil {
+		return nil, err
+	}
+	// Version is not in json
+	tp.Product.Version = tp.Version
+	tp.Product.StructuredIngredients = model.ParseIngredients(
+		tp.Product.Ingredients)
+	tp.DeletedAt = fromMillis(deletedAt)
+	return &tp, nil
+}
+
+// ReadTrash reads a page of trashed products ordered by productId. Cursor is
+// from last ReadTrash. Limit must be larger than 0. The cursor of the last
+// page is empty.
+func (h *SQLProductBackend) ReadTrash(ctx context.Context, cursor string, limit int) (*model.Trash, error) {
+	if limit <= 0 {
+		log.Error(fmt.Sprintf("Invalid limit:%d", limit),
+			"err", backend.ErrInvalidArgument)
+		return nil, pe.WithStack(backend.ErrInvalidArgument)
+	}
+	// One more than limit tells if there's a next page.
+	rows, err := h.db.QueryContext(ctx, `SELECT version, product, deleted_at
+		FROM trashed_products WHERE product_id > ? ORDER BY product_id
+		LIMIT ?`, cursor, limit+1)
+	if err != nil {
+		log.Error("Query failed", "from", cursor, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	defer rows.Close()
+	ret := &model.Trash{
+		Products: make([]model.TrashedProduct, 0),
+	}
+	for rows.Next() {
+		tp, err := scanTrashed(rows)
+		if err != nil {
+			log.Error("Scan failed", "from", cursor, "err", err)
+			return nil, translate(pe.WithStack(err))
+		}
+		ret.Products = append(ret.Products, *tp)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("Query failed", "from", cursor, "err", err)
+		return nil, translate(pe.WithStack(err))
+	}
+	if len(ret.Products) > limit {
+		ret.Products = ret.Products[:limit]
+		ret.Cursor = ret.Products[limit-1].ProductID
+	}
+	log.Debug("ReadTrash succeeded", "count", len(ret.Products),
+		"from", cursor, "to", ret.Cursor)
+	return ret, nil
+}
+
+// RestoreTrashed moves the trashed Product with productID back, as a new
+// version. Return error if it's not trashed or a Product with the same
+// productID has been created since.
+func (h *SQLProductBackend) RestoreTrashed(ctx context.Context, productID string) error {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	return h.withTx(ctx, func(tx *sql.Tx) error {
+		var id int64
+		var product string
+		var deletedAt int64
+		var tp model.TrashedProduct
+		err := tx.QueryRowContext(ctx, `SELECT id, version, product, deleted_at
+			FROM trashed_products WHERE product_id = ?`, productID).Scan(
+			&id, &tp.Version, &product, &deletedAt)
+		if err != nil {
+			log.Error("Restore failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if err := json.Unmarshal([]byte(product), &tp.Product); err != nil {
+			log.Error("Restore failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if _, err := findID(ctx, tx, productID); err == nil {
+			log.Error("Restore existed failed", "productId", productID,
+				"err", backend.ErrAlreadyExists)
+			return pe.WithStack(backend.ErrAlreadyExists)
+		} else if err != sql.ErrNoRows {
+			log.Error("Restore failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		// The id is kept so that the product is where it was in pages.
+		p := &tp.Product
+		if _, err := tx.ExecContext(ctx, `INSERT INTO products (id, product_id,
+			name, image_closed, image_open, description, story, allergy_info,
+			dietary_certifications, version, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, p.ProductID, p.Name, p.ImageClosed, p.ImageOpen,
+			p.Description, p.Story, p.AllergyInfo, p.DietaryCertifications,
+			tp.Version+1, toMillis(p.CreatedAt),
+			toMillis(backend.Now())); err != nil {
+			log.Error("Restore failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if err := replaceChildren(ctx, tx, id, p); err != nil {
+			log.Error("Restore failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if err := indexTerms(ctx, tx, id); err != nil {
+			log.Error("Restore failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM trashed_products WHERE product_id = ?`,
+			productID); err != nil {
+			log.Error("Restore failed", "productId", productID, "err", err)
+			return pe.WithStack(err)
+		}
+		log.Debug(fmt.Sprintf("Restore id=%d", id), "productId", productID)
+		return nil
+	})
+}
+
+// PurgeTrashed permanently deletes the trashed Product with productID. Return
+// error if it's not trashed.
+func (h *SQLProductBackend) PurgeTrashed(ctx context.Context, productID string) error {
+	if productID == "" {
+		log.Error("Invalid productId", "productId", productID,
+			"err", backend.ErrInvalidArgument)
+		return pe.WithStack(backend.ErrInvalidArgument)
+	}
+	res, err := h.db.ExecContext(ctx,
+		`DELETE FROM trashed_products WHERE product_id = ?`, productID)
+	if err != nil {
+		log.Error("Purge failed", "productId", productID, "err", err)
+		return translate(pe.WithStack(err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return pe.WithStack(backend.ErrNotFound)
+	}
+	log.Debug("Purge succeeded", "productId", productID)
+	return nil
+}
+
+// PurgeTrashedBefore permanently deletes Products trashed before t and returns
+// the number of them.
+func (h *SQLProductBackend) PurgeTrashedBefore(ctx context.Context, t time.Time) (int, error) {
+	res, err := h.db.ExecContext(ctx,
+		`DELETE FROM trashed_products WHERE deleted_at < ?`, toMillis(t))
+	if err != nil {
+		log.Error("Purge failed", "before", t, "err", err)
+		return 0, translate(pe.WithStack(err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Error("Purge failed", "before", t, "err", err)
+		return 0, translate(pe.WithStack(err))
+	}
+	log.Debug("Purge succeeded", "before", t, "count", n)
+	return int(n), nil
+}
+
+// CreateSQLProductBackend creates SQLProductBackend
+func CreateSQLProductBackend(db *sql.DB) (*SQLProductBackend, error) {
+	return &SQLProductBackend{
+		db: db,
+	}, nil
+}
@@ -0,0 +1,136 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/backendtest"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	_ "github.com/mattn/go-sqlite3"
+	pe "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var ctx = context.Background()
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to ":memory:" opens a distinct database
+	db.SetMaxOpenConns(1)
+	if err := CreateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLProductBackend_CreateRead(t *testing.T) {
+	b, _ := CreateSQLProductBackend(openDB(t))
+	defer b.Close()
+
+	product := &model.Product{
+		ProductID:      "001",
+		Name:           "One",
+		SourcingValues: []string{"Non-GMO", "Fairtrade"},
+		Ingredients:    []string{"cream", "skim milk", "sugar"},
+	}
+	assert.NoError(t, b.Create(ctx, product))
+	assert.Equal(t, backend.ErrAlreadyExists, pe.Cause(b.Create(ctx, product)))
+
+	result, err := b.Read(ctx, "001")
+	assert.NoError(t, err)
+	assert.Equal(t, product.Name, result.Name)
+	assert.Equal(t, product.SourcingValues, result.SourcingValues)
+	assert.Equal(t, product.Ingredients, result.Ingredients)
+
+	_, err = b.Read(ctx, "002")
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(err))
+}
+
+func TestSQLProductBackend_UpsertUpdatePartial(t *testing.T) {
+	b, _ := CreateSQLProductBackend(openDB(t))
+	defer b.Close()
+
+	assert.NoError(t, b.Upsert(ctx, &model.Product{
+		ProductID:   "001",
+		Name:        "One",
+		Ingredients: []string{"cream", "sugar"},
+	}))
+	assert.NoError(t, b.Upsert(ctx, &model.Product{
+		ProductID:   "001",
+		Name:        "1",
+		Ingredients: []string{"milk"},
+	}))
+	result, _ := b.Read(ctx, "001")
+	assert.Equal(t, "1", result.Name)
+	assert.Equal(t, []string{"milk"}, result.Ingredients)
+
+	assert.NoError(t, b.UpdatePartial(ctx, "001", map[string]interface{}{
+		"story":       "once upon a time",
+		"ingredients": []string{"soy", "milk"},
+	}))
+	result, _ = b.Read(ctx, "001")
+	assert.Equal(t, "1", result.Name)
+	assert.Equal(t, "once upon a time", result.Story)
+	assert.Equal(t, []string{"soy", "milk"}, result.Ingredients)
+
+	err := b.UpdatePartial(ctx, "001", map[string]interface{}{"extra": 1})
+	assert.Equal(t, backend.ErrInvalidArgument, pe.Cause(err))
+	err = b.UpdatePartial(ctx, "002", map[string]interface{}{"name": "Two"})
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(err))
+}
+
+func TestSQLProductBackend_ReadManyDelete(t *testing.T) {
+	b, _ := CreateSQLProductBackend(openDB(t))
+	defer b.Close()
+
+	for _, id := range []string{"001", "002", "003"} {
+		assert.NoError(t, b.Create(ctx, &model.Product{
+			ProductID:   id,
+			Ingredients: []string{id},
+		}))
+	}
+	assert.NoError(t, b.Delete(ctx, "002"))
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(b.Delete(ctx, "002")))
+
+	page, err := b.ReadMany(ctx, "", 1, nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Products, 1)
+	assert.Equal(t, "001", page.Products[0].ProductID)
+
+	page, err = b.ReadMany(ctx, page.Cursor, 5, nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Products, 1)
+	assert.Equal(t, "003", page.Products[0].ProductID)
+	assert.Equal(t, []string{"003"}, page.Products[0].Ingredients)
+	assert.Empty(t, page.Cursor)
+}
+
+func TestSQLAPIKeyBackend_Authenticate(t *testing.T) {
+	b, _ := CreateSQLAPIKeyBackend(openDB(t))
+	assert.NoError(t, b.Add("testkey"))
+	assert.NoError(t, b.Add("testkey"))
+	assert.NoError(t, b.Authenticate(ctx, "testkey"))
+	assert.Equal(t, backend.ErrNotFound, pe.Cause(b.Authenticate(ctx, "nokey")))
+}
+
+func TestSQLProductBackend_Conformance(t *testing.T) {
+	backendtest.TestProductBackend(t, func(t *testing.T) (backendtest.ProductBackend, func()) {
+		b, _ := CreateSQLProductBackend(openDB(t))
+		return b, b.Close
+	})
+}
+
+func TestSQLAPIKeyBackend_Conformance(t *testing.T) {
+	backendtest.TestAPIKeyBackend(t, func(t *testing.T, apiKeys []string) (backendtest.APIKeyBackend, func()) {
+		db := openDB(t)
+		b, _ := CreateSQLAPIKeyBackend(db)
+		for _, k := range apiKeys {
+			b.Add(k)
+		}
+		return b, func() { db.Close() }
+	})
+}
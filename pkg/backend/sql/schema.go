@@ -0,0 +1,331 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/cfchou/icecream/pkg/backend"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	pe "github.com/pkg/errors"
+	"reflect"
+)
+
+// Timestamps are stored as milliseconds since epoch, 0 if unknown.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS products (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		product_id TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL DEFAULT '',
+		image_closed TEXT NOT NULL DEFAULT '',
+		image_open TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		story TEXT NOT NULL DEFAULT '',
+		allergy_info TEXT NOT NULL DEFAULT '',
+		dietary_certifications TEXT NOT NULL DEFAULT '',
+		version INTEGER NOT NULL DEFAULT 1,
+		created_at INTEGER NOT NULL DEFAULT 0,
+		updated_at INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS products_name ON products(name)`,
+	`CREATE INDEX IF NOT EXISTS products_dietary_certifications
+		ON products(dietary_certifications)`,
+	`CREATE TABLE IF NOT EXISTS product_sourcing_values (
+		product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (product_id, position)
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_sourcing_values_value
+		ON product_sourcing_values(value)`,
+	`CREATE TABLE IF NOT EXISTS product_ingredients (
+		product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (product_id, position)
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_ingredients_value
+		ON product_ingredients(value)`,
+	// may_contain is 1 if the product may contain allergen as traces.
+	`CREATE TABLE IF NOT EXISTS product_allergens (
+		product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		allergen TEXT NOT NULL,
+		may_contain INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (product_id, allergen)
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_allergens_allergen
+		ON product_allergens(allergen)`,
+	// weight is of term in the product, see package search.
+	`CREATE TABLE IF NOT EXISTS product_terms (
+		product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		term TEXT NOT NULL,
+		weight REAL NOT NULL,
+		PRIMARY KEY (term, product_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_terms_product_id
+		ON product_terms(product_id)`,
+	// product is the Product of a revision in json.
+	`CREATE TABLE IF NOT EXISTS product_revisions (
+		product_id TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		product TEXT NOT NULL,
+		deleted INTEGER NOT NULL DEFAULT 0,
+		apikey TEXT NOT NULL DEFAULT '',
+		revised_at INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (product_id, revision)
+	)`,
+	// product is the trashed Product in json. id is what it had in products.
+	`CREATE TABLE IF NOT EXISTS trashed_products (
+		product_id TEXT PRIMARY KEY,
+		id INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		product TEXT NOT NULL,
+		deleted_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS trashed_products_deleted_at
+		ON trashed_products(deleted_at)`,
+	`CREATE TABLE IF NOT EXISTS apikeys (
+		apikey TEXT PRIMARY KEY
+	)`,
+}
+
+// addedColumns are columns added to tables created by an earlier schema.
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"products", "version", "INTEGER NOT NULL DEFAULT 1"},
+	{"products", "created_at", "INTEGER NOT NULL DEFAULT 0"},
+	{"products", "updated_at", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// addedIndexes are indexes on addedColumns, created after them.
+var addedIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS products_updated_at ON products(updated_at)`,
+}
+
+// CreateSchema creates tables and indexes if they don't exist. Columns added
+// since the tables were created are added as well, and so are terms of
+// products stored before they're indexed.
+func CreateSchema(db *sql.DB) error {
+	// Allergens are parsed once for products stored before they are.
+	allergensParsed, err := tableExists(db, "product_allergens")
+	if err != nil {
+		log.Error("CreateSchema failed", "err", err)
+		return pe.WithStack(err)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Error("CreateSchema failed", "err", err)
+			return pe.WithStack(err)
+		}
+	}
+	for _, ac := range addedColumns {
+		if err := addColumn(db, ac.table, ac.column, ac.definition); err != nil {
+			log.Error("CreateSchema failed", "table", ac.table,
+				"column", ac.column, "err", err)
+			return pe.WithStack(err)
+		}
+	}
+	for _, stmt := range addedIndexes {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Error("CreateSchema failed", "err", err)
+			return pe.WithStack(err)
+		}
+	}
+	if err := indexMissingTerms(db); err != nil {
+		log.Error("CreateSchema failed", "table", "product_terms", "err", err)
+		return pe.WithStack(err)
+	}
+	if err := repairIngredients(db); err != nil {
+		log.Error("CreateSchema failed", "table", "product_ingredients",
+			"err", err)
+		return pe.WithStack(err)
+	}
+	if !allergensParsed {
+		if err := parseAllergens(db); err != nil {
+			log.Error("CreateSchema failed", "table", "product_allergens",
+				"err", err)
+			return pe.WithStack(err)
+		}
+	}
+	log.Debug("CreateSchema succeeded")
+	return nil
+}
+
+// IsEmpty is true if no products, including trashed ones, are stored in db.
+func IsEmpty(db *sql.DB) (bool, error) {
+	var stored bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM products)
+		OR EXISTS (SELECT 1 FROM trashed_products)`).Scan(&stored); err != nil {
+		log.Error("IsEmpty failed", "err", err)
+		return false, pe.WithStack(err)
+	}
+	return !stored, nil
+}
+
+// indexMissingTerms indexes terms of products having none.
+func indexMissingTerms(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id FROM products
+		WHERE id NOT IN (SELECT product_id FROM product_terms)`)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, id := range ids {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := indexTerms(ctx, tx, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repairIngredients normalizes ingredients of products that were split on every
+// comma, e.g. "liquid sugar (sugar" and "water)". Products repaired get a new
+// version so that cached ones are not matched.
+func repairIngredients(db *sql.DB) error {
+	rows, err := db.Query(`SELECT product_id, value FROM product_ingredients
+		ORDER BY product_id, position`)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	ingredients := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var v string
+		if err := rows.Scan(&id, &v); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := ingredients[id]; !ok {
+			ids = append(ids, id)
+		}
+		ingredients[id] = append(ingredients[id], v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	n := 0
+	for _, id := range ids {
+		normalized := model.NormalizeIngredients(ingredients[id])
+		if reflect.DeepEqual(normalized, ingredients[id]) {
+			continue
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := replaceChild(ctx, tx, childTables["ingredients"], id,
+			normalized); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE products
+			SET version = version + 1, updated_at = ? WHERE id = ?`,
+			toMillis(backend.Now()), id); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		log.Info("Repair ingredients", "updated", n)
+	}
+	return nil
+}
+
+// parseAllergens parses allergy_info of products to their allergens.
+func parseAllergens(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, allergy_info FROM products
+		WHERE allergy_info != ''`)
+	if err != nil {
+		return err
+	}
+	allergens := make(map[int64]model.Allergens)
+	for rows.Next() {
+		var id int64
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return err
+		}
+		allergens[id] = model.ParseAllergyInfo(text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for id, a := range allergens {
+		if err := replaceAllergens(ctx, tx, id, &a); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// tableExists is true if table is in the schema.
+func tableExists(db *sql.DB, table string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = ?`, table).Scan(&n)
+	return n > 0, err
+}
+
+// addColumn adds column to table unless it exists.
+func addColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table,
+		column, definition))
+	return err
+}
@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"database/sql"
+	"github.com/cfchou/icecream/pkg/backend/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCreateSchema_AddColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// products created before versions are maintained
+	_, err = db.Exec(`CREATE TABLE products (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		product_id TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL DEFAULT '',
+		image_closed TEXT NOT NULL DEFAULT '',
+		image_open TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		story TEXT NOT NULL DEFAULT '',
+		allergy_info TEXT NOT NULL DEFAULT '',
+		dietary_certifications TEXT NOT NULL DEFAULT ''
+	)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO products (product_id, allergy_info)
+		VALUES ('001', 'contains milk, may contain peanuts')`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, CreateSchema(db))
+	// Idempotent
+	assert.NoError(t, CreateSchema(db))
+
+	b, _ := CreateSQLProductBackend(db)
+	product, err := b.Read(ctx, "001")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), product.Version)
+		// Parsed once for products stored before allergens are
+		assert.Equal(t, model.Allergens{
+			Contains:   []model.Allergen{model.Milk},
+			MayContain: []model.Allergen{model.Peanuts},
+		}, product.Allergens)
+	}
+}
+
+func TestCreateSchema_IndexTerms(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	assert.NoError(t, CreateSchema(db))
+	// products stored before terms are indexed
+	_, err = db.Exec(`INSERT INTO products (product_id, name)
+		VALUES ('001', 'Cookie Dough')`)
+	assert.NoError(t, err)
+	assert.NoError(t, CreateSchema(db))
+
+	b, _ := CreateSQLProductBackend(db)
+	results, err := b.Search(ctx, "cookie", "", 10)
+	if assert.NoError(t, err) && assert.Len(t, results.Hits, 1) {
+		assert.Equal(t, "001", results.Hits[0].Product.ProductID)
+	}
+}
+
+func TestCreateSchema_RepairIngredients(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	assert.NoError(t, CreateSchema(db))
+	b, _ := CreateSQLProductBackend(db)
+	// Ingredients split on every comma
+	assert.NoError(t, b.Create(ctx, &model.Product{
+		ProductID:   "001",
+		Ingredients: []string{"cream", "liquid sugar (sugar", "water)"},
+	}))
+	assert.NoError(t, b.Create(ctx, &model.Product{
+		ProductID:   "002",
+		Ingredients: []string{"cream"},
+	}))
+	assert.NoError(t, CreateSchema(db))
+	// Idempotent
+	assert.NoError(t, CreateSchema(db))
+
+	product, err := b.Read(ctx, "001")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"cream", "liquid sugar (sugar, water)"},
+			product.Ingredients)
+		assert.Equal(t, int64(2), product.Version)
+	}
+	product, err = b.Read(ctx, "002")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), product.Version)
+	}
+}
@@ -0,0 +1,11 @@
+package backend
+
+import (
+	"time"
+)
+
+// Now returns the time backends store as timestamps of a write. It's in UTC
+// and truncated to milliseconds, the precision every backend keeps.
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
@@ -0,0 +1,3 @@
+// Package productpb is the gRPC API of products, generated from
+// product.proto by `make proto`.
+package productpb